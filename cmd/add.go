@@ -9,8 +9,6 @@ import (
 	"github.com/melih-ucgun/veto/internal/config"
 	"github.com/melih-ucgun/veto/internal/consts"
 	"github.com/melih-ucgun/veto/internal/core"
-	"github.com/melih-ucgun/veto/internal/hub"
-	"github.com/melih-ucgun/veto/internal/system"
 	"github.com/melih-ucgun/veto/internal/transport"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -33,16 +31,7 @@ var addCmd = &cobra.Command{
 	Long:  `Intelligently adds resources to the active configuration profile. Detects files, packages, and services automatically.`,
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		manager := hub.NewRecipeManager("")
-		activeRecipe, _ := manager.GetActive()
-
-		configPath := consts.GetSystemProfilePath()
-		if activeRecipe != "" {
-			path, err := manager.GetRecipePath(activeRecipe)
-			if err == nil {
-				configPath = path
-			}
-		}
+		configPath := activeProfilePath()
 
 		// Verify config exists
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -55,7 +44,7 @@ var addCmd = &cobra.Command{
 
 		// Only local context needed for adding user
 		ctx := core.NewSystemContext(false, transport.NewLocalTransport())
-		system.Detect(ctx)
+		newFactCache().Detect(ctx, "")
 
 		for _, arg := range args {
 			pterm.Println()
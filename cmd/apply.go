@@ -17,8 +17,8 @@ import (
 	"github.com/melih-ucgun/veto/internal/hub"
 	"github.com/melih-ucgun/veto/internal/inventory" // New import
 	"github.com/melih-ucgun/veto/internal/resource"
+	"github.com/melih-ucgun/veto/internal/secrets"
 	"github.com/melih-ucgun/veto/internal/state"
-	"github.com/melih-ucgun/veto/internal/system"
 	"github.com/melih-ucgun/veto/internal/transport"
 )
 
@@ -38,20 +38,11 @@ Updates %s with the results.`, consts.GetStateFilePath()),
 		if len(args) > 0 {
 			configFile = args[0]
 		} else {
-			// Check active recipe
-			mgr := hub.NewRecipeManager("")
-			recipePath, err := mgr.GetRecipePath("")
-			if err == nil && recipePath != "" {
-				// Verify file exists
-				if _, err := os.Stat(recipePath); err == nil {
-					configFile = recipePath
-					pterm.Info.Printf("Using active recipe: %s\n", recipePath)
-				}
-			}
-
-			// Fallback
-			if configFile == "" {
-				configFile = "system.yaml"
+			var fromRecipe bool
+			configFile, fromRecipe = defaultConfigFile()
+			if fromRecipe {
+				pterm.Info.Printf("Using active recipe: %s\n", configFile)
+			} else {
 				pterm.Warning.Println("No active recipe found. Defaulting to system.yaml")
 			}
 		}
@@ -72,6 +63,20 @@ func init() {
 	applyCmd.Flags().IntVarP(&concurrency, "concurrency", "C", 5, "Number of concurrent hosts")
 }
 
+// defaultConfigFile returns the configuration apply uses when none is given: the
+// active recipe if its file exists (fromRecipe), system.yaml otherwise.
+func defaultConfigFile() (path string, fromRecipe bool) {
+	mgr := hub.NewRecipeManager("")
+	recipePath, err := mgr.GetRecipePath("")
+	if err == nil && recipePath != "" {
+		// Verify file exists
+		if _, err := os.Stat(recipePath); err == nil {
+			return recipePath, true
+		}
+	}
+	return "system.yaml", false
+}
+
 func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSnapshot bool, isPrune bool, decrypt bool) error {
 	// Header
 	pterm.DefaultHeader.WithFullWidth().WithBackgroundStyle(pterm.NewStyle(pterm.BgLightBlue)).
@@ -99,7 +104,7 @@ func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSn
 	ctx.Logger.SetLevel(logLevel)
 	localTransport.Logger = ctx.Logger
 
-	system.Detect(ctx)
+	newFactCache().Detect(ctx, "")
 
 	// 1.5 Load System Profile (if exists)
 	if data, err := os.ReadFile(consts.GetSystemProfilePath()); err == nil {
@@ -110,6 +115,42 @@ func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSn
 		}
 	}
 
+	// 2. Load Configuration
+	spinnerLoad, _ := pterm.DefaultSpinner.Start("Loading configuration...")
+	// In fleet mode each host decrypts its own X25519 secrets
+	cfg, err := config.LoadConfigWith(configFile, config.LoadOptions{Decrypt: decrypt, HostSecrets: invFile != "", Facts: newFactCache()})
+	if err != nil {
+		spinnerLoad.Fail(fmt.Sprintf("Error loading config file '%s': %v", configFile, err))
+		return err
+	}
+	spinnerLoad.Success("Configuration loaded")
+	ctx.Vars = cfg.Vars
+	if decrypt {
+		ctx.Keyring = config.Keyring()
+	}
+
+	// 3. Initialize State Manager on the backend configured in 'state:'
+	// Fleet hosts each get their own state, opened by the fleet manager
+	var stateMgr *state.Manager
+	if invFile == "" {
+		// Use ctx.Transport.GetFileSystem() for remote state capability
+		stateMgr, err = newStateManager(cfg.State, ctx.Transport.GetFileSystem())
+		if err != nil {
+			pterm.Error.Printf("Could not initialize state backend: %v\n", err)
+			return err
+		}
+
+		// Keep concurrent applies (watch, cron, manual) from writing the state at once
+		if !isDryRun {
+			unlock, err := lockState(stateMgr, "apply "+configFile)
+			if err != nil {
+				pterm.Error.Println(err)
+				return err
+			}
+			defer unlock()
+		}
+	}
+
 	// Snapshot Manager Setup
 	var snapMgr *snapshot.Manager
 	var preSnapID string
@@ -152,24 +193,6 @@ func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSn
 	pterm.DefaultTable.WithHasHeader(false).WithData(sysInfo).Render()
 	pterm.Println()
 
-	// 2. Initialize State Manager
-	statePath := consts.GetStateFilePath()
-	// Use ctx.Transport.GetFileSystem() for remote state capability
-	stateMgr, err := state.NewManager(statePath, ctx.Transport.GetFileSystem())
-	if err != nil {
-		pterm.Warning.Printf("Could not initialize state manager: %v\n", err)
-	}
-
-	// 3. Load Configuration
-	spinnerLoad, _ := pterm.DefaultSpinner.Start("Loading configuration...")
-	cfg, err := config.LoadConfig(configFile, decrypt)
-	if err != nil {
-		spinnerLoad.Fail(fmt.Sprintf("Error loading config file '%s': %v", configFile, err))
-		return err
-	}
-	spinnerLoad.Success("Configuration loaded")
-	ctx.Vars = cfg.Vars
-
 	// 4. Sort Resources (Global for both Local and Fleet)
 	spinnerSort, _ := pterm.DefaultSpinner.Start("Resolving dependencies...")
 	sortedResources, err := config.SortResources(cfg.Resources)
@@ -242,6 +265,12 @@ func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSn
 		}
 
 		fleetMgr := fleet.NewFleetManager(inv.Hosts, isDryRun, isPrune, ctx.Logger)
+		fleetMgr.Facts = newFactCache()
+		if decrypt {
+			fleetMgr.Keyring = config.Keyring()
+		}
+		fleetMgr.State = cfg.State
+		fleetMgr.LockTimeout = lockTimeout
 		if err := fleetMgr.ApplyConfig(layers, concurrency, createFn); err != nil {
 			return err
 		}
@@ -268,7 +297,7 @@ func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSn
 
 		if err := eng.RunParallel(layer, createFn); err != nil {
 			spinnerExec.Fail(fmt.Sprintf("Layer %d failed", i+1))
-			pterm.Error.Printf("Layer %d completed with errors: %v\n", i+1, err)
+			pterm.Error.Printf("Layer %d completed with errors: %s\n", i+1, secrets.Redact(err.Error()))
 			finalError = err // Keep track of error
 			break            // Stop processing layers on failure
 		}
@@ -312,7 +341,7 @@ func runApply(configFile, invFile string, concurrency int, isDryRun bool, skipSn
 		}
 
 		if err := eng.Prune(allItems, createFn); err != nil {
-			pterm.Error.Printf("Prune failed: %v\n", err)
+			pterm.Error.Printf("Prune failed: %s\n", secrets.Redact(err.Error()))
 			return err
 		}
 	}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/config"
+	"github.com/melih-ucgun/veto/internal/crypto"
+	"github.com/melih-ucgun/veto/internal/state"
+)
+
+var backupRestoreTo string
+var backupRestoreYes bool
+var backupGCDryRun bool
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Inspect, restore and clean up file backups",
+	Long: `Files are backed up before veto changes them. The content is stored once per sha256
+in .veto/backups, so unchanged files take no extra space however often they are backed up.
+Backups belong to the transaction that took them and are kept as long as it is in the
+history; 'veto backup gc' removes the rest. Fleet hosts keep their backups in
+.veto/hosts/<name>/backups; --host works on those.`,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list [filter]",
+	Short: "List backups, optionally only those whose path contains filter",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := openBackupManager(cmd, nil).List()
+		if err != nil {
+			pterm.Error.Printf("Failed to read backups: %v\n", err)
+			os.Exit(1)
+		}
+
+		tableData := [][]string{{"ID", "Transaction", "Path", "Size", "Time"}}
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if len(args) > 0 && !strings.Contains(e.Path, args[0]) {
+				continue
+			}
+			path := e.Path
+			if state.IsTreeBackup(e.Blob) {
+				path += "/"
+			}
+			tableData = append(tableData, []string{e.ID, shortID(e.TxID), path, formatSize(e.Size), e.Time.Format("2006-01-02 15:04:05")})
+		}
+		if len(tableData) == 1 {
+			pterm.Info.Println("No backups found.")
+			return
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	},
+}
+
+var backupShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the metadata of a backup",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		e, err := openBackupManager(cmd, nil).Find(args[0])
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+
+		pterm.DefaultSection.Println(e.ID)
+		pterm.Printf("Path:        %s\n", e.Path)
+		if e.Host != "" {
+			pterm.Printf("Host:        %s\n", e.Host)
+		}
+		pterm.Printf("Transaction: %s\n", e.TxID)
+		pterm.Printf("Time:        %s\n", e.Time.Format(time.RFC822))
+		pterm.Printf("Mode:        %04o\n", e.Mode.Perm())
+		if e.UID >= 0 {
+			pterm.Printf("Owner:       %d:%d\n", e.UID, e.GID)
+		}
+		pterm.Printf("Size:        %s\n", formatSize(e.Size))
+		pterm.Printf("SHA256:      %s\n", e.Hash)
+		pterm.Printf("Stored at:   %s\n", e.Blob)
+		if e.Encrypted() {
+			pterm.Println("Encrypted:   yes")
+		}
+		if state.IsTreeBackup(e.Blob) {
+			pterm.Println("Kind:        directory tree")
+		}
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Write a backup back to its original path, or elsewhere with --to",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// The files of a fleet host are restored over its transport
+		_, ctx, done, err := loadStateContext(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+		bm := openBackupManager(cmd, ctx.FS)
+		e, err := bm.Find(args[0])
+		if err != nil {
+			pterm.Error.Println(err)
+			done()
+			os.Exit(1)
+		}
+
+		target := e.Path
+		if backupRestoreTo != "" {
+			target = backupRestoreTo
+		}
+		if _, err := ctx.FS.Stat(target); err == nil && !backupRestoreYes {
+			ok, _ := pterm.DefaultInteractiveConfirm.
+				WithDefaultText(fmt.Sprintf("Overwrite %s with the backup from %s?", target, e.Time.Format(time.RFC822))).
+				WithDefaultValue(false).
+				Show()
+			if !ok {
+				pterm.Info.Println("Restore cancelled.")
+				return
+			}
+		}
+
+		decrypt := func(data []byte) ([]byte, error) {
+			value, err := crypto.DearmorFile(data)
+			if err != nil {
+				return nil, err
+			}
+			plain, err := config.Keyring().Decrypt(value)
+			return []byte(plain), err
+		}
+		if err := bm.Restore(e, target, decrypt); err != nil {
+			pterm.Error.Printf("Failed to restore %s: %v\n", e.ID, err)
+			done()
+			os.Exit(1)
+		}
+		pterm.Success.Printf("Restored %s to %s.\n", e.ID, target)
+	},
+}
+
+var backupGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove backups of transactions no longer in the history",
+	Long: `Removes the backups taken by transactions that have left the history (see
+keep_transactions and max_age in the 'state' section), and content no backup refers to.
+With --host, the backups of that fleet host are checked against its history.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+		unlock := func() {}
+		if !backupGCDryRun {
+			unlock, err = lockState(mgr, "backup gc")
+			if err != nil {
+				pterm.Error.Println(err)
+				os.Exit(1)
+			}
+		}
+		defer unlock()
+
+		stats, err := openBackupManager(cmd, nil).GC(mgr.GetTransactions(), backupGCDryRun)
+		if err != nil {
+			pterm.Error.Printf("Garbage collection failed: %v\n", err)
+			unlock()
+			os.Exit(1)
+		}
+		verb := "Removed"
+		if backupGCDryRun {
+			verb = "Dry run: would remove"
+		}
+		pterm.Info.Printf("%s %d backup(s) and %d stored file(s), %s.\n", verb, stats.Entries, stats.Blobs, formatSize(stats.Bytes))
+	},
+}
+
+// openBackupManager returns the backup store of this machine, or of the --host fleet
+// host, reading and writing the backed up files through hostFS (local when nil).
+func openBackupManager(cmd *cobra.Command, hostFS state.HostFS) *state.BackupManager {
+	if hostName, _ := cmd.Flags().GetString("host"); hostName != "" {
+		return state.NewHostBackupManager(hostName, hostFS)
+	}
+	bm := state.NewBackupManager("")
+	bm.FS = hostFS
+	return bm
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// formatSize formats n bytes for humans.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	addHostFlags(backupCmd)
+	backupCmd.AddCommand(backupListCmd, backupShowCmd, backupRestoreCmd, backupGCCmd)
+	backupRestoreCmd.Flags().StringVar(&backupRestoreTo, "to", "", "Restore to this path instead of the original one")
+	backupRestoreCmd.Flags().BoolVarP(&backupRestoreYes, "yes", "y", false, "Overwrite an existing file without asking")
+	backupGCCmd.Flags().BoolVar(&backupGCDryRun, "dry-run", false, "Only report what would be removed")
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr/file"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/melih-ucgun/veto/internal/config"
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/fleet"
+	"github.com/melih-ucgun/veto/internal/inventory"
+	"github.com/melih-ucgun/veto/internal/secrets"
+	"github.com/melih-ucgun/veto/internal/transport"
+)
+
+var evalAsExpr bool
+var evalHost string
+var evalInventory string
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [template | expression]",
+	Short: "Evaluate templates and 'when' expressions against live facts",
+	Long: `Renders a template or evaluates a 'when' expression against the detected system context
+and the variables of the configuration apply would load (the active recipe or system.yaml,
+or --config). Without an argument, starts an interactive REPL.
+
+Examples:
+  veto eval '{{ .Hardware.CPUModel }}'
+  veto eval --expr 'Distro == "arch"'
+  veto eval --host web1 --expr 'Hardware.CPUCore >= 4'
+  veto eval`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, closeFn, err := buildEvalContext(cmd)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		defer closeFn()
+
+		if len(args) == 0 {
+			runEvalREPL(ctx)
+			return
+		}
+
+		if !evaluateInput(ctx, args[0], evalAsExpr) {
+			closeFn()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+	evalCmd.Flags().BoolVarP(&evalAsExpr, "expr", "e", false, "Evaluate as a 'when' expression instead of a template")
+	evalCmd.Flags().StringVar(&evalHost, "host", "", "Evaluate against a remote host from the inventory")
+	evalCmd.Flags().StringVarP(&evalInventory, "inventory", "i", "inventory.yaml", "Path to inventory file (used with --host)")
+}
+
+// buildEvalContext detects the local or remote system context and merges config/host vars.
+func buildEvalContext(cmd *cobra.Command) (*core.SystemContext, func(), error) {
+	var ctx *core.SystemContext
+	closeFn := func() {}
+	var hostVars map[string]string
+
+	if evalHost != "" {
+		inv, err := inventory.LoadInventory(evalInventory)
+		if err != nil {
+			return nil, closeFn, err
+		}
+		host, ok := fleet.FindHost(inv, evalHost)
+		if !ok {
+			return nil, closeFn, fmt.Errorf("host '%s' not found in %s", evalHost, evalInventory)
+		}
+
+		tr, err := fleet.Connect(host)
+		if err != nil {
+			return nil, closeFn, fmt.Errorf("connection to %s failed: %w", host.Name, err)
+		}
+		closeFn = func() { tr.Close() }
+
+		ctx = core.NewSystemContext(false, tr)
+		ctx.FS = tr.GetFileSystem()
+		ctx.TargetUser = host.User
+		newFactCache().Detect(ctx, host.Name)
+		hostVars = host.Vars
+	} else {
+		ctx = core.NewSystemContext(false, transport.NewLocalTransport())
+		newFactCache().Detect(ctx, "")
+
+		// Same override as 'apply', so expressions see what apply would see
+		if data, err := os.ReadFile(consts.GetSystemProfilePath()); err == nil {
+			if err := yaml.Unmarshal(data, ctx); err != nil {
+				pterm.Warning.Printf("Failed to parse system profile: %v\n", err)
+			}
+		}
+	}
+
+	ctx.Vars = make(map[string]string)
+	// The configuration apply would load, unless --config names another
+	configFile, _ := defaultConfigFile()
+	if cmd.Flags().Changed("config") {
+		configFile, _ = cmd.Flags().GetString("config")
+	}
+	if _, err := os.Stat(configFile); err == nil {
+		decrypt, _ := cmd.Flags().GetBool("decrypt")
+		cfg, err := config.LoadConfigWith(configFile, config.LoadOptions{Decrypt: decrypt, Facts: newFactCache()})
+		if err != nil {
+			pterm.Warning.Printf("Failed to load config '%s': %v\n", configFile, err)
+		} else {
+			for k, v := range cfg.Vars {
+				ctx.Vars[k] = v
+			}
+		}
+	}
+	for k, v := range hostVars {
+		ctx.Vars[k] = v
+	}
+
+	return ctx, closeFn, nil
+}
+
+// evaluateInput renders a template or evaluates an expression and prints the result.
+// Returns false if evaluation failed.
+func evaluateInput(ctx *core.SystemContext, input string, asExpr bool) bool {
+	if asExpr {
+		result, err := core.EvaluateExpression(input, ctx)
+		if err != nil {
+			printEvalError(input, err)
+			return false
+		}
+		fmt.Println(secrets.Redact(formatEvalResult(result)))
+		if _, ok := result.(bool); !ok {
+			pterm.Warning.Printf("Result is %T; 'when' conditions must return a boolean.\n", result)
+		}
+		return true
+	}
+
+	rendered, err := core.ExecuteTemplate(input, ctx)
+	if err != nil {
+		printEvalError(input, err)
+		return false
+	}
+	fmt.Println(secrets.Redact(rendered))
+	return true
+}
+
+func formatEvalResult(result interface{}) string {
+	switch v := result.(type) {
+	case string:
+		return strconv.Quote(v)
+	case nil:
+		return "nil"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// templateErrPos matches positions in text/template errors: "template: veto:1:12: ..." (column is optional)
+var templateErrPos = regexp.MustCompile(`template: veto:(\d+)(?::(\d+))?: `)
+
+// evalErrorPosition extracts the 1-based line and 0-based column of an evaluation error.
+// Column is -1 when the error only carries a line number.
+func evalErrorPosition(err error) (int, int, bool) {
+	var fileErr *file.Error
+	if errors.As(err, &fileErr) && fileErr.Line > 0 {
+		return fileErr.Line, fileErr.Column, true
+	}
+
+	if m := templateErrPos.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		col := -1
+		if m[2] != "" {
+			col, _ = strconv.Atoi(m[2])
+		}
+		return line, col, true
+	}
+	return 0, 0, false
+}
+
+func printEvalError(input string, err error) {
+	line, col, ok := evalErrorPosition(err)
+	if !ok {
+		pterm.Error.Println(err)
+		return
+	}
+
+	var fileErr *file.Error
+	msg := err.Error()
+	if errors.As(err, &fileErr) {
+		msg = fileErr.Message
+	}
+
+	if col >= 0 {
+		pterm.Error.Printf("line %d, column %d: %s\n", line, col+1, msg)
+	} else {
+		pterm.Error.Printf("line %d: %s\n", line, msg)
+	}
+
+	lines := strings.Split(input, "\n")
+	if line-1 < len(lines) {
+		fmt.Fprintf(os.Stderr, "  %s\n", lines[line-1])
+		if col >= 0 {
+			fmt.Fprintf(os.Stderr, "  %s%s\n", strings.Repeat(" ", col), pterm.FgRed.Sprint("^"))
+		}
+	}
+}
+
+func runEvalREPL(ctx *core.SystemContext) {
+	pterm.DefaultHeader.Printf("Veto Eval REPL (%s)", ctx.Hostname)
+	pterm.Info.Println("Input containing '{{' is rendered as a template, anything else is evaluated as an expression.")
+	pterm.Info.Println("Commands: :facts, :vars, :help, :quit")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("veto> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		switch input {
+		case "":
+			continue
+		case ":q", ":quit", ":exit":
+			return
+		case ":help":
+			fmt.Println(`  {{ .Hardware.GPUVendor }}     render a template
+  Distro == "arch"              evaluate a 'when' expression
+  :facts                        show detected facts
+  :vars                         show loaded variables
+  :quit                         leave the REPL`)
+			continue
+		case ":facts":
+			data, _ := yaml.Marshal(ctx)
+			fmt.Print(string(data))
+			continue
+		case ":vars":
+			data, _ := yaml.Marshal(ctx.Vars)
+			fmt.Print(string(data))
+			continue
+		}
+
+		evaluateInput(ctx, input, !strings.Contains(input, "{{"))
+	}
+}
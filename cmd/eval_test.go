@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalErrorPosition(t *testing.T) {
+	ctx := &core.SystemContext{Context: context.Background(), Distro: "arch"}
+
+	// expr compile error carries line/column
+	_, err := core.EvaluateExpression(`Distro == `, ctx)
+	line, col, ok := evalErrorPosition(err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, 9, col)
+
+	// template execution error carries line/column
+	_, err = core.ExecuteTemplate("ok\n{{ .Missing.Field }}", ctx)
+	line, col, ok = evalErrorPosition(err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 11, col)
+
+	// template parse error only carries a line
+	_, err = core.ExecuteTemplate("{{ .Distro ", ctx)
+	line, col, ok = evalErrorPosition(err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, -1, col)
+}
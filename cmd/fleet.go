@@ -6,6 +6,7 @@ import (
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/fleet"
@@ -39,6 +40,7 @@ var factsCmd = &cobra.Command{
 			atomic.Error.Printf("Failed to load inventory: %v\n", err)
 			return
 		}
+		customFacts, _ := cmd.Flags().GetStringSlice("fact")
 
 		// 2. Gather Facts concurrently
 		type HostFact struct {
@@ -48,6 +50,8 @@ var factsCmd = &cobra.Command{
 			CPU      string
 			RAM      string
 			Status   string
+			FactAge  time.Duration
+			Custom   []string
 			Error    error
 		}
 
@@ -71,6 +75,7 @@ var factsCmd = &cobra.Command{
 		}
 
 		spinner, _ := atomic.DefaultSpinner.Start(fmt.Sprintf("Gathering facts from %d hosts...", len(inv.Hosts)))
+		cache := newFactCache()
 
 		for _, host := range inv.Hosts {
 			wg.Add(1)
@@ -121,7 +126,19 @@ var factsCmd = &cobra.Command{
 					}
 				}()
 
-				system.Detect(ctx)
+				cache.Detect(ctx, h.Name)
+
+				// Custom facts requested with --fact, failed ones show their error
+				custom := make([]string, len(customFacts))
+				for i, name := range customFacts {
+					if errMsg, failed := ctx.FactErrors[name]; failed {
+						custom[i] = "ERROR: " + errMsg
+					} else if v, ok := ctx.Facts[name]; ok {
+						custom[i] = fmt.Sprintf("%v", v)
+					} else {
+						custom[i] = "-"
+					}
+				}
 
 				results <- HostFact{
 					HostName: h.Name,
@@ -130,6 +147,8 @@ var factsCmd = &cobra.Command{
 					CPU:      ctx.Hardware.CPUModel,
 					RAM:      ctx.Hardware.RAMTotal,
 					Status:   "ONLINE",
+					FactAge:  ctx.FactAge(system.FactHardware),
+					Custom:   custom,
 				}
 			}(host)
 		}
@@ -140,8 +159,14 @@ var factsCmd = &cobra.Command{
 
 		// 3. Display Table
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "HOST\tSTATUS\tOS\tKERNEL\tCPU\tRAM")
-		fmt.Fprintln(w, "----\t------\t--\t------\t---\t---")
+		header := "HOST\tSTATUS\tOS\tKERNEL\tCPU\tRAM\tFACTS AGE"
+		divider := "----\t------\t--\t------\t---\t---\t---------"
+		for _, name := range customFacts {
+			header += "\t" + strings.ToUpper(name)
+			divider += "\t" + strings.Repeat("-", len(name))
+		}
+		fmt.Fprintln(w, header)
+		fmt.Fprintln(w, divider)
 
 		for res := range results {
 			statusIcon := "✅"
@@ -155,14 +180,19 @@ var factsCmd = &cobra.Command{
 				cpuDisplay = cpuDisplay[:27] + "..."
 			}
 
-			fmt.Fprintf(w, "%s\t%s %s\t%s\t%s\t%s\t%s\n",
+			fmt.Fprintf(w, "%s\t%s %s\t%s\t%s\t%s\t%s\t%s",
 				res.HostName,
 				statusIcon, res.Status,
 				res.OS,
 				res.Kernel,
 				cpuDisplay,
 				res.RAM,
+				res.FactAge.Truncate(time.Second),
 			)
+			for _, v := range res.Custom {
+				fmt.Fprintf(w, "\t%s", v)
+			}
+			fmt.Fprintln(w)
 		}
 		w.Flush()
 	},
@@ -237,6 +267,7 @@ func init() {
 	fleetCmd.AddCommand(factsCmd)
 	fleetCmd.AddCommand(execCmd)
 	fleetCmd.PersistentFlags().StringP("inventory", "i", "inventory.yaml", "Path to inventory file")
+	factsCmd.Flags().StringSlice("fact", nil, "Custom facts (from facts.d) to show as extra columns")
 	execCmd.Flags().IntP("concurrency", "C", 10, "Number of concurrent hosts")
 	execCmd.Flags().Bool("sudo", false, "Run with sudo privileges")
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/melih-ucgun/veto/internal/consts"
 	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/discovery"
+	"github.com/melih-ucgun/veto/internal/hub"
 	"github.com/melih-ucgun/veto/internal/system"
 	"github.com/melih-ucgun/veto/internal/transport"
 	"github.com/pterm/pterm"
@@ -18,19 +19,40 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+var importFrom string
+var importOutput string
+var importTarget string
+var importStowDotfiles bool
+
 var importCmd = &cobra.Command{
-	Use:   "import [output_file]",
+	Use:   "import [output_file | source_dir]",
 	Short: "Discover installed packages and services",
-	Long:  `Scans the system for explicitly installed packages and enabled services, and generates a Veto configuration file.`,
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Scans the system for explicitly installed packages and enabled services, and generates a Veto configuration file.
+
+With --from, migrates an existing dotfile manager tree instead:
+  veto import --from stow ~/dotfiles        # stow packages -> symlink resources
+  veto import --from chezmoi ~/.local/share/chezmoi   # dot_/private_/executable_/.tmpl -> file/template resources`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		nonInteractive, _ := cmd.Flags().GetBool("yes")
+
+		if importFrom != "" {
+			if len(args) == 0 {
+				pterm.Error.Printf("Source directory is required: veto import --from %s <dir>\n", importFrom)
+				os.Exit(1)
+			}
+			if err := RunDotfileImport(importFrom, args[0], importOutput, nonInteractive); err != nil {
+				pterm.Error.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		outputFile := "imported_system.yaml"
 		if len(args) > 0 {
 			outputFile = args[0]
 		}
 		// If called from CLI, we default to the argument or "imported_system.yaml"
-		nonInteractive, _ := cmd.Flags().GetBool("yes")
-
 		RunImportInteractive(outputFile, nonInteractive)
 	},
 }
@@ -267,7 +289,114 @@ func RunImportInteractive(outputFile string, nonInteractive bool) {
 	pterm.Info.Println("Review this file before running 'veto apply'!")
 }
 
+// RunDotfileImport migrates a GNU Stow or chezmoi tree into the active profile.
+// Files are copied into the profile's files/ directory and a reviewable config is written.
+func RunDotfileImport(from, sourceDir, outputFile string, nonInteractive bool) error {
+	pterm.DefaultHeader.Printf("Dotfile Import (%s)", from)
+
+	absSource, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(absSource); err != nil || !info.IsDir() {
+		return fmt.Errorf("source directory '%s' not found", sourceDir)
+	}
+
+	profileDir, err := filepath.Abs(filepath.Dir(activeProfilePath()))
+	if err != nil {
+		return err
+	}
+	home, _ := os.UserHomeDir()
+
+	importer := &discovery.DotfileImporter{
+		SourceDir:    absSource,
+		TargetDir:    home,
+		FilesDir:     filepath.Join(profileDir, consts.FilesDirName),
+		HomeDir:      home,
+		StowDotfiles: importStowDotfiles,
+	}
+
+	var result *discovery.DotfileImportResult
+	switch from {
+	case "stow":
+		// Stow's default target is the parent of the stow directory
+		importer.TargetDir = filepath.Dir(absSource)
+		if importTarget != "" {
+			importer.TargetDir = importTarget
+		}
+		result, err = importer.ImportStow()
+	case "chezmoi":
+		if importTarget != "" {
+			importer.TargetDir = importTarget
+		}
+		result, err = importer.ImportChezmoi()
+	default:
+		return fmt.Errorf("unsupported import source '%s' (supported: stow, chezmoi)", from)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, w := range importer.Warnings {
+		pterm.Warning.Println(w)
+	}
+
+	if len(result.Resources) == 0 {
+		pterm.Warning.Println("No dotfiles found. Nothing to save.")
+		return nil
+	}
+
+	if outputFile == "" {
+		outputFile = filepath.Join(profileDir, "imported_dotfiles.yaml")
+	}
+
+	if !nonInteractive {
+		pterm.Info.Printf("%d resources will be written to %s\n", len(result.Resources), outputFile)
+		confirm, _ := pterm.DefaultInteractiveConfirm.
+			WithDefaultText("Save the generated configuration?").
+			WithDefaultValue(true).
+			Show()
+		if !confirm {
+			pterm.Info.Println("Import cancelled. Nothing was written.")
+			return nil
+		}
+	}
+
+	copied, err := result.CopyFiles()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&config.Config{Resources: result.Resources})
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	pterm.Success.Printf("Copied %d files into %s\n", copied, importer.FilesDir)
+	pterm.Success.Printf("Configuration saved to %s (%d resources)\n", outputFile, len(result.Resources))
+	pterm.Info.Println("Review this file and add it to 'includes' before running 'veto apply'!")
+	return nil
+}
+
+// activeProfilePath returns the config path of the active profile, falling back to the system profile.
+func activeProfilePath() string {
+	manager := hub.NewRecipeManager("")
+	if activeRecipe, _ := manager.GetActive(); activeRecipe != "" {
+		if path, err := manager.GetRecipePath(activeRecipe); err == nil {
+			return path
+		}
+	}
+	return consts.GetSystemProfilePath()
+}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
 	importCmd.Flags().BoolP("yes", "y", false, "Import all without prompting")
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Migrate from a dotfile manager tree (stow, chezmoi)")
+	importCmd.Flags().StringVarP(&importOutput, "output", "o", "", "Output file for --from imports (default: <profile>/imported_dotfiles.yaml)")
+	importCmd.Flags().StringVar(&importTarget, "target", "", "Target directory the dotfiles deploy into (stow: parent of source dir, chezmoi: $HOME)")
+	importCmd.Flags().BoolVar(&importStowDotfiles, "dotfiles", false, "Stow --dotfiles mode: rename 'dot-' prefixes to '.'")
 }
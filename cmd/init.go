@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"atomicgo.dev/cursor"
 	"github.com/melih-ucgun/veto/internal/consts"
@@ -101,6 +102,7 @@ func displaySystemInfo(ctx *core.SystemContext) {
 	data := [][]string{
 		{"Kernel", ctx.Kernel},
 		{"Distro", ctx.Distro},
+		{"Family", ctx.Family},
 		{"Version", ctx.Version},
 		{"Init System", ctx.InitSystem},
 		{"Hostname", ctx.Hostname},
@@ -113,6 +115,9 @@ func displaySystemInfo(ctx *core.SystemContext) {
 		{"RAM", ctx.Hardware.RAMTotal},
 		{"GPU", ctx.Hardware.GPUVendor},
 		{"FS", ctx.FSInfo.RootFSType},
+		{"Virtualization", fmt.Sprintf("%s (container: %s)", ctx.Virtualization, ctx.Container)},
+		{"Session", strings.TrimSpace(fmt.Sprintf("%s %s", ctx.Env.SessionType, ctx.Env.Desktop))},
+		{"IP", ctx.Network.PrimaryIP},
 	}
 	pterm.DefaultTable.WithHasHeader(false).WithData(data).Render()
 }
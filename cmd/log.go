@@ -1,55 +1,99 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
-	"github.com/melih-ucgun/veto/internal/consts"
-	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/state"
 	"github.com/melih-ucgun/veto/internal/types"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
+var logSince string
+var logUntil string
+var logStatus []string
+var logResources []string
+var logGrep string
+var logLimit int
+var logOffset int
+var logFormat string
+
 var logCmd = &cobra.Command{
 	Use:   "log",
 	Short: "View transaction history",
+	Long: `Lists the transactions of the history, newest first. The filters narrow it down by
+time (--since/--until take a date, a time or an age such as 24h or 7d), status, resource
+and text in the diffs; with --resource or --grep only the matching changes are shown.
+--host reads the history of a fleet host. --format json, csv or markdown exports the
+result, e.g. to paste it into a change ticket.`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := loadStateManager()
+		filter, err := newLogFilter()
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		if err := checkLogFormat(); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+
+		manager, done, err := loadStateManager(cmd)
 		if err != nil {
 			pterm.Error.Printf("Failed to load state: %v\n", err)
 			return
 		}
+		defer done()
+
+		// Latest first
+		history := manager.GetTransactions()
+		var txs []types.Transaction
+		for i := len(history) - 1; i >= 0; i-- {
+			if tx, ok := filter.apply(history[i]); ok {
+				txs = append(txs, tx)
+			}
+		}
+		total := len(txs)
+		txs = paginate(txs, logOffset, logLimit)
+
+		if logFormat != "table" {
+			if err := exportLog(os.Stdout, logFormat, txs); err != nil {
+				pterm.Error.Printf("Export failed: %v\n", err)
+				done()
+				os.Exit(1)
+			}
+			return
+		}
 
 		pterm.DefaultHeader.WithBackgroundStyle(pterm.NewStyle(pterm.BgCyan)).Println("Transaction Log")
+		if len(txs) == 0 {
+			pterm.Info.Println("No transactions found.")
+			return
+		}
 
 		data := [][]string{
 			{"ID", "Time", "Status", "Changes"},
 		}
-
-		// Reverse iteration for latest first
-		if manager.Current != nil {
-			for i := len(manager.Current.History) - 1; i >= 0; i-- {
-				tx := manager.Current.History[i]
-				status := tx.Status
-				if status == "success" {
-					status = pterm.FgGreen.Sprint(status)
-				} else if status == "failed" {
-					status = pterm.FgRed.Sprint(status)
-				}
-
-				data = append(data, []string{
-					tx.ID[:8],
-					tx.Timestamp.Format(time.RFC822),
-					status,
-					fmt.Sprintf("%d", len(tx.Changes)),
-				})
-			}
+		for _, tx := range txs {
+			data = append(data, []string{
+				shortID(tx.ID),
+				tx.Timestamp.Format(time.RFC822),
+				colorStatus(tx.Status),
+				fmt.Sprintf("%d", len(tx.Changes)),
+			})
 		}
-
 		pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+		if len(txs) < total {
+			pterm.Info.Printf("Showing %d-%d of %d transactions (--offset, --limit).\n", logOffset+1, logOffset+len(txs), total)
+		}
 	},
 }
 
@@ -57,29 +101,34 @@ var logCmd = &cobra.Command{
 var showCmd = &cobra.Command{
 	Use:   "show <transaction_id>",
 	Short: "Show detailed information about a specific transaction",
+	Long:  `Shows a transaction; any unique prefix of its ID will do.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := loadStateManager()
+		if err := checkLogFormat(); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+
+		manager, done, err := loadStateManager(cmd)
 		if err != nil {
 			pterm.Error.Printf("Failed to load state: %v\n", err)
 			return
 		}
+		defer done()
 
-		targetID := args[0]
-		var foundTx *types.Transaction
-
-		// Find transaction (support prefix matching)
-		if manager.Current != nil {
-			for _, tx := range manager.Current.History {
-				if strings.HasPrefix(tx.ID, targetID) {
-					foundTx = &tx
-					break
-				}
-			}
+		_, foundTx, err := manager.FindTransaction(args[0])
+		if err != nil {
+			pterm.Error.Println(err)
+			done()
+			os.Exit(1)
 		}
 
-		if foundTx == nil {
-			pterm.Error.Printf("Transaction '%s' not found.\n", targetID)
+		if logFormat != "table" {
+			if err := exportLog(os.Stdout, logFormat, []types.Transaction{foundTx}); err != nil {
+				pterm.Error.Printf("Export failed: %v\n", err)
+				done()
+				os.Exit(1)
+			}
 			return
 		}
 
@@ -93,6 +142,9 @@ var showCmd = &cobra.Command{
 			statusStyle = pterm.NewStyle(pterm.FgRed)
 		}
 		pterm.Info.Printf("Status: %s\n", statusStyle.Sprint(foundTx.Status))
+		if len(foundTx.Reverts) > 0 {
+			pterm.Info.Printf("Reverts: %s\n", strings.Join(foundTx.Reverts, ", "))
+		}
 		pterm.Println()
 
 		pterm.DefaultSection.Println("Changes")
@@ -110,32 +162,359 @@ var showCmd = &cobra.Command{
 			if change.BackupPath != "" {
 				pterm.Printf("  Backup: %s\n", change.BackupPath)
 			}
+			printDiff(change.Diff)
+			pterm.Println()
+		}
+	},
+}
 
-			if change.Diff != "" {
-				pterm.Println(pterm.FgGray.Sprint("  Diff:"))
-				// Simple indentation for diff
-				lines := strings.Split(change.Diff, "\n")
-				for _, line := range lines {
-					if strings.HasPrefix(line, "+") {
-						pterm.Print(pterm.FgGreen.Sprintf("    %s\n", line))
-					} else if strings.HasPrefix(line, "-") {
-						pterm.Print(pterm.FgRed.Sprintf("    %s\n", line))
-					} else {
-						pterm.Print(pterm.FgGray.Sprintf("    %s\n", line))
-					}
-				}
+// logDiffCmd summarizes what changed between two transactions
+var logDiffCmd = &cobra.Command{
+	Use:   "diff <from_id> <to_id>",
+	Short: "Show what changed between two transactions",
+	Long: `Lists the resources changed by the transactions after <from_id> up to and including
+<to_id>, with their diffs. --format json, csv or markdown exports those transactions.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkLogFormat(); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+
+		manager, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			return
+		}
+		defer done()
+
+		if err := logDiff(manager, args[0], args[1]); err != nil {
+			pterm.Error.Println(err)
+			done()
+			os.Exit(1)
+		}
+	},
+}
+
+// logDiff prints the changes of the transactions after fromID up to toID. The IDs may
+// be given in either order.
+func logDiff(manager *state.Manager, fromID, toID string) error {
+	from, fromTx, err := manager.FindTransaction(fromID)
+	if err != nil {
+		return err
+	}
+	to, toTx, err := manager.FindTransaction(toID)
+	if err != nil {
+		return err
+	}
+	if to < from {
+		from, to = to, from
+		fromTx, toTx = toTx, fromTx
+	}
+	txs := manager.GetTransactions()[from+1 : to+1]
+	if logFormat != "table" {
+		return exportLog(os.Stdout, logFormat, txs)
+	}
+
+	pterm.DefaultHeader.WithBackgroundStyle(pterm.NewStyle(pterm.BgCyan)).Printf("Changes %s..%s", shortID(fromTx.ID), shortID(toTx.ID))
+	pterm.Println()
+	resources := diffResources(txs)
+	if len(resources) == 0 {
+		pterm.Info.Println("No changes between these transactions.")
+		return nil
+	}
+
+	data := [][]string{{"Resource", "Changes", "Last Action", "Transactions"}}
+	for _, r := range resources {
+		data = append(data, []string{r.id, fmt.Sprintf("%d", len(r.changes)), r.changes[len(r.changes)-1].Action, strings.Join(r.txIDs, ", ")})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+	pterm.Println()
+
+	for _, r := range resources {
+		for i, change := range r.changes {
+			if change.Diff == "" {
+				continue
 			}
+			pterm.Println(pterm.Bold.Sprintf("• %s (%s, %s)", r.id, change.Action, r.txIDs[i]))
+			printDiff(change.Diff)
 			pterm.Println()
 		}
-	},
+	}
+	return nil
+}
+
+// resourceChanges are the changes a range of transactions made to one resource.
+type resourceChanges struct {
+	id      string
+	changes []types.TransactionChange
+	txIDs   []string // Short ID of the transaction of each change
+}
+
+// diffResources groups the changes of txs (oldest first) by resource, in the order the
+// resources were first changed.
+func diffResources(txs []types.Transaction) []*resourceChanges {
+	var resources []*resourceChanges
+	byID := make(map[string]*resourceChanges)
+	for _, tx := range txs {
+		for _, change := range tx.Changes {
+			id := state.ResourceID(change.Type, change.Name)
+			r, ok := byID[id]
+			if !ok {
+				r = &resourceChanges{id: id}
+				byID[id] = r
+				resources = append(resources, r)
+			}
+			r.changes = append(r.changes, change)
+			r.txIDs = append(r.txIDs, shortID(tx.ID))
+		}
+	}
+	return resources
+}
+
+// logFilter selects transactions, and the changes within them, for 'veto log'.
+type logFilter struct {
+	since, until time.Time
+	statuses     []string
+	resources    []string // type, type:name or name; names may be globs
+	grep         *regexp.Regexp
+}
+
+// newLogFilter builds the filter of the log flags.
+func newLogFilter() (logFilter, error) {
+	f := logFilter{statuses: logStatus, resources: logResources}
+	if logLimit < 0 {
+		return f, fmt.Errorf("invalid --limit %d: must not be negative", logLimit)
+	}
+	if logOffset < 0 {
+		return f, fmt.Errorf("invalid --offset %d: must not be negative", logOffset)
+	}
+	now := time.Now()
+	var err error
+	if logSince != "" {
+		if f.since, err = parseLogTime(logSince, now); err != nil {
+			return f, fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if logUntil != "" {
+		if f.until, err = parseLogTime(logUntil, now); err != nil {
+			return f, fmt.Errorf("invalid --until: %w", err)
+		}
+		// A date means the end of that day
+		if _, err := time.ParseInLocation(logDateLayout, logUntil, time.Local); err == nil {
+			f.until = f.until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	if logGrep != "" {
+		if f.grep, err = regexp.Compile(logGrep); err != nil {
+			return f, fmt.Errorf("invalid --grep: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// logDateLayout is the layout of a date-only --since or --until.
+const logDateLayout = "2006-01-02"
+
+// parseLogTime accepts an age before now (30m, 24h, 7d), a date (2006-01-02), a local
+// time (2006-01-02 15:04) or an RFC 3339 timestamp.
+func parseLogTime(s string, now time.Time) (time.Time, error) {
+	if age, err := state.ParseAge(s); err == nil {
+		return now.Add(-age), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", logDateLayout} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' is neither an age such as 7d nor a date such as 2006-01-02", s)
+}
+
+// apply reports whether tx passes the filter, and returns it with only the matching
+// changes when the filter looks at changes.
+func (f logFilter) apply(tx types.Transaction) (types.Transaction, bool) {
+	if !f.since.IsZero() && tx.Timestamp.Before(f.since) {
+		return tx, false
+	}
+	if !f.until.IsZero() && tx.Timestamp.After(f.until) {
+		return tx, false
+	}
+	if len(f.statuses) > 0 && !containsString(f.statuses, tx.Status) {
+		return tx, false
+	}
+	if len(f.resources) == 0 && f.grep == nil {
+		return tx, true
+	}
+
+	var changes []types.TransactionChange
+	for _, change := range tx.Changes {
+		if f.matchChange(change) {
+			changes = append(changes, change)
+		}
+	}
+	tx.Changes = changes
+	return tx, len(changes) > 0
+}
+
+func (f logFilter) matchChange(change types.TransactionChange) bool {
+	if len(f.resources) > 0 {
+		matched := false
+		for _, r := range f.resources {
+			if matchResource(r, change) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return f.grep == nil || f.grep.MatchString(change.Diff) || f.grep.MatchString(change.Detail)
+}
+
+// matchResource matches pattern against a change: "type:name" (the name may be a glob)
+// or a bare word that is either the type or the name.
+func matchResource(pattern string, change types.TransactionChange) bool {
+	if resType, name, ok := strings.Cut(pattern, ":"); ok && resType == change.Type {
+		matched, _ := path.Match(name, change.Name)
+		return matched || name == change.Name
+	}
+	if pattern == change.Type {
+		return true
+	}
+	matched, _ := path.Match(pattern, change.Name)
+	return matched || pattern == change.Name
+}
+
+// paginate skips the first offset transactions and keeps at most limit (0 for all).
+func paginate(txs []types.Transaction, offset, limit int) []types.Transaction {
+	if offset >= len(txs) {
+		return nil
+	}
+	txs = txs[offset:]
+	if limit > 0 && limit < len(txs) {
+		txs = txs[:limit]
+	}
+	return txs
+}
+
+func checkLogFormat() error {
+	switch logFormat {
+	case "table", "json", "csv", "markdown":
+		return nil
+	}
+	return fmt.Errorf("unknown format '%s' (table, json, csv or markdown)", logFormat)
+}
+
+// exportLog writes txs to w as json, csv (one row per change) or markdown.
+func exportLog(w io.Writer, format string, txs []types.Transaction) error {
+	switch format {
+	case "json":
+		if txs == nil {
+			txs = []types.Transaction{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(txs)
+	case "csv":
+		return exportCSV(w, txs)
+	case "markdown":
+		return exportMarkdown(w, txs)
+	}
+	return fmt.Errorf("unknown format '%s'", format)
+}
+
+func exportCSV(w io.Writer, txs []types.Transaction) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"transaction", "time", "status", "type", "name", "action", "target", "detail", "diff"})
+	for _, tx := range txs {
+		row := []string{tx.ID, tx.Timestamp.Format(time.RFC3339), tx.Status}
+		if len(tx.Changes) == 0 {
+			cw.Write(append(row, "", "", "", "", "", ""))
+		}
+		for _, c := range tx.Changes {
+			cw.Write(append(row, c.Type, c.Name, c.Action, c.Target, c.Detail, c.Diff))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportMarkdown(w io.Writer, txs []types.Transaction) error {
+	var b strings.Builder
+	for i, tx := range txs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "### Transaction %s\n\n", tx.ID)
+		fmt.Fprintf(&b, "- **Time:** %s\n- **Status:** %s\n", tx.Timestamp.Format(time.RFC3339), tx.Status)
+		if len(tx.Reverts) > 0 {
+			fmt.Fprintf(&b, "- **Reverts:** %s\n", strings.Join(tx.Reverts, ", "))
+		}
+		if len(tx.Changes) == 0 {
+			b.WriteString("\nNo changes recorded.\n")
+			continue
+		}
+
+		b.WriteString("\n| Type | Name | Action | Target |\n|---|---|---|---|\n")
+		for _, c := range tx.Changes {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", markdownCell(c.Type), markdownCell(c.Name), markdownCell(c.Action), markdownCell(c.Target))
+		}
+		for _, c := range tx.Changes {
+			if c.Diff == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "\n`%s:%s`\n\n```diff\n%s\n```\n", c.Type, c.Name, strings.TrimRight(c.Diff, "\n"))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes s for a markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func colorStatus(status string) string {
+	if status == "success" {
+		return pterm.FgGreen.Sprint(status)
+	} else if status == "failed" {
+		return pterm.FgRed.Sprint(status)
+	}
+	return status
+}
+
+func printDiff(diff string) {
+	if diff == "" {
+		return
+	}
+	pterm.Println(pterm.FgGray.Sprint("  Diff:"))
+	// Simple indentation for diff
+	lines := strings.Split(diff, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "+") {
+			pterm.Print(pterm.FgGreen.Sprintf("    %s\n", line))
+		} else if strings.HasPrefix(line, "-") {
+			pterm.Print(pterm.FgRed.Sprintf("    %s\n", line))
+		} else {
+			pterm.Print(pterm.FgGray.Sprintf("    %s\n", line))
+		}
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(logCmd)
-	logCmd.AddCommand(showCmd)
-}
+	logCmd.AddCommand(showCmd, logDiffCmd)
+	addHostFlags(logCmd)
 
-func loadStateManager() (*state.Manager, error) {
-	configPath := consts.GetStateFilePath()
-	return state.NewManager(configPath, &core.RealFS{})
+	logCmd.PersistentFlags().StringVar(&logFormat, "format", "table", "Output format: table, json, csv or markdown")
+	logCmd.Flags().StringVar(&logSince, "since", "", "Only transactions since this date or age (2024-05-01, 24h, 7d)")
+	logCmd.Flags().StringVar(&logUntil, "until", "", "Only transactions until this date or age")
+	logCmd.Flags().StringSliceVar(&logStatus, "status", nil, "Only transactions with this status (success, failed, reverted)")
+	logCmd.Flags().StringSliceVar(&logResources, "resource", nil, "Only changes to this resource: type, name or type:name (globs allowed)")
+	logCmd.Flags().StringVar(&logGrep, "grep", "", "Only changes whose diff or detail matches this regular expression")
+	logCmd.Flags().IntVar(&logLimit, "limit", 0, "Show at most this many transactions")
+	logCmd.Flags().IntVar(&logOffset, "offset", 0, "Skip this many of the newest transactions")
 }
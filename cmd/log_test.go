@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogFilter(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tx := types.Transaction{ID: "tx1", Timestamp: now.Add(-48 * time.Hour), Status: "success", Changes: []types.TransactionChange{
+		{Type: "file", Name: "/etc/motd", Action: "applied", Diff: "-old\n+welcome"},
+		{Type: "package", Name: "htop", Action: "applied"},
+	}}
+
+	_, ok := logFilter{since: now.Add(-24 * time.Hour)}.apply(tx)
+	assert.False(t, ok)
+	_, ok = logFilter{until: now.Add(-24 * time.Hour), statuses: []string{"success"}}.apply(tx)
+	assert.True(t, ok)
+	_, ok = logFilter{statuses: []string{"failed"}}.apply(tx)
+	assert.False(t, ok)
+
+	// Change filters narrow the transaction down to the matching changes
+	got, ok := logFilter{resources: []string{"file:/etc/*"}}.apply(tx)
+	assert.True(t, ok)
+	assert.Len(t, got.Changes, 1)
+	got, ok = logFilter{resources: []string{"htop"}}.apply(tx)
+	assert.True(t, ok)
+	assert.Equal(t, "package", got.Changes[0].Type)
+	got, ok = logFilter{grep: regexp.MustCompile(`welc`)}.apply(tx)
+	assert.True(t, ok)
+	assert.Equal(t, "/etc/motd", got.Changes[0].Name)
+	_, ok = logFilter{resources: []string{"service"}}.apply(tx)
+	assert.False(t, ok)
+	assert.Len(t, tx.Changes, 2)
+}
+
+func TestParseLogTime(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	got, err := parseLogTime("7d", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-7*24*time.Hour), got)
+
+	got, err = parseLogTime("2024-05-01", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local), got)
+
+	_, err = parseLogTime("yesterday", now)
+	assert.Error(t, err)
+}
+
+func TestPaginate(t *testing.T) {
+	txs := []types.Transaction{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	assert.Len(t, paginate(txs, 0, 0), 3)
+	assert.Equal(t, "b", paginate(txs, 1, 1)[0].ID)
+	assert.Len(t, paginate(txs, 2, 5), 1)
+	assert.Empty(t, paginate(txs, 3, 0))
+}
+
+func TestDiffResources(t *testing.T) {
+	txs := []types.Transaction{
+		{ID: "aaaaaaaa1", Changes: []types.TransactionChange{{Type: "file", Name: "/etc/motd", Action: "applied"}}},
+		{ID: "bbbbbbbb2", Changes: []types.TransactionChange{
+			{Type: "package", Name: "htop", Action: "applied"},
+			{Type: "file", Name: "/etc/motd", Action: "reverted"},
+		}},
+	}
+	resources := diffResources(txs)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, "file:/etc/motd", resources[0].id)
+	assert.Equal(t, []string{"aaaaaaaa", "bbbbbbbb"}, resources[0].txIDs)
+	assert.Equal(t, "reverted", resources[0].changes[1].Action)
+}
+
+func TestExportLog(t *testing.T) {
+	txs := []types.Transaction{{ID: "tx1", Timestamp: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), Status: "success", Changes: []types.TransactionChange{
+		{Type: "file", Name: "/etc/a|b", Action: "applied", Diff: "+line"},
+		{Type: "package", Name: "htop", Action: "applied"},
+	}}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, exportLog(&buf, "json", txs))
+	var decoded []types.Transaction
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, txs, decoded)
+
+	buf.Reset()
+	assert.NoError(t, exportLog(&buf, "csv", txs))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, []string{"tx1", "2024-05-01T08:00:00Z", "success", "file", "/etc/a|b", "applied", "", "", "+line"}, rows[1])
+
+	buf.Reset()
+	assert.NoError(t, exportLog(&buf, "markdown", txs))
+	assert.Contains(t, buf.String(), "### Transaction tx1")
+	assert.Contains(t, buf.String(), `| file | /etc/a\|b | applied |  |`)
+	assert.Contains(t, buf.String(), "```diff\n+line\n```")
+
+	assert.Error(t, exportLog(&buf, "xml", txs))
+}
+
+func TestNewLogFilter(t *testing.T) {
+	defer func() { logLimit, logOffset, logUntil = 0, 0, "" }()
+
+	logOffset = -1
+	_, err := newLogFilter()
+	assert.Error(t, err)
+	logOffset, logLimit = 0, -5
+	_, err = newLogFilter()
+	assert.Error(t, err)
+
+	// A date-only --until includes that whole day
+	logLimit, logUntil = 0, "2024-05-01"
+	f, err := newLogFilter()
+	assert.NoError(t, err)
+	_, ok := f.apply(types.Transaction{Timestamp: time.Date(2024, 5, 1, 18, 30, 0, 0, time.Local)})
+	assert.True(t, ok)
+	_, ok = f.apply(types.Transaction{Timestamp: time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local)})
+	assert.False(t, ok)
+}
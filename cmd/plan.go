@@ -39,12 +39,16 @@ var planCmd = &cobra.Command{
 
 		// 2. Load Config
 		decrypt, _ := cmd.Flags().GetBool("decrypt")
-		cfg, err := config.LoadConfig(configPath, decrypt)
+		cfg, err := config.LoadConfigWith(configPath, config.LoadOptions{Decrypt: decrypt, Facts: newFactCache()})
 		if err != nil {
 			spinner.Fail("Failed to load config: " + err.Error())
 			os.Exit(1)
 		}
 		spinner.Success("Configuration loaded")
+		ctx.Vars = cfg.Vars
+		if decrypt {
+			ctx.Keyring = config.Keyring()
+		}
 
 		// 3. Sort Resources (Dependency Graph)
 		// We flatten layers for a simple sequential plan or keep layers?
@@ -2,85 +2,319 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/google/uuid"
+	"github.com/melih-ucgun/veto/internal/config"
 	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/resource"
 	"github.com/melih-ucgun/veto/internal/state"
-	"github.com/melih-ucgun/veto/internal/system"
-	"github.com/melih-ucgun/veto/internal/transport"
 	"github.com/melih-ucgun/veto/internal/types"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
+var rollbackTo string
+var rollbackOnly []string
+var rollbackDryRun bool
+var rollbackYes bool
+var rollbackCount int
+
+// rollbackStep is one transaction to roll back and the changes of it to revert,
+// in the order they are reverted.
+type rollbackStep struct {
+	tx      types.Transaction
+	changes []types.TransactionChange
+	partial bool // Some changes of tx are left alone
+}
+
 var rollbackCmd = &cobra.Command{
-	Use:   "rollback [count]",
-	Short: "Rollback the last N transactions",
-	Args:  cobra.MaximumNArgs(1),
+	Use:   "rollback [count | txid]",
+	Short: "Rollback the last N transactions, or a given one",
+	Long: `Reverts the last N transactions (1 by default), the transaction with the given ID
+(prefix), or with --to every transaction after the given one. A number that is also the
+start of a transaction ID is refused; pass --count or a longer ID then. Each revert restores the
+state recorded before the change. The rollback is recorded as a new transaction, so
+running 'veto rollback' again undoes it.
+
+  veto rollback 3
+  veto rollback --to 1a2b3c4d
+  veto rollback 1a2b3c4d --only package:htop --dry-run`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		count := 1
-		if len(args) > 0 {
-			fmt.Sscanf(args[0], "%d", &count)
+		if rollbackTo != "" && (len(args) > 0 || rollbackCount > 0) {
+			pterm.Error.Println("--to can't be combined with a count or transaction ID")
+			os.Exit(1)
+		}
+		if rollbackCount > 0 && len(args) > 0 {
+			pterm.Error.Println("--count can't be combined with a count or transaction ID")
+			os.Exit(1)
+		}
+		for _, id := range rollbackOnly {
+			if _, _, err := state.ParseResourceID(id); err != nil {
+				pterm.Error.Println(err)
+				os.Exit(1)
+			}
 		}
 
-		// Initialize State Manager
-		fs := core.RealFS{}
-		mgr, err := state.NewManager(consts.GetStateFilePath(), &fs) // Use default path
+		// Initialize State Manager and System Context, on the fleet host for --host
+		mgr, ctx, done, err := loadStateContext(cmd)
 		if err != nil {
 			pterm.Error.Printf("Failed to load state: %v\n", err)
 			return
 		}
+		defer done()
+		ctx.Keyring = config.Keyring() // Encrypted (.enc) backups
 
-		txs := mgr.GetTransactions()
-		if len(txs) == 0 {
-			pterm.Info.Println("No history to rollback.")
-			return
+		unlock := func() {}
+		if !rollbackDryRun {
+			unlock, err = lockState(mgr, "rollback")
+			if err != nil {
+				pterm.Error.Println(err)
+				return
+			}
 		}
+		defer unlock()
 
-		// Determine transactions to rollback (LIFO)
-		if count > len(txs) {
-			count = len(txs)
+		selected, err := selectRollback(mgr, args)
+		if err != nil {
+			pterm.Error.Println(err)
+			unlock()
+			os.Exit(1)
 		}
-
-		// Get last N transactions, reversed
-		toRollback := make([]types.Transaction, 0, count)
-		for i := len(txs) - 1; i >= len(txs)-count; i-- {
-			toRollback = append(toRollback, txs[i])
+		steps := planRollback(selected, rollbackOnly)
+		if len(steps) == 0 {
+			pterm.Info.Println("Nothing to rollback.")
+			return
 		}
 
-		pterm.DefaultHeader.Printf("Rolling Back %d Transactions", count)
+		// Preview
+		pterm.DefaultHeader.Printf("Rolling Back %d Transactions", len(steps))
+		total := 0
+		for _, step := range steps {
+			pterm.Println(pterm.FgCyan.Sprintf("Transaction %s (%s)", step.tx.ID, step.tx.Timestamp.Format(time.RFC822)))
+			for _, change := range step.changes {
+				pterm.Printf("  %s %s \"%s\" %s\n",
+					pterm.FgYellow.Sprint("~"),
+					pterm.Bold.Sprint(change.Type),
+					change.Name,
+					describeRevert(change))
+				total++
+			}
+		}
+		pterm.Println()
 
-		// Initialize System Context
-		ctx := core.NewSystemContext(false, transport.NewLocalTransport())
-		system.Detect(ctx)
+		if rollbackDryRun {
+			pterm.Info.Printf("Dry run: %d change(s) would be reverted.\n", total)
+			return
+		}
+		if !rollbackYes {
+			ok, _ := pterm.DefaultInteractiveConfirm.
+				WithDefaultText(fmt.Sprintf("Revert %d change(s)?", total)).
+				WithDefaultValue(false).
+				Show()
+			if !ok {
+				pterm.Info.Println("Rollback cancelled.")
+				return
+			}
+		}
 
-		// Execute Rollback
-		for _, tx := range toRollback {
-			pterm.Info.Printf("Rolling back transaction: %s\n", tx.ID)
+		// The rollback is a transaction of its own; files are backed up before they
+		// are restored so that it can be rolled back too
+		rollbackTx := types.Transaction{
+			ID:        uuid.New().String(),
+			Timestamp: time.Now(),
+			Status:    "success",
+		}
+		ctx.TxID = rollbackTx.ID
+		ctx.BackupManager = openBackupManager(cmd, ctx.FS)
 
-			// Revert changes in reverse order within the transaction
-			for i := len(tx.Changes) - 1; i >= 0; i-- {
-				change := tx.Changes[i]
+		var reverted []string
+		for _, step := range steps {
+			pterm.Info.Printf("Rolling back transaction: %s\n", step.tx.ID)
+			complete := !step.partial
+			for _, change := range step.changes {
 				pterm.Info.Printf("  Reverting: %s %s (%s)\n", change.Action, change.Name, change.Type)
 
-				if err := performRollback(change, ctx); err != nil {
+				undo, err := performRollback(change, ctx)
+				if err != nil {
 					pterm.Error.Printf("Failed to revert: %v\n", err)
-				} else {
-					pterm.Success.Println("Reverted.")
+					rollbackTx.Status = "failed"
+					complete = false
+					continue
 				}
+				pterm.Success.Println("Reverted.")
+				rollbackTx.Changes = append(rollbackTx.Changes, undo)
+			}
+			rollbackTx.Reverts = append(rollbackTx.Reverts, step.tx.ID)
+			if complete {
+				reverted = append(reverted, step.tx.ID)
 			}
 		}
+
+		if len(rollbackTx.Changes) > 0 {
+			if err := mgr.RecordRollback(rollbackTx, reverted); err != nil {
+				pterm.Warning.Printf("Failed to record the rollback: %v\n", err)
+			} else {
+				pterm.Info.Printf("Recorded as transaction %s; 'veto rollback' undoes it.\n", rollbackTx.ID)
+			}
+		}
+		if rollbackTx.Status == "failed" {
+			unlock()
+			done()
+			os.Exit(1)
+		}
 	},
 }
 
-func performRollback(change types.TransactionChange, ctx *core.SystemContext) error {
+// selectRollback returns the transactions to roll back, newest first: those after
+// --to, the one whose ID is given, or the last N.
+func selectRollback(mgr *state.Manager, args []string) ([]types.Transaction, error) {
+	txs := mgr.GetTransactions()
+
+	if rollbackTo != "" {
+		i, _, err := mgr.FindTransaction(rollbackTo)
+		if err != nil {
+			return nil, err
+		}
+		var selected []types.Transaction
+		for j := len(txs) - 1; j > i; j-- {
+			selected = append(selected, txs[j])
+		}
+		return selected, nil
+	}
+
+	count := 1
+	if rollbackCount > 0 {
+		count = rollbackCount
+	} else if len(args) > 0 {
+		n, err := rollbackArg(txs, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			_, tx, err := mgr.FindTransaction(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return []types.Transaction{tx}, nil
+		}
+		count = n
+	}
+
+	// Get last N transactions, reversed
+	if count > len(txs) {
+		count = len(txs)
+	}
+	selected := make([]types.Transaction, 0, count)
+	for i := len(txs) - 1; i >= len(txs)-count; i-- {
+		selected = append(selected, txs[i])
+	}
+	return selected, nil
+}
+
+// rollbackArg tells whether arg is a count (returned) or a transaction ID (0). A
+// number is a count unless it starts a transaction ID and exceeds the history, which no
+// count sensibly does; one that could be either is an error.
+func rollbackArg(txs []types.Transaction, arg string) (int, error) {
+	isID := false
+	for _, tx := range txs {
+		if strings.HasPrefix(tx.ID, arg) {
+			isID = true
+			break
+		}
+	}
+	n, err := strconv.Atoi(arg)
+	switch {
+	case err != nil || (isID && n > len(txs)):
+		return 0, nil
+	case isID:
+		return 0, fmt.Errorf("'%s' is both a count and the start of a transaction ID; use --count %s or a longer ID", arg, arg)
+	case n < 1:
+		return 0, fmt.Errorf("invalid count %d", n)
+	}
+	return n, nil
+}
+
+// planRollback picks the changes of each transaction to revert, in reverse order.
+// State-only changes are skipped; with only, so is every resource not listed.
+func planRollback(txs []types.Transaction, only []string) []rollbackStep {
+	var steps []rollbackStep
+	for _, tx := range txs {
+		step := rollbackStep{tx: tx}
+		for i := len(tx.Changes) - 1; i >= 0; i-- {
+			change := tx.Changes[i]
+			if types.IsStateAction(change.Action) {
+				continue
+			}
+			if len(only) > 0 && !containsString(only, state.ResourceID(change.Type, change.Name)) {
+				step.partial = true
+				continue
+			}
+			step.changes = append(step.changes, change)
+		}
+		if len(step.changes) > 0 {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// describeRevert says what reverting change will do, going by the state recorded
+// before it.
+func describeRevert(change types.TransactionChange) string {
+	b := change.Before
+	if b == nil {
+		if change.BackupPath != "" {
+			return pterm.FgYellow.Sprint("will be restored from backup")
+		}
+		return pterm.FgGray.Sprint("will be reverted (no prior state recorded, best effort)")
+	}
+	for _, key := range []string{"exists", "installed", "cloned"} {
+		if v, ok := b[key].(bool); ok && !v {
+			return pterm.FgRed.Sprint("will be removed (absent before)")
+		}
+	}
+	if v, ok := b["set"].(bool); ok && !v {
+		return pterm.FgRed.Sprint("will be reset (unset before)")
+	}
+
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, b[k]))
+	}
+	return pterm.FgYellow.Sprint("will be restored to " + strings.Join(pairs, ", "))
+}
+
+// performRollback reverts change and returns the change recorded for it in the
+// rollback transaction. That one holds the state before the revert, and a backup of
+// the file or directory for resources that keep one, so the rollback can be reverted
+// as well.
+func performRollback(change types.TransactionChange, ctx *core.SystemContext) (types.TransactionChange, error) {
 	// 1. Identify Resource
 	resType := change.Type
 	resName := change.Name
 
 	if resType == "" || resName == "" {
-		return fmt.Errorf("invalid resource info")
+		return change, fmt.Errorf("invalid resource info")
 	}
 
 	// 2. Create Resource Instance
@@ -88,22 +322,69 @@ func performRollback(change types.TransactionChange, ctx *core.SystemContext) er
 	if change.BackupPath != "" {
 		params["backup_path"] = change.BackupPath
 	}
+	if change.Target != "" && change.Target != resName {
+		// The target differs from the name only when it came from the path parameter
+		params["path"] = change.Target
+	}
 
 	res, err := resource.CreateResourceWithParams(resType, resName, params, ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create resource factory: %w", err)
+		return change, fmt.Errorf("failed to create resource factory: %w", err)
 	}
 
 	// 3. Cast to Revertable
 	revertable, ok := res.(core.Revertable)
 	if !ok {
-		return fmt.Errorf("resource %s does not support rollback", resType)
+		return change, fmt.Errorf("resource %s does not support rollback", resType)
+	}
+
+	// 4. Hand back the state captured before the change, so it is restored exactly.
+	// It also tells resources like git where they live.
+	s, canSnapshot := res.(core.Snapshotter)
+	if canSnapshot && change.Before != nil {
+		s.SetSnapshot(core.Snapshot(change.Before))
+	}
+
+	// 5. Record the current state for the rollback transaction
+	undo := types.TransactionChange{
+		Type:   resType,
+		Name:   resName,
+		Action: types.ActionReverted,
+		Target: change.Target,
+	}
+	if canSnapshot {
+		if snap, err := s.Snapshot(ctx); err == nil {
+			undo.Before = snap
+		} else {
+			pterm.Warning.Printf("Failed to capture the state of %s: %v\n", resName, err)
+		}
+	}
+	if _, ok := res.(interface{ GetBackupPath() string }); ok {
+		// Files are copied, directories kept as a tree. Resources installing into a
+		// directory name it in their snapshot.
+		path := change.Target
+		for _, key := range []string{"dir", "dest"} {
+			if dir, _ := undo.Before[key].(string); dir != "" {
+				path = dir
+			}
+		}
+		if p, err := core.BackupTree(ctx, path, nil); err == nil {
+			undo.BackupPath = p
+		} else {
+			pterm.Warning.Printf("Failed to back up %s: %v\n", path, err)
+		}
 	}
 
-	// 4. Execute RevertAction
-	return revertable.RevertAction(change.Action, ctx)
+	// 6. Execute RevertAction
+	return undo, revertable.RevertAction(change.Action, ctx)
 }
 
 func init() {
 	rootCmd.AddCommand(rollbackCmd)
+	addHostFlags(rollbackCmd)
+	rollbackCmd.Flags().StringVar(&rollbackTo, "to", "", "Roll back every transaction after this one (ID or prefix)")
+	rollbackCmd.Flags().StringArrayVar(&rollbackOnly, "only", nil, "Only revert this resource (type:name, repeatable)")
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "Show what would be reverted without changing anything")
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Don't ask for confirmation")
+	rollbackCmd.Flags().IntVar(&rollbackCount, "count", 0, "Roll back the last N transactions")
 }
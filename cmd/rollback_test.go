@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRollback(t *testing.T) {
+	txs := []types.Transaction{
+		{ID: "tx2", Changes: []types.TransactionChange{
+			{Type: "file", Name: "/etc/motd", Action: "applied"},
+			{Type: "package", Name: "htop", Action: "applied"},
+		}},
+		{ID: "tx1", Changes: []types.TransactionChange{
+			{Type: "package", Name: "htop", Action: types.ActionStateImport},
+		}},
+	}
+
+	steps := planRollback(txs, nil)
+	// State-only transactions have nothing to revert; changes are reverted last first
+	assert.Len(t, steps, 1)
+	assert.Equal(t, "htop", steps[0].changes[0].Name)
+	assert.Equal(t, "/etc/motd", steps[0].changes[1].Name)
+	assert.False(t, steps[0].partial)
+
+	steps = planRollback(txs, []string{"file:/etc/motd"})
+	assert.Len(t, steps, 1)
+	assert.Len(t, steps[0].changes, 1)
+	assert.True(t, steps[0].partial)
+}
+
+func TestRollbackArg(t *testing.T) {
+	txs := []types.Transaction{{ID: "12345678-aaaa"}, {ID: "2b3c4d5e-bbbb"}, {ID: "9f00aa11-cccc"}}
+
+	n, err := rollbackArg(txs, "3")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// A numeric ID prefix beyond the history is an ID, never a huge count
+	n, err = rollbackArg(txs, "12345678")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = rollbackArg(txs, "2b3c")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = rollbackArg(txs, "2")
+	assert.Error(t, err)
+	_, err = rollbackArg(txs, "0")
+	assert.Error(t, err)
+}
@@ -3,9 +3,13 @@ package cmd
 import (
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/secrets"
+	"github.com/melih-ucgun/veto/internal/system"
 )
 
 var rootCmd = &cobra.Command{
@@ -15,6 +19,10 @@ var rootCmd = &cobra.Command{
 }
 
 var verboseCount int
+var refreshFacts bool
+var factsTTL time.Duration
+var showSecrets bool
+var lockTimeout time.Duration
 
 func Execute() error {
 	return rootCmd.Execute()
@@ -38,4 +46,18 @@ func init() {
 	rootCmd.PersistentFlags().StringP("config", "c", "veto.yaml", "config file path")
 	rootCmd.PersistentFlags().CountVarP(&verboseCount, "verbose", "v", "Increase verbosity level (-v, -vv, -vvv)")
 	rootCmd.PersistentFlags().Bool("decrypt", true, "Decrypt secret values using master key")
+	rootCmd.PersistentFlags().BoolVar(&refreshFacts, "refresh-facts", false, "Ignore cached facts and re-detect the system")
+	rootCmd.PersistentFlags().DurationVar(&factsTTL, "facts-ttl", system.DefaultFactsTTL, "How long cached facts are trusted (0 disables the cache)")
+	rootCmd.PersistentFlags().DurationVar(&lockTimeout, "lock-timeout", 0, "How long to wait for the state lock held by another veto process")
+	rootCmd.PersistentFlags().BoolVar(&showSecrets, "show-secrets", false, "Print decrypted secrets in logs, diffs and plan output instead of masking them")
+
+	// Runs after flag parsing, before any command
+	cobra.OnInitialize(func() {
+		secrets.SetReveal(showSecrets)
+	})
+}
+
+// newFactCache returns the fact cache configured by the global flags.
+func newFactCache() *system.FactCache {
+	return system.NewFactCache(factsTTL, refreshFacts)
 }
@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/melih-ucgun/veto/internal/config"
 	"github.com/melih-ucgun/veto/internal/consts"
 	"github.com/melih-ucgun/veto/internal/crypto"
 	"github.com/pterm/pterm"
@@ -17,10 +20,27 @@ var secretCmd = &cobra.Command{
 	Long:  `Utilities for generating keys and encrypting/decrypting sensitive values.`,
 }
 
+var keygenAsymmetric bool
+var keygenOutput string
+var keygenName string
+var encryptRecipients []string
+var encryptAES bool
+var decryptRaw bool
+
 var keygenCmd = &cobra.Command{
 	Use:   "keygen",
-	Short: "Generate a new master key",
+	Short: "Generate a new master key or X25519 identity",
+	Long: `Generates a shared AES master key, or with --asymmetric a personal X25519 identity.
+
+Values encrypted with an identity's public key (ENC[X25519:...]) can only be decrypted by the
+holders of the matching identities, so people and hosts no longer share one secret.
+In fleet mode each host decrypts the values of its resources with its own identity.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if keygenAsymmetric {
+			runIdentityKeygen()
+			return
+		}
+
 		key, err := crypto.GenerateKey()
 		if err != nil {
 			pterm.Error.Println("Failed to generate key:", err)
@@ -35,14 +55,17 @@ var keygenCmd = &cobra.Command{
 var encryptCmd = &cobra.Command{
 	Use:   "encrypt [value]",
 	Short: "Encrypt a value",
-	Args:  cobra.ExactArgs(1),
+	Long: `Encrypts a value for the recipients in .veto/recipients (ENC[X25519:...]).
+Without a recipients file, or with --aes, the shared master key is used (ENC[AES256:...]).`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		key := getMasterKey()
-		if key == "" {
+		encrypt, err := secretEncrypter(encryptRecipients, encryptAES)
+		if err != nil {
+			pterm.Error.Println(err)
 			return
 		}
 
-		encrypted, err := crypto.Encrypt(args[0], key)
+		encrypted, err := encrypt(args[0])
 		if err != nil {
 			pterm.Error.Println("Encryption failed:", err)
 			return
@@ -58,14 +81,16 @@ var decryptCmd = &cobra.Command{
 	Short: "Decrypt a value",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		key := getMasterKey()
-		if key == "" {
-			return
-		}
-
-		decrypted, err := crypto.Decrypt(args[0], key)
+		decrypted, err := newSecretKeyring().Decrypt(args[0])
 		if err != nil {
 			pterm.Error.Println("Decryption failed:", err)
+			if decryptRaw {
+				os.Exit(1)
+			}
+			return
+		}
+		if decryptRaw {
+			fmt.Print(decrypted)
 			return
 		}
 
@@ -79,19 +104,138 @@ func init() {
 	secretCmd.AddCommand(keygenCmd)
 	secretCmd.AddCommand(encryptCmd)
 	secretCmd.AddCommand(decryptCmd)
+
+	keygenCmd.Flags().BoolVar(&keygenAsymmetric, "asymmetric", false, "Generate an X25519 identity instead of a shared master key")
+	keygenCmd.Flags().StringVarP(&keygenOutput, "output", "o", "", "Identity file to write (default ~/.veto/identity, '-' for stdout)")
+	keygenCmd.Flags().StringVar(&keygenName, "name", "", "Add the public key to .veto/recipients under this name")
+	encryptCmd.Flags().StringSliceVarP(&encryptRecipients, "recipient", "r", nil, "Public key or recipient name (overrides .veto/recipients)")
+	decryptCmd.Flags().BoolVar(&decryptRaw, "raw", false, "Print only the plaintext (used by fleet hosts)")
+	encryptCmd.Flags().BoolVar(&encryptAES, "aes", false, "Encrypt with the shared master key even if recipients exist")
 }
 
-func getMasterKey() string {
-	// 1. Env Var
-	if key := os.Getenv("VETO_MASTER_KEY"); key != "" {
-		return strings.TrimSpace(key)
+func runIdentityKeygen() {
+	id, err := crypto.GenerateIdentity()
+	if err != nil {
+		pterm.Error.Println("Failed to generate identity:", err)
+		return
 	}
 
-	// 2. File
-	if keyPath, err := consts.GetMasterKeyPath(); err == nil {
-		if content, err := os.ReadFile(keyPath); err == nil {
-			return strings.TrimSpace(string(content))
+	output := keygenOutput
+	if output == "" {
+		if output, err = consts.GetIdentityPath(); err != nil {
+			pterm.Error.Println(err)
+			return
+		}
+	}
+
+	if output == "-" {
+		fmt.Println(id.String())
+	} else {
+		if _, err := os.Stat(output); err == nil {
+			pterm.Error.Printf("%s already exists. Use -o to write the new identity elsewhere.\n", output)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0700); err != nil {
+			pterm.Error.Println(err)
+			return
 		}
+		content := fmt.Sprintf("# public key: %s\n%s\n", id.Recipient(), id.String())
+		if err := os.WriteFile(output, []byte(content), 0600); err != nil {
+			pterm.Error.Println("Failed to write identity:", err)
+			return
+		}
+		pterm.Success.Printf("Identity written to %s\n", output)
+	}
+
+	pterm.Info.Println("Public key:")
+	fmt.Fprintln(os.Stderr, id.Recipient())
+
+	if keygenName != "" {
+		path := consts.GetRecipientsPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			pterm.Error.Println(err)
+			return
+		}
+		if err := crypto.AppendRecipient(path, crypto.Recipient{PublicKey: id.Recipient(), Name: keygenName}); err != nil {
+			pterm.Error.Println("Failed to add recipient:", err)
+			return
+		}
+		pterm.Success.Printf("Added '%s' to %s\n", keygenName, path)
+	} else {
+		pterm.Info.Printf("Add the public key to %s so values are encrypted to it.\n", consts.GetRecipientsPath())
+	}
+}
+
+// secretEncrypter returns the encryption used for new values: X25519 for the given or
+// configured recipients, or the shared master key without recipients or with useAES.
+func secretEncrypter(explicit []string, useAES bool) (func(string) (string, error), error) {
+	recipients, err := resolveRecipients(explicit)
+	if err != nil {
+		return nil, err
+	}
+	if !useAES && len(recipients) > 0 {
+		return func(plaintext string) (string, error) {
+			return crypto.EncryptX25519(plaintext, recipients)
+		}, nil
+	}
+
+	key := getMasterKey()
+	if key == "" {
+		return nil, fmt.Errorf("master key not found")
+	}
+	return func(plaintext string) (string, error) {
+		return crypto.Encrypt(plaintext, key)
+	}, nil
+}
+
+// resolveRecipients returns the public keys to encrypt to. Explicit entries may be
+// public keys or names from the recipients file; without them the whole file is used.
+func resolveRecipients(explicit []string) ([]string, error) {
+	known, err := crypto.LoadRecipients(consts.GetRecipientsPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(explicit) == 0 {
+		return crypto.PublicKeys(known), nil
+	}
+
+	var keys []string
+	for _, r := range explicit {
+		if strings.HasPrefix(r, crypto.PublicKeyPrefix) {
+			keys = append(keys, r)
+			continue
+		}
+		found := false
+		for _, k := range known {
+			if k.Name == r {
+				keys = append(keys, k.PublicKey)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown recipient '%s' (not in %s)", r, consts.GetRecipientsPath())
+		}
+	}
+	return keys, nil
+}
+
+// newSecretKeyring loads identities and resolves the master key lazily.
+func newSecretKeyring() *crypto.Keyring {
+	identities, err := crypto.LoadIdentities()
+	if err != nil {
+		pterm.Warning.Printf("Failed to load identities: %v\n", err)
+	}
+	return &crypto.Keyring{Identities: identities, LoadMasterKey: getMasterKey}
+}
+
+func getMasterKey() string {
+	key, err := config.ReadMasterKey(config.PromptPassphrase)
+	if err == nil {
+		return key
+	}
+	if !errors.Is(err, config.ErrNoMasterKey) {
+		pterm.Error.Println(err)
+		return ""
 	}
 
 	pterm.Error.Println("Master Key not found!")
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/config"
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/crypto"
+)
+
+var unlockTimeout time.Duration
+var agentTimeout time.Duration
+var passphraseRemove bool
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Cache the master key in a local agent for a while",
+	Long: `Opens the passphrase-protected master key once and keeps it in a background agent
+(~/.veto/agent.sock, owner only) so watch mode and repeated applies can decrypt without
+the plaintext key on disk. The agent forgets the key after --timeout or 'veto secret lock'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runUnlock(unlockTimeout); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Make the key agent forget the master key",
+	Run: func(cmd *cobra.Command, args []string) {
+		socket, err := consts.GetAgentSocketPath()
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		if err := crypto.LockAgent(socket); err != nil {
+			pterm.Info.Println("Key agent is not running.")
+			return
+		}
+		pterm.Success.Println("Master key locked.")
+	},
+}
+
+// agentCmd is the background process started by unlock; the key arrives on stdin.
+var agentCmd = &cobra.Command{
+	Use:    "agent",
+	Short:  "Run the master key agent (started by 'veto secret unlock')",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil || strings.TrimSpace(key) == "" {
+			fmt.Fprintln(os.Stderr, "agent: no key on stdin")
+			os.Exit(1)
+		}
+		socket, err := consts.GetAgentSocketPath()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "agent:", err)
+			os.Exit(1)
+		}
+		if err := crypto.ServeAgent(socket, strings.TrimSpace(key), agentTimeout); err != nil {
+			fmt.Fprintln(os.Stderr, "agent:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var passphraseCmd = &cobra.Command{
+	Use:   "passphrase",
+	Short: "Protect the master key file with a passphrase",
+	Long: `Wraps ~/.veto/master.key with a passphrase (argon2id), or changes the passphrase of an
+already protected key. The key itself does not change, so no value needs re-encryption.
+Use 'veto secret unlock' to avoid typing the passphrase on every run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runPassphrase(passphraseRemove); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(unlockCmd)
+	secretCmd.AddCommand(lockCmd)
+	secretCmd.AddCommand(agentCmd)
+	secretCmd.AddCommand(passphraseCmd)
+
+	unlockCmd.Flags().DurationVarP(&unlockTimeout, "timeout", "t", 15*time.Minute, "How long the agent keeps the key")
+	agentCmd.Flags().DurationVar(&agentTimeout, "timeout", 15*time.Minute, "How long to keep the key")
+	passphraseCmd.Flags().BoolVar(&passphraseRemove, "remove", false, "Store the master key unprotected again")
+}
+
+func runUnlock(timeout time.Duration) error {
+	socket, err := consts.GetAgentSocketPath()
+	if err != nil {
+		return err
+	}
+	keyPath, err := consts.GetMasterKeyPath()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read master key: %w", err)
+	}
+
+	key := strings.TrimSpace(string(content))
+	if crypto.IsWrappedKey(key) {
+		if key, err = config.UnwrapMasterKey(key, config.PromptPassphrase); err != nil {
+			return err
+		}
+	} else {
+		pterm.Warning.Printf("%s is not passphrase-protected; run 'veto secret passphrase' to protect it.\n", keyPath)
+	}
+
+	// Replace a running agent so the timeout starts over
+	crypto.LockAgent(socket)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	agent := exec.Command(exe, "secret", "agent", "--timeout", timeout.String())
+	agent.Stdin = strings.NewReader(key + "\n")
+	agent.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // Outlive the terminal session
+	if err := agent.Start(); err != nil {
+		return fmt.Errorf("failed to start key agent: %w", err)
+	}
+	agent.Process.Release()
+
+	for i := 0; i < 50; i++ {
+		if _, err := crypto.AgentStatus(socket); err == nil {
+			pterm.Success.Printf("Master key unlocked for %s.\n", timeout)
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("key agent did not start on %s", socket)
+}
+
+func runPassphrase(remove bool) error {
+	keyPath, err := consts.GetMasterKeyPath()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read master key: %w", err)
+	}
+
+	key := strings.TrimSpace(string(content))
+	wrapped := crypto.IsWrappedKey(key)
+	if wrapped {
+		if key, err = config.UnwrapMasterKey(key, config.PromptPassphrase); err != nil {
+			return err
+		}
+	}
+
+	if remove {
+		if !wrapped {
+			pterm.Info.Println("Master key is not passphrase-protected.")
+			return nil
+		}
+		if err := os.WriteFile(keyPath, []byte(key+"\n"), 0600); err != nil {
+			return err
+		}
+		pterm.Success.Printf("Passphrase removed from %s\n", keyPath)
+		return nil
+	}
+
+	data, err := wrapMasterKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, data, 0600); err != nil {
+		return err
+	}
+	pterm.Success.Printf("%s is now passphrase-protected\n", keyPath)
+	return nil
+}
+
+// wrapMasterKey asks for a new passphrase (twice) and returns the wrapped key file content.
+func wrapMasterKey(key string) ([]byte, error) {
+	pass, err := pterm.DefaultInteractiveTextInput.WithMask("*").WithDefaultText("New passphrase").Show()
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := pterm.DefaultInteractiveTextInput.WithMask("*").WithDefaultText("Repeat passphrase").Show()
+	if err != nil {
+		return nil, err
+	}
+	if pass != confirm {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+
+	wrapped, err := crypto.WrapKey(key, pass)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(wrapped + "\n"), nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/config"
+	"github.com/melih-ucgun/veto/internal/utils"
+)
+
+var editRecipients []string
+var editAES bool
+
+var secretEditCmd = &cobra.Command{
+	Use:   "edit <file>",
+	Short: "Edit an encrypted YAML file in $EDITOR",
+	Long: `Decrypts every ENC[...] value of a YAML file into a temporary copy and opens it in
+$VISUAL or $EDITOR. Decrypted values are marked !secret:
+
+  db_password: !secret "hunter2"
+
+On save, only values that changed and new values marked !secret are encrypted; unchanged
+values keep their exact ciphertext so git diffs stay small. Removing the !secret tag stores
+a value in plain text.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSecretEdit(args[0]); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(secretEditCmd)
+	secretEditCmd.Flags().StringSliceVarP(&editRecipients, "recipient", "r", nil, "Public key or recipient name for changed values (overrides .veto/recipients)")
+	secretEditCmd.Flags().BoolVar(&editAES, "aes", false, "Encrypt changed values with the shared master key even if recipients exist")
+}
+
+func runSecretEdit(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	plain, secrets, err := config.DecryptSecrets(data, newSecretKeyring().Decrypt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// CreateTemp uses mode 0600, so plaintext is only readable by the current user
+	tmp, err := os.CreateTemp("", "veto-edit-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(plain); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	// The encryption key is only needed once something actually changed
+	var encrypt func(string) (string, error)
+	lazyEncrypt := func(plaintext string) (string, error) {
+		if encrypt == nil {
+			if encrypt, err = secretEncrypter(editRecipients, editAES); err != nil {
+				return "", err
+			}
+		}
+		return encrypt(plaintext)
+	}
+
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			return err
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, plain) {
+			pterm.Info.Println("No changes.")
+			return nil
+		}
+
+		out, n, err := config.EncryptSecrets(edited, secrets, lazyEncrypt)
+		if err == nil {
+			if err := utils.WriteFileAtomic(path, out, info.Mode().Perm()); err != nil {
+				return err
+			}
+			pterm.Success.Printf("Saved %s (%d value(s) encrypted)\n", path, n)
+			return nil
+		}
+
+		pterm.Error.Println(err)
+		again, _ := pterm.DefaultInteractiveConfirm.
+			WithDefaultText("Re-open the editor to fix it?").
+			WithDefaultValue(true).
+			Show()
+		if !again {
+			return fmt.Errorf("changes discarded, %s was not modified", path)
+		}
+	}
+}
+
+// runEditor opens path in $VISUAL, $EDITOR or vi, attached to the terminal.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// EDITOR may carry arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	c := exec.Command(parts[0], append(parts[1:], path)...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor '%s' failed: %w", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/crypto"
+)
+
+var encryptFileOutput string
+var encryptFileRecipients []string
+var encryptFileAES bool
+var decryptFileOutput string
+
+var encryptFileCmd = &cobra.Command{
+	Use:   "encrypt-file <file>",
+	Short: "Encrypt a whole file (private keys, VPN configs, .netrc)",
+	Long: `Encrypts a file into a text blob that is safe to commit, written to <file>.enc by default.
+
+'file' resources (source:) and 'template' resources (src:) pointing at an encrypted file
+decrypt it transparently before comparing and writing. Backups of the written files are
+stored encrypted as well.
+
+  veto secret encrypt-file ~/.ssh/id_ed25519 -o files/id_ed25519.enc`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		if crypto.IsEncryptedFile(args[0], data) {
+			pterm.Error.Printf("%s is already encrypted\n", args[0])
+			os.Exit(1)
+		}
+
+		encrypt, err := secretEncrypter(encryptFileRecipients, encryptFileAES)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		encrypted, err := encrypt(string(data))
+		if err != nil {
+			pterm.Error.Println("Encryption failed:", err)
+			os.Exit(1)
+		}
+
+		output := encryptFileOutput
+		if output == "" {
+			output = args[0] + crypto.EncryptedFileExt
+		}
+		if output == "-" {
+			os.Stdout.Write(crypto.ArmorFile(encrypted))
+			return
+		}
+		if err := os.WriteFile(output, crypto.ArmorFile(encrypted), 0644); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		pterm.Success.Printf("Encrypted %s to %s\n", args[0], output)
+	},
+}
+
+var decryptFileCmd = &cobra.Command{
+	Use:   "decrypt-file <file>",
+	Short: "Decrypt a file encrypted with encrypt-file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		value, err := crypto.DearmorFile(data)
+		if err != nil {
+			pterm.Error.Printf("%s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		plain, err := newSecretKeyring().Decrypt(value)
+		if err != nil {
+			pterm.Error.Println("Decryption failed:", err)
+			os.Exit(1)
+		}
+
+		if decryptFileOutput == "" || decryptFileOutput == "-" {
+			os.Stdout.WriteString(plain)
+			return
+		}
+		if err := os.WriteFile(decryptFileOutput, []byte(plain), 0600); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		pterm.Success.Printf("Decrypted %s to %s\n", args[0], decryptFileOutput)
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(encryptFileCmd)
+	secretCmd.AddCommand(decryptFileCmd)
+
+	encryptFileCmd.Flags().StringVarP(&encryptFileOutput, "output", "o", "", fmt.Sprintf("Output file (default <file>%s, '-' for stdout)", crypto.EncryptedFileExt))
+	encryptFileCmd.Flags().StringSliceVarP(&encryptFileRecipients, "recipient", "r", nil, "Public key or recipient name (overrides .veto/recipients)")
+	encryptFileCmd.Flags().BoolVar(&encryptFileAES, "aes", false, "Encrypt with the shared master key even if recipients exist")
+	decryptFileCmd.Flags().StringVarP(&decryptFileOutput, "output", "o", "", "Output file (default stdout)")
+}
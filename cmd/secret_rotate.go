@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/config"
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/crypto"
+)
+
+var rotateTo string
+var rotateNewKey string
+var rotateOldKey string
+var rotateRecipients []string
+var rotateInventory string
+var rotateCheck bool
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate [extra files...]",
+	Short: "Re-encrypt every secret of the config tree with a new key or recipient set",
+	Long: `Walks the configuration (including includes and rulesets) and the inventory, and
+re-encrypts every encrypted value in place. Comments and formatting are preserved.
+
+  veto secret rotate                      # new master key (AES256), saved to ~/.veto/master.key
+  veto secret rotate --to x25519          # encrypt to the current .veto/recipients
+  veto secret rotate --check              # report values not on the current key/recipients
+
+Values already encrypted with the target key or recipient set are left untouched,
+so an interrupted rotation can simply be run again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, _ := cmd.Flags().GetString("config")
+		files, err := secretFiles(configFile, rotateInventory, args)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			pterm.Warning.Println("No configuration files found.")
+			return
+		}
+
+		if rotateCheck {
+			if !runRotateCheck(files) {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if err := runRotate(files); err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(rotateCmd)
+	rotateCmd.Flags().StringVar(&rotateTo, "to", "", "Target format: aes or x25519 (default: x25519 if .veto/recipients exists)")
+	rotateCmd.Flags().StringVar(&rotateNewKey, "new-key", "", "New master key (hex) for --to aes (default: generate one)")
+	rotateCmd.Flags().StringVar(&rotateOldKey, "old-key", "", "Master key the AES256 values are currently encrypted with (default: current master key)")
+	rotateCmd.Flags().StringSliceVarP(&rotateRecipients, "recipient", "r", nil, "Recipients for --to x25519 (default: .veto/recipients)")
+	rotateCmd.Flags().StringVarP(&rotateInventory, "inventory", "i", "inventory.yaml", "Inventory file to include (skipped if missing)")
+	rotateCmd.Flags().BoolVar(&rotateCheck, "check", false, "Only report values that are not encrypted with the current key/recipients")
+}
+
+// secretFiles lists the config tree, the inventory and extra files that exist.
+func secretFiles(configFile, inventoryFile string, extra []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		abs, err := filepath.Abs(path)
+		if err == nil && !seen[abs] {
+			seen[abs] = true
+			files = append(files, abs)
+		}
+	}
+
+	if _, err := os.Stat(configFile); err == nil {
+		tree, err := config.ConfigFiles(configFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range tree {
+			add(f)
+		}
+	}
+	if _, err := os.Stat(inventoryFile); err == nil {
+		add(inventoryFile)
+	}
+	for _, f := range extra {
+		if _, err := os.Stat(f); err != nil {
+			return nil, err
+		}
+		add(f)
+	}
+	return files, nil
+}
+
+// rotateTarget describes what values should be encrypted with after rotation.
+type rotateTarget struct {
+	format     string // "aes" or "x25519"
+	masterKey  string
+	recipients []string
+	keyIDs     []string // Sorted key IDs a current value carries
+}
+
+func (t *rotateTarget) isCurrent(value string) bool {
+	ids, err := crypto.ValueKeyIDs(value)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(value, crypto.X25519Prefix) != (t.format == "x25519") {
+		return false
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",") == strings.Join(t.keyIDs, ",")
+}
+
+func (t *rotateTarget) encrypt(plaintext string) (string, error) {
+	if t.format == "x25519" {
+		return crypto.EncryptX25519(plaintext, t.recipients)
+	}
+	return crypto.Encrypt(plaintext, t.masterKey)
+}
+
+// resolveRotateTarget builds the target from flags. With generate=false the current
+// master key is used for AES, which is what --check compares against.
+func resolveRotateTarget(generate bool) (*rotateTarget, error) {
+	format := rotateTo
+	if format == "" {
+		format = "aes"
+		if _, err := os.Stat(consts.GetRecipientsPath()); err == nil || len(rotateRecipients) > 0 {
+			format = "x25519"
+		}
+	}
+
+	t := &rotateTarget{format: format}
+	switch format {
+	case "x25519":
+		recipients, err := resolveRecipients(rotateRecipients)
+		if err != nil {
+			return nil, err
+		}
+		if len(recipients) == 0 {
+			return nil, fmt.Errorf("no recipients: add public keys to %s or pass -r", consts.GetRecipientsPath())
+		}
+		t.recipients = recipients
+		for _, r := range recipients {
+			id, err := crypto.RecipientKeyID(r)
+			if err != nil {
+				return nil, err
+			}
+			t.keyIDs = append(t.keyIDs, id)
+		}
+	case "aes":
+		switch {
+		case rotateNewKey != "":
+			t.masterKey = strings.TrimSpace(rotateNewKey)
+		case generate:
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				return nil, err
+			}
+			t.masterKey = key
+		default:
+			t.masterKey = getMasterKey()
+			if t.masterKey == "" {
+				return nil, fmt.Errorf("master key not found")
+			}
+		}
+		t.keyIDs = []string{crypto.KeyID(t.masterKey)}
+	default:
+		return nil, fmt.Errorf("unknown target '%s' (use aes or x25519)", format)
+	}
+
+	sort.Strings(t.keyIDs)
+	return t, nil
+}
+
+func runRotateCheck(files []string) bool {
+	target, err := resolveRotateTarget(false)
+	if err != nil {
+		pterm.Error.Println(err)
+		return false
+	}
+
+	tableData := [][]string{{"File", "Values", "Stale", "Key IDs"}}
+	totalStale := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			pterm.Error.Println(err)
+			return false
+		}
+		values, err := config.EncryptedValues(data)
+		if err != nil {
+			pterm.Error.Printf("%s: %v\n", file, err)
+			return false
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		stale := 0
+		keys := make(map[string]bool)
+		for _, v := range values {
+			if !target.isCurrent(v) {
+				stale++
+			}
+			ids, _ := crypto.ValueKeyIDs(v)
+			for _, id := range ids {
+				keys[id] = true
+			}
+		}
+		totalStale += stale
+
+		var keyList []string
+		for k := range keys {
+			keyList = append(keyList, k)
+		}
+		sort.Strings(keyList)
+		tableData = append(tableData, []string{relPath(file), fmt.Sprint(len(values)), fmt.Sprint(stale), strings.Join(keyList, ", ")})
+	}
+
+	pterm.Info.Printf("Target (%s): %s\n", target.format, strings.Join(target.keyIDs, ", "))
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+
+	if totalStale > 0 {
+		pterm.Warning.Printf("%d value(s) are not encrypted with the current key/recipients. Run 'veto secret rotate'.\n", totalStale)
+		return false
+	}
+	pterm.Success.Println("All secrets use the current key/recipients.")
+	return true
+}
+
+func runRotate(files []string) error {
+	target, err := resolveRotateTarget(true)
+	if err != nil {
+		return err
+	}
+
+	keyring := newSecretKeyring()
+	if rotateOldKey != "" {
+		keyring.MasterKey = strings.TrimSpace(rotateOldKey)
+	}
+
+	// Pass 1: decrypt every stale value before touching any file, so a missing
+	// key never leaves the tree half-rotated.
+	plain := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		values, err := config.EncryptedValues(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, v := range values {
+			if _, ok := plain[v]; ok || target.isCurrent(v) {
+				continue
+			}
+			p, err := keyring.Decrypt(v)
+			if err != nil {
+				return fmt.Errorf("%s: cannot decrypt value: %w", relPath(file), err)
+			}
+			plain[v] = p
+		}
+	}
+
+	if len(plain) == 0 {
+		pterm.Success.Println("All secrets already use the target key/recipients.")
+		return nil
+	}
+
+	// A generated key is stored before anything is encrypted with it, so it is never
+	// lost when the rotation stops half-way
+	stagedKey := ""
+	if target.format == "aes" && rotateNewKey == "" {
+		if stagedKey, err = stageRotatedMasterKey(target.masterKey); err != nil {
+			return err
+		}
+	}
+
+	// Pass 2: rewrite files in place
+	tableData := [][]string{{"File", "Re-encrypted"}}
+	total := 0
+	for _, file := range files {
+		n, err := config.RewriteSecrets(file, func(value string) (string, error) {
+			p, ok := plain[value]
+			if !ok {
+				return value, nil
+			}
+			return target.encrypt(p)
+		})
+		if err != nil {
+			if stagedKey != "" {
+				pterm.Warning.Printf("Rotation stopped; values already re-encrypted use the new key in %s. Run 'veto secret rotate --new-key' with it to finish.\n", stagedKey)
+			}
+			return err
+		}
+		if n > 0 {
+			tableData = append(tableData, []string{relPath(file), fmt.Sprint(n)})
+			total += n
+		}
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	pterm.Success.Printf("Re-encrypted %d value(s) (%s, key IDs: %s)\n", total, target.format, strings.Join(target.keyIDs, ", "))
+
+	if stagedKey != "" {
+		return saveRotatedMasterKey(stagedKey, target.masterKey)
+	}
+	return nil
+}
+
+// stageRotatedMasterKey writes a generated key to master.key.new and returns its path.
+// When the current key is protected by a passphrase, the new one is protected too, so
+// the passphrase is asked for before any value is re-encrypted.
+func stageRotatedMasterKey(key string) (string, error) {
+	keyPath, err := consts.GetMasterKeyPath()
+	if err != nil {
+		return "", err
+	}
+	data := []byte(key + "\n")
+	if old, err := os.ReadFile(keyPath); err == nil && crypto.IsWrappedKey(string(old)) && os.Getenv("VETO_MASTER_KEY") == "" {
+		pterm.Info.Println("Choose a passphrase for the new master key.")
+		if data, err = wrapMasterKey(key); err != nil {
+			return "", fmt.Errorf("rotation cancelled, no file was changed: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return "", err
+	}
+	stagedPath := keyPath + ".new"
+	if err := os.WriteFile(stagedPath, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to save new master key: %w", err)
+	}
+	return stagedPath, nil
+}
+
+// saveRotatedMasterKey moves the staged key in place of the master key once every
+// file was rewritten, keeping the previous one as master.key.old.
+func saveRotatedMasterKey(stagedPath, key string) error {
+	if os.Getenv("VETO_MASTER_KEY") != "" {
+		pterm.Warning.Printf("VETO_MASTER_KEY is set; update it with the new master key (also saved to %s):\n", stagedPath)
+		fmt.Println(key)
+		return nil
+	}
+
+	keyPath := strings.TrimSuffix(stagedPath, ".new")
+	if old, err := os.ReadFile(keyPath); err == nil {
+		if err := os.WriteFile(keyPath+".old", old, 0600); err != nil {
+			return fmt.Errorf("failed to back up old master key: %w", err)
+		}
+	}
+	if err := os.Rename(stagedPath, keyPath); err != nil {
+		return fmt.Errorf("failed to save new master key (it is kept in %s): %w", stagedPath, err)
+	}
+	pterm.Success.Printf("New master key saved to %s (previous key kept as %s.old)\n", keyPath, filepath.Base(keyPath))
+	if socket, err := consts.GetAgentSocketPath(); err == nil && crypto.LockAgent(socket) == nil {
+		pterm.Info.Println("Key agent locked; run 'veto secret unlock' to cache the new key.")
+	}
+	return nil
+}
+
+func relPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/config"
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/fleet"
+	"github.com/melih-ucgun/veto/internal/inventory"
+	"github.com/melih-ucgun/veto/internal/state"
+	"github.com/melih-ucgun/veto/internal/transport"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and maintain the state file",
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	addHostFlags(stateCmd)
+}
+
+// addHostFlags adds --host and --inventory to a command working on the state of a
+// fleet host instead of this machine. They are persistent so subcommands inherit them.
+func addHostFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("host", "", "Use the state of this inventory host (fleet mode)")
+	cmd.PersistentFlags().StringP("inventory", "i", "inventory.yaml", "Inventory file used to reach the host")
+}
+
+// loadStateConfig reads the 'state' section of the --config file.
+func loadStateConfig(cmd *cobra.Command) (*state.BackendConfig, error) {
+	configFile, _ := cmd.Flags().GetString("config")
+	decrypt, _ := cmd.Flags().GetBool("decrypt")
+	return config.LoadStateConfig(configFile, decrypt)
+}
+
+// loadStateManager opens the state backend configured in the 'state' section of the
+// --config file, or the local state file if there is none. With --host it opens the
+// state of that fleet host, connecting to it when the state lives on the host.
+// The returned function closes that connection.
+func loadStateManager(cmd *cobra.Command) (*state.Manager, func(), error) {
+	cfg, err := loadStateConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostName, _ := cmd.Flags().GetString("host")
+	if hostName == "" {
+		mgr, err := newStateManager(cfg, &core.RealFS{})
+		return mgr, func() {}, err
+	}
+
+	var fs state.FileSystem
+	done := func() {}
+	if cfg != nil && cfg.Backend == state.BackendRemote {
+		_, trans, err := connectHost(cmd, hostName)
+		if err != nil {
+			return nil, nil, err
+		}
+		fs = trans.GetFileSystem()
+		done = func() { trans.Close() }
+	}
+
+	mgr, err := newHostStateManager(cfg, hostName, fs)
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	return mgr, done, nil
+}
+
+// loadStateContext opens the state like loadStateManager and a system context for
+// the machine it belongs to: this one, or the fleet host given with --host.
+// The returned function closes the connection to the host.
+func loadStateContext(cmd *cobra.Command) (*state.Manager, *core.SystemContext, func(), error) {
+	hostName, _ := cmd.Flags().GetString("host")
+	if hostName == "" {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ctx := core.NewSystemContext(false, transport.NewLocalTransport())
+		newFactCache().Detect(ctx, "")
+		return mgr, ctx, done, nil
+	}
+
+	h, trans, err := connectHost(cmd, hostName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cfg, err := loadStateConfig(cmd)
+	if err != nil {
+		trans.Close()
+		return nil, nil, nil, err
+	}
+	mgr, err := newHostStateManager(cfg, hostName, trans.GetFileSystem())
+	if err != nil {
+		trans.Close()
+		return nil, nil, nil, err
+	}
+
+	ctx := core.NewSystemContext(false, trans)
+	ctx.FS = trans.GetFileSystem()
+	ctx.TargetUser = h.User
+	newFactCache().Detect(ctx, h.Name)
+	return mgr, ctx, func() { trans.Close() }, nil
+}
+
+// newStateManager opens the state of the machine whose filesystem is fs.
+func newStateManager(cfg *state.BackendConfig, fs state.FileSystem) (*state.Manager, error) {
+	return state.Open(cfg, state.Target{FS: fs})
+}
+
+// newHostStateManager opens the state of fleet host name; fs is its filesystem.
+func newHostStateManager(cfg *state.BackendConfig, name string, fs state.FileSystem) (*state.Manager, error) {
+	return state.OpenHost(cfg, state.Target{Name: name, FS: fs})
+}
+
+// connectHost looks up name in the --inventory file and connects to it.
+func connectHost(cmd *cobra.Command, name string) (inventory.Host, core.Transport, error) {
+	invFile, _ := cmd.Flags().GetString("inventory")
+	inv, err := inventory.LoadInventory(invFile)
+	if err != nil {
+		return inventory.Host{}, nil, fmt.Errorf("failed to load inventory: %w", err)
+	}
+	h, ok := fleet.FindHost(inv, name)
+	if !ok {
+		return inventory.Host{}, nil, fmt.Errorf("host '%s' not found in %s", name, invFile)
+	}
+
+	hosts := []inventory.Host{h}
+	if err := ensureSudoPasswords(hosts); err != nil {
+		return inventory.Host{}, nil, err
+	}
+	trans, err := fleet.Connect(hosts[0])
+	if err != nil {
+		return inventory.Host{}, nil, fmt.Errorf("failed to connect to %s: %w", name, err)
+	}
+	return hosts[0], trans, nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/state"
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+var migrateDryRun bool
+
+var stateMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the state to the current schema",
+	Long: `A state written by an older veto is migrated in memory whenever it is loaded, and saved in the
+new schema by the next command that writes it. This command performs the upgrade right away and
+keeps the original next to it (state.json.v1.bak). Use --dry-run to list the pending migrations.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+
+		if mgr.LoadedSchema == types.StateSchemaVersion {
+			pterm.Info.Printf("State %s is up to date (schema v%d).\n", mgr.Backend, types.StateSchemaVersion)
+			return
+		}
+
+		pending, err := state.PendingMigrations(mgr.LoadedSchema)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		pterm.Info.Printf("State %s uses schema v%d, current is v%d:\n", mgr.Backend, mgr.LoadedSchema, types.StateSchemaVersion)
+		for _, m := range pending {
+			pterm.Printf("  v%d -> v%d: %s\n", m.From, m.From+1, m.Description)
+		}
+		if migrateDryRun {
+			pterm.Info.Println("Dry run, nothing was written.")
+			return
+		}
+
+		unlock, err := lockState(mgr, "state migrate")
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		defer unlock()
+
+		backup := mgr.MigrationBackup()
+		if err := mgr.Save(); err != nil {
+			pterm.Error.Printf("Migration failed: %v\n", err)
+			os.Exit(1)
+		}
+		pterm.Success.Printf("State migrated to schema v%d (original kept at %s).\n", types.StateSchemaVersion, backup)
+	},
+}
+
+func init() {
+	stateCmd.AddCommand(stateMigrateCmd)
+	stateMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "List pending migrations without writing the state")
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/resource"
+	"github.com/melih-ucgun/veto/internal/state"
+)
+
+var importState string
+var importParams []string
+
+var stateListCmd = &cobra.Command{
+	Use:   "list [filter]",
+	Short: "List the resources tracked in the state",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+
+		tableData := [][]string{{"ID", "State", "Status", "Last Applied"}}
+		for _, e := range mgr.Resources() {
+			if len(args) > 0 && !strings.Contains(e.ID, args[0]) {
+				continue
+			}
+			status := pterm.FgGreen.Sprint(e.Status)
+			if e.Status != "success" {
+				status = pterm.FgRed.Sprint(e.Status)
+			}
+			tableData = append(tableData, []string{e.ID, e.State, status, e.LastApplied.Format("2006-01-02 15:04:05")})
+		}
+		if len(tableData) == 1 {
+			pterm.Info.Println("No matching resources in the state.")
+			return
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	},
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show <type:name>",
+	Short: "Show a state entry with its metadata and recent transactions",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+
+		e, ok := mgr.Resource(args[0])
+		if !ok {
+			pterm.Error.Printf("Resource '%s' is not in the state.\n", args[0])
+			done()
+			os.Exit(1)
+		}
+
+		pterm.DefaultSection.Println(e.ID)
+		pterm.Printf("Type:         %s\n", e.Type)
+		pterm.Printf("Name:         %s\n", e.Name)
+		pterm.Printf("State:        %s\n", e.State)
+		pterm.Printf("Status:       %s\n", e.Status)
+		pterm.Printf("Last Applied: %s\n", e.LastApplied.Format(time.RFC822))
+
+		if len(e.Metadata) > 0 {
+			pterm.Println("Metadata:")
+			keys := make([]string, 0, len(e.Metadata))
+			for k := range e.Metadata {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				pterm.Printf("  %s: %v\n", k, e.Metadata[k])
+			}
+		}
+
+		// Latest transactions that touched the resource
+		var recent []string
+		txs := mgr.GetTransactions()
+		for i := len(txs) - 1; i >= 0 && len(recent) < 5; i-- {
+			for _, c := range txs[i].Changes {
+				if c.Type == e.Type && c.Name == e.Name {
+					id := txs[i].ID
+					if len(id) > 8 {
+						id = id[:8]
+					}
+					recent = append(recent, fmt.Sprintf("  %s  %s  %s", id, txs[i].Timestamp.Format(time.RFC822), c.Action))
+					break
+				}
+			}
+		}
+		if len(recent) > 0 {
+			pterm.Println("Transactions:")
+			pterm.Println(strings.Join(recent, "\n"))
+		}
+	},
+}
+
+var stateRmCmd = &cobra.Command{
+	Use:   "rm <type:name>...",
+	Short: "Stop tracking resources without touching the system",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+		unlock, err := lockState(mgr, "state rm")
+		if err != nil {
+			pterm.Error.Println(err)
+			done()
+			os.Exit(1)
+		}
+		defer unlock()
+
+		failed := false
+		for _, id := range args {
+			if _, err := mgr.RemoveResource(id); err != nil {
+				pterm.Error.Println(err)
+				failed = true
+				continue
+			}
+			pterm.Success.Printf("Removed %s from the state.\n", id)
+		}
+		if failed {
+			unlock()
+			done()
+			os.Exit(1)
+		}
+	},
+}
+
+var stateMvCmd = &cobra.Command{
+	Use:   "mv <type:name> <type:name>",
+	Short: "Rename a state entry, e.g. after renaming the resource in the config",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+		unlock, err := lockState(mgr, "state mv")
+		if err != nil {
+			pterm.Error.Println(err)
+			done()
+			os.Exit(1)
+		}
+		defer unlock()
+
+		if _, err := mgr.MoveResource(args[0], args[1]); err != nil {
+			pterm.Error.Println(err)
+			unlock()
+			done()
+			os.Exit(1)
+		}
+		pterm.Success.Printf("Moved %s to %s.\n", args[0], args[1])
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <type> <name>",
+	Short: "Adopt an existing system object as managed",
+	Long: `Checks the object against the given parameters, as apply would, and adds it to the state
+without changing anything. Objects that don't match yet are refused; apply them instead.
+
+  veto state import package htop
+  veto state import service nginx -s running
+  veto state import file /etc/motd -p content="Welcome"`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		resType, name := args[0], args[1]
+
+		params := map[string]interface{}{"state": importState}
+		for _, p := range importParams {
+			k, v, ok := strings.Cut(p, "=")
+			if !ok {
+				pterm.Error.Printf("Invalid parameter '%s' (expected key=value)\n", p)
+				os.Exit(1)
+			}
+			params[k] = v
+		}
+
+		mgr, ctx, done, err := loadStateContext(cmd)
+		if err != nil {
+			pterm.Error.Printf("Failed to load state: %v\n", err)
+			os.Exit(1)
+		}
+		defer done()
+
+		res, err := resource.CreateResourceWithParams(resType, name, params, ctx)
+		if err == nil {
+			err = res.Validate(ctx)
+		}
+		if err != nil {
+			pterm.Error.Printf("Invalid resource: %v\n", err)
+			done()
+			os.Exit(1)
+		}
+		needsChange, err := res.Check(ctx)
+		if err != nil {
+			pterm.Error.Printf("Check failed: %v\n", err)
+			done()
+			os.Exit(1)
+		}
+		if needsChange {
+			pterm.Error.Printf("%s does not match the given parameters; apply it instead of importing.\n", state.ResourceID(resType, name))
+			done()
+			os.Exit(1)
+		}
+
+		unlock, err := lockState(mgr, "state import")
+		if err != nil {
+			pterm.Error.Println(err)
+			done()
+			os.Exit(1)
+		}
+		defer unlock()
+
+		metadata := map[string]interface{}{"imported": true}
+		entry, err := mgr.ImportResource(resType, name, importState, metadata)
+		if err != nil {
+			pterm.Error.Println(err)
+			unlock()
+			done()
+			os.Exit(1)
+		}
+		pterm.Success.Printf("Imported %s (%s).\n", entry.ID, entry.State)
+	},
+}
+
+func init() {
+	stateCmd.AddCommand(stateListCmd, stateShowCmd, stateRmCmd, stateMvCmd, stateImportCmd)
+	stateImportCmd.Flags().StringVarP(&importState, "state", "s", "present", "Desired state the object is checked against")
+	stateImportCmd.Flags().StringArrayVarP(&importParams, "param", "p", nil, "Resource parameter as key=value (repeatable)")
+}
@@ -6,11 +6,8 @@ import (
 	"time"
 
 	"github.com/melih-ucgun/veto/internal/config"
-	"github.com/melih-ucgun/veto/internal/consts"
 	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/resource"
-	"github.com/melih-ucgun/veto/internal/state"
-	"github.com/melih-ucgun/veto/internal/system"
 	"github.com/melih-ucgun/veto/internal/transport"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -24,8 +21,12 @@ var statusCmd = &cobra.Command{
 	Short: "Show the current state of managed resources",
 	Long:  `Displays a list of resources managed by Veto. By default, it shows the last known state from history. Use --check to perform a live system audit (drift detection).`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if host, _ := cmd.Flags().GetString("host"); host != "" && checkMode {
+			pterm.Error.Println("--check audits this machine and can't be combined with --host")
+			return
+		}
 		if !checkMode {
-			showHistoryStatus()
+			showHistoryStatus(cmd)
 		} else {
 			runDriftCheck(cmd)
 		}
@@ -36,16 +37,16 @@ func init() {
 	rootCmd.AddCommand(statusCmd)
 	statusCmd.Flags().BoolVar(&checkMode, "check", false, "Perform live drift check")
 	statusCmd.Flags().BoolVarP(&detailedMode, "detailed", "d", false, "Show detailed diffs for drifted resources")
+	addHostFlags(statusCmd)
 }
 
-func showHistoryStatus() {
-	statePath := consts.GetStateFilePath()
-	// Status command typically runs locally, so we use RealFS
-	mgr, err := state.NewManager(statePath, &core.RealFS{})
+func showHistoryStatus(cmd *cobra.Command) {
+	mgr, done, err := loadStateManager(cmd)
 	if err != nil {
 		pterm.Error.Printf("Could not load state file: %v\n", err)
 		return
 	}
+	defer done()
 
 	if len(mgr.Current.Resources) == 0 {
 		pterm.Info.Println("No resources currently managed by Veto.")
@@ -81,7 +82,7 @@ func runDriftCheck(cmd *cobra.Command) {
 
 	// 1. Setup Context
 	ctx := core.NewSystemContext(false, transport.NewLocalTransport())
-	system.Detect(ctx)
+	newFactCache().Detect(ctx, "")
 
 	// 2. Load Active Config
 	// Use global config flag or default
@@ -90,7 +91,7 @@ func runDriftCheck(cmd *cobra.Command) {
 		configFile = "system.yaml"
 	}
 
-	cfg, err := config.LoadConfig(configFile, false) // Decrypt false? Maybe explicit?
+	cfg, err := config.LoadConfigWith(configFile, config.LoadOptions{Facts: newFactCache()})
 	if err != nil {
 		spinner.Fail(fmt.Sprintf("Failed to load config file '%s': %v", configFile, err))
 		return
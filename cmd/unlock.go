@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/melih-ucgun/veto/internal/state"
+)
+
+var forceUnlock bool
+
+var stateUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Remove a stale state lock",
+	Long: `Mutating commands (apply, watch, rollback) lock the state file so two veto processes never
+write it at once. A process that crashed can leave its lock behind; this command shows the
+holder and removes the lock when that process is gone.
+
+Use --force to remove a lock held by a process on another host, or one that is still running.
+With --host, the lock on the state of that fleet host is removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr, done, err := loadStateManager(cmd)
+		if err != nil {
+			pterm.Error.Println(err)
+			os.Exit(1)
+		}
+		defer done()
+
+		info, err := mgr.ReadLock()
+		if errors.Is(err, os.ErrNotExist) {
+			pterm.Info.Println("State is not locked.")
+			return
+		}
+		if err == nil {
+			pterm.Info.Printf("Lock held by %s\n", info)
+		}
+		if err == nil && !info.Stale() && !forceUnlock {
+			pterm.Error.Println("The lock holder may still be running; use --force to remove the lock anyway.")
+			os.Exit(1)
+		}
+
+		if _, err := mgr.ForceUnlock(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			pterm.Error.Printf("Failed to remove lock: %v\n", err)
+			os.Exit(1)
+		}
+		pterm.Success.Println("State lock removed.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stateUnlockCmd)
+	stateUnlockCmd.Flags().BoolVarP(&forceUnlock, "force", "f", false, "Remove the lock even if its holder may still be running")
+	addHostFlags(stateUnlockCmd)
+}
+
+// lockState takes the state lock for a mutating command, honouring --lock-timeout.
+// The returned function releases it.
+func lockState(mgr *state.Manager, command string) (func(), error) {
+	lock, err := mgr.Lock("veto "+command, lockTimeout)
+	if err != nil {
+		var locked *state.LockedError
+		if errors.As(err, &locked) && !locked.Info.Stale() && lockTimeout == 0 {
+			return nil, fmt.Errorf("%w (use --lock-timeout to wait for it)", err)
+		}
+		return nil, err
+	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			pterm.Warning.Printf("Failed to release state lock: %v\n", err)
+		}
+	}, nil
+}
@@ -16,6 +16,7 @@ type DconfAdapter struct {
 	Name   string // Key (e.g. /org/gnome/desktop/interface/color-scheme)
 	State  string // present (default) | reset
 	Params map[string]interface{}
+	Before core.Snapshot // Value before Apply: set, value
 }
 
 // NewDconfAdapter creates a new dconf adapter.
@@ -97,3 +98,41 @@ func (a *DconfAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	}
 	return core.SuccessChange(fmt.Sprintf("Set to %s", val)), nil
 }
+
+// Snapshot records the value of the key before Apply; an unset key reads as empty.
+func (a *DconfAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	output, err := ctx.Transport.Execute(ctx.Context, fmt.Sprintf("dconf read %s", a.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dconf key: %w", err)
+	}
+	value := strings.TrimSpace(output)
+	return core.Snapshot{"set": value != "", "value": value}, nil
+}
+
+func (a *DconfAdapter) SetSnapshot(s core.Snapshot) {
+	a.Before = s
+}
+
+func (a *DconfAdapter) Revert(ctx *core.SystemContext) error {
+	return a.RevertAction("applied", ctx)
+}
+
+// RevertAction writes back the value recorded before Apply, or resets the key if it
+// was unset. Without a snapshot there is nothing to go back to.
+func (a *DconfAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	if a.Before == nil {
+		ctx.Logger.Warn(fmt.Sprintf("No previous value recorded for %s. Skipping rollback.", a.Name))
+		return nil
+	}
+
+	cmd := fmt.Sprintf("dconf reset %s", a.Name)
+	if a.Before.Bool("set") {
+		// dconf read prints GVariant text, which dconf write takes back as one argument
+		value := a.Before.String("value")
+		cmd = fmt.Sprintf("dconf write %s '%s'", a.Name, strings.ReplaceAll(value, "'", `'\''`))
+	}
+	if _, err := ctx.Transport.Execute(ctx.Context, cmd); err != nil {
+		return fmt.Errorf("failed to restore dconf key %s: %w", a.Name, err)
+	}
+	return nil
+}
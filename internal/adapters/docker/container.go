@@ -18,6 +18,7 @@ type ContainerAdapter struct {
 	State   string // running, stopped, absent
 	Runtime ContainerRuntime
 	Params  map[string]interface{}
+	Before  core.Snapshot // State before Apply: exists, image, image_id, running
 }
 
 func NewDockerAdapter(name string, params map[string]interface{}, ctx *core.SystemContext) (core.Resource, error) {
@@ -158,6 +159,81 @@ func (a *ContainerAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange("Container created/recreated and started"), nil
 }
 
+// Snapshot records whether the container existed before Apply, its image and
+// whether it was running.
+func (a *ContainerAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	state, err := a.Runtime.Inspect(ctx.Context, a.Name)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return core.Snapshot{"exists": false}, nil
+	}
+	return core.Snapshot{
+		"exists":   true,
+		"image":    state.ImageName,
+		"image_id": state.ImageID,
+		"running":  state.Running,
+	}, nil
+}
+
+func (a *ContainerAdapter) SetSnapshot(s core.Snapshot) {
+	a.Before = s
+}
+
+func (a *ContainerAdapter) Revert(ctx *core.SystemContext) error {
+	return a.RevertAction("applied", ctx)
+}
+
+// RevertAction returns the container to the recorded state: a created container is
+// removed, a removed or recreated one is run again from the recorded image, and it
+// is started or stopped as it was. Ports, volumes and env come from the current
+// parameters, since only the image is recorded.
+func (a *ContainerAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	if a.Before == nil {
+		ctx.Logger.Warn(fmt.Sprintf("No previous state recorded for container %s. Skipping rollback.", a.Name))
+		return nil
+	}
+
+	state, err := a.Runtime.Inspect(ctx.Context, a.Name)
+	if err != nil {
+		return err
+	}
+
+	if !a.Before.Bool("exists") {
+		if state == nil {
+			return nil
+		}
+		return a.Runtime.Remove(ctx.Context, a.Name, true)
+	}
+
+	image := a.Before.String("image")
+	if state == nil || state.ImageName != image {
+		if state != nil {
+			if err := a.Runtime.Remove(ctx.Context, a.Name, true); err != nil {
+				return fmt.Errorf("failed to remove container %s: %w", a.Name, err)
+			}
+		}
+		config := &ContainerConfig{}
+		if _, ok := a.Params["image"].(string); ok {
+			config = a.parseConfig()
+		}
+		config.Image = image
+		if err := a.Runtime.Run(ctx.Context, a.Name, config); err != nil {
+			return fmt.Errorf("failed to run container %s from %s: %w", a.Name, image, err)
+		}
+		state = &ContainerState{Running: true, ImageName: image}
+	}
+
+	if a.Before.Bool("running") && !state.Running {
+		return a.Runtime.Start(ctx.Context, a.Name)
+	}
+	if !a.Before.Bool("running") && state.Running {
+		return a.Runtime.Stop(ctx.Context, a.Name, 10*time.Second)
+	}
+	return nil
+}
+
 func (a *ContainerAdapter) parseConfig() *ContainerConfig {
 	config := &ContainerConfig{
 		Image: a.Params["image"].(string),
@@ -27,6 +27,15 @@ type ArchiveAdapter struct {
 	Source string // Arşiv dosyasının yolu (örn: /tmp/app.zip)
 	Dest   string // Nereye açılacağı (örn: /opt/app)
 	Mode   os.FileMode
+
+	BackupPath string        // Backup of Dest taken before extracting over it
+	Before     core.Snapshot // State before Apply: exists
+
+	params map[string]interface{}
+}
+
+func (r *ArchiveAdapter) GetBackupPath() string {
+	return r.BackupPath
 }
 
 func NewArchiveAdapter(name string, params map[string]interface{}) core.Resource {
@@ -48,11 +57,15 @@ func NewArchiveAdapter(name string, params map[string]interface{}) core.Resource
 		mode = os.FileMode(int(mDouble))
 	} // TODO: Handle string octal input
 
+	backupPath, _ := params["backup_path"].(string)
+
 	return &ArchiveAdapter{
 		BaseResource: core.BaseResource{Name: name, Type: "archive"},
 		Source:       src,
 		Dest:         dest,
 		Mode:         mode,
+		BackupPath:   backupPath,
+		params:       params,
 	}
 }
 
@@ -94,6 +107,12 @@ func (r *ArchiveAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 		return core.SuccessChange(fmt.Sprintf("[DryRun] Extract %s to %s", r.Source, r.Dest)), nil
 	}
 
+	backupPath, err := core.BackupTree(ctx, r.Dest, r.params)
+	if err != nil {
+		return core.Failure(err, "Failed to back up destination"), err
+	}
+	r.BackupPath = backupPath
+
 	// Hedef klasörü oluştur
 	if err := ctx.FS.MkdirAll(r.Dest, r.Mode); err != nil {
 		return core.Failure(err, "Failed to create destination directory"), err
@@ -115,6 +134,26 @@ func (r *ArchiveAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(fmt.Sprintf("Archive extracted to %s", r.Dest)), nil
 }
 
+// Snapshot records whether the destination existed before Apply.
+func (r *ArchiveAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	return core.DirSnapshot(ctx, r.Dest)
+}
+
+func (r *ArchiveAdapter) SetSnapshot(s core.Snapshot) {
+	r.Before = s
+	if dir := s.String("dir"); dir != "" {
+		r.Dest = dir
+	}
+}
+
+func (r *ArchiveAdapter) Revert(ctx *core.SystemContext) error {
+	return core.RevertDir(ctx, r.Dest, r.BackupPath, r.Before)
+}
+
+func (r *ArchiveAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	return core.RevertDir(ctx, r.Dest, r.BackupPath, r.Before)
+}
+
 // --- Yardımcı Fonksiyonlar (FS uyumlu) ---
 
 func (r *ArchiveAdapter) unzip(ctx *core.SystemContext, src, dest string) error {
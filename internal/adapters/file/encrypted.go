@@ -0,0 +1,88 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/crypto"
+)
+
+// readSource reads a source file and decrypts it when it is an encrypted file
+// (.enc extension or encrypted file header). Reports whether it was encrypted.
+func readSource(ctx *core.SystemContext, path string) ([]byte, bool, error) {
+	data, err := ctx.FS.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !crypto.IsEncryptedFile(path, data) {
+		return data, false, nil
+	}
+
+	plain, err := decryptFile(ctx, path, data)
+	return plain, true, err
+}
+
+func decryptFile(ctx *core.SystemContext, path string, data []byte) ([]byte, error) {
+	if ctx.Keyring == nil {
+		return nil, fmt.Errorf("'%s' is encrypted but no keyring is available", path)
+	}
+	value, err := crypto.DearmorFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", path, err)
+	}
+	plain, err := ctx.Keyring.Decrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt '%s': %w", path, err)
+	}
+	return []byte(plain), nil
+}
+
+// backupEncrypted backs up path re-encrypted with the context keyring, so plaintext
+// written from an encrypted source never lands in the backup directory.
+// Returns an empty path if the file does not exist yet.
+func backupEncrypted(ctx *core.SystemContext, path string) (string, error) {
+	if _, err := ctx.FS.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	storer, ok := ctx.BackupManager.(interface {
+		StoreBackup(txID, sourcePath, suffix string, data []byte) (string, error)
+	})
+	if !ok {
+		return "", fmt.Errorf("backup manager cannot store encrypted backups")
+	}
+	if ctx.Keyring == nil {
+		return "", fmt.Errorf("no keyring available to encrypt the backup of '%s'", path)
+	}
+
+	data, err := ctx.FS.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := ctx.Keyring.Encrypt(string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	return storer.StoreBackup(ctx.TxID, path, crypto.EncryptedFileExt, crypto.ArmorFile(encrypted))
+}
+
+// restoreBackup copies a backup back to dest, decrypting encrypted (.enc) backups.
+// Backups are stored locally by the backup manager; dest is written through the
+// context filesystem, which is the target host's in fleet mode.
+func restoreBackup(ctx *core.SystemContext, backupPath, dest string, mode os.FileMode) error {
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(backupPath) == crypto.EncryptedFileExt {
+		if data, err = decryptFile(ctx, backupPath, data); err != nil {
+			return err
+		}
+	}
+	if err := ctx.FS.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return ctx.FS.WriteFile(dest, data, mode)
+}
@@ -0,0 +1,82 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/crypto"
+	"github.com/melih-ucgun/veto/internal/state"
+)
+
+// TestEncryptedSource verifies that an encrypted source is decrypted on apply and
+// that the backup of the replaced file is stored encrypted.
+func TestEncryptedSource(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	id, _ := crypto.GenerateIdentity()
+	keyring := &crypto.Keyring{Identities: []*crypto.Identity{id}}
+
+	secret := "machine example.com login me password hunter2\n"
+	value, err := keyring.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(tmpDir, "netrc.enc")
+	if err := os.WriteFile(source, crypto.ArmorFile(value), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	targetPath := filepath.Join(tmpDir, ".netrc")
+	if err := os.WriteFile(targetPath, []byte("machine old.example.com password oldpass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &core.SystemContext{
+		FS:            &core.RealFS{},
+		Logger:        core.NewDefaultLogger(os.Stderr, core.LevelDebug),
+		TxID:          "test-tx-1",
+		BackupManager: state.NewBackupManager(filepath.Join(tmpDir, "backups")),
+		Keyring:       keyring,
+	}
+
+	res := NewFileAdapter(targetPath, map[string]interface{}{"source": source})
+	if err := res.Validate(ctx); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if _, err := res.Apply(ctx); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	written, _ := os.ReadFile(targetPath)
+	if string(written) != secret {
+		t.Fatalf("Expected decrypted content, got %q", written)
+	}
+	if info, _ := os.Stat(targetPath); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 for decrypted file, got %o", info.Mode().Perm())
+	}
+
+	fRes := res.(*FileAdapter)
+	if filepath.Ext(fRes.BackupPath) != crypto.EncryptedFileExt {
+		t.Fatalf("Expected encrypted backup, got %q", fRes.BackupPath)
+	}
+	backup, _ := os.ReadFile(fRes.BackupPath)
+	if strings.Contains(string(backup), "oldpass") {
+		t.Error("Backup contains plaintext")
+	}
+
+	if needs, err := res.Check(ctx); err != nil || needs {
+		t.Errorf("Expected no change after apply: %v, %v", needs, err)
+	}
+
+	fRes.ActionPerformed = "modified"
+	if err := fRes.Revert(ctx); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	restored, _ := os.ReadFile(targetPath)
+	if string(restored) != "machine old.example.com password oldpass\n" {
+		t.Errorf("Revert restored %q", restored)
+	}
+}
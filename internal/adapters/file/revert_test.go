@@ -208,3 +208,89 @@ func TestRevert_Modification_NoBackup(t *testing.T) {
 	content, _ := os.ReadFile(targetPath)
 	t.Logf("File content after revert: %s", string(content))
 }
+
+// TestRevert_Snapshot verifies that rollback uses the state recorded before Apply.
+func TestRevert_Snapshot(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := &core.SystemContext{
+		FS:     &core.RealFS{},
+		Logger: core.NewDefaultLogger(os.Stderr, core.LevelDebug),
+	}
+
+	// A file that did not exist is deleted, even when rolled back from history
+	created := filepath.Join(tmpDir, "created.txt")
+	res := NewFileAdapter(created, map[string]interface{}{"content": "new"}).(*FileAdapter)
+	snap, err := res.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.Apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rolled := NewFileAdapter(created, nil).(*FileAdapter)
+	rolled.SetSnapshot(snap)
+	if err := rolled.RevertAction("applied", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("File created by apply was not deleted")
+	}
+
+	// An existing file gets its mode back
+	existing := filepath.Join(tmpDir, "existing.txt")
+	os.WriteFile(existing, []byte("old"), 0600)
+	res = NewFileAdapter(existing, map[string]interface{}{"content": "new"}).(*FileAdapter)
+	snap, _ = res.Snapshot(ctx)
+	if snap.String("mode") != "0600" {
+		t.Fatalf("Unexpected snapshot: %v", snap)
+	}
+	res.SetSnapshot(snap)
+	if _, err := res.Apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(existing, 0644)
+	if err := res.Revert(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0600 {
+		t.Errorf("Mode not restored: %v", info.Mode().Perm())
+	}
+}
+
+// TestRevert_Directory verifies that a removed directory is backed up as a tree and
+// restored on revert.
+func TestRevert_Directory(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := &core.SystemContext{
+		FS:            &core.RealFS{},
+		Logger:        core.NewDefaultLogger(os.Stderr, core.LevelDebug),
+		TxID:          "tx-dir",
+		BackupManager: state.NewBackupManager(filepath.Join(tmpDir, "backups")),
+	}
+
+	dir := filepath.Join(tmpDir, "conf.d")
+	os.MkdirAll(filepath.Join(dir, "sub"), 0750)
+	os.WriteFile(filepath.Join(dir, "sub", "a.conf"), []byte("a"), 0600)
+
+	res := NewFileAdapter(dir, map[string]interface{}{"state": "absent"}).(*FileAdapter)
+	if _, err := res.Apply(ctx); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("Directory was not removed")
+	}
+	if !core.IsTreeBackup(res.BackupPath) {
+		t.Fatalf("Expected a tree backup, got '%s'", res.BackupPath)
+	}
+
+	if err := res.Revert(ctx); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sub", "a.conf"))
+	if err != nil || string(data) != "a" {
+		t.Fatalf("Directory not restored: %v", err)
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0750 {
+		t.Errorf("Restored directory mode %o, want 0750", info.Mode().Perm())
+	}
+}
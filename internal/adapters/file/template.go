@@ -8,6 +8,7 @@ import (
 	"text/template"
 
 	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/crypto"
 )
 
 func init() {
@@ -23,6 +24,7 @@ type TemplateAdapter struct {
 	Vars       map[string]interface{} // Template içine gönderilecek değişkenler
 	Mode       os.FileMode
 	BackupPath string
+	Encrypted  bool // Src is an encrypted file, decrypted before rendering
 }
 
 func NewTemplateAdapter(name string, params map[string]interface{}) core.Resource {
@@ -63,6 +65,9 @@ func (r *TemplateAdapter) Validate(ctx *core.SystemContext) error {
 	if _, err := ctx.FS.Stat(r.Src); os.IsNotExist(err) {
 		return fmt.Errorf("template source file '%s' does not exist", r.Src)
 	}
+	if data, err := ctx.FS.ReadFile(r.Src); err == nil && crypto.IsEncryptedFile(r.Src, data) {
+		r.Encrypted = true
+	}
 
 	return nil
 }
@@ -120,7 +125,13 @@ func (r *TemplateAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 
 	// YEDEKLEME
 	if ctx.BackupManager != nil && ctx.TxID != "" {
-		backupPath, err := ctx.BackupManager.CreateBackup(ctx.TxID, r.Dest)
+		var backupPath string
+		var err error
+		if r.Encrypted {
+			backupPath, err = backupEncrypted(ctx, r.Dest)
+		} else {
+			backupPath, err = ctx.BackupManager.CreateBackup(ctx.TxID, r.Dest)
+		}
 		if err == nil {
 			r.BackupPath = backupPath
 		}
@@ -147,20 +158,20 @@ func (r *TemplateAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 
 func (r *TemplateAdapter) Revert(ctx *core.SystemContext) error {
 	if r.BackupPath != "" {
-		return core.CopyFile(ctx.FS, r.BackupPath, r.Dest, r.Mode)
+		return restoreBackup(ctx, r.BackupPath, r.Dest, r.Mode)
 	}
 	return ctx.FS.Remove(r.Dest)
 }
 
 func (r *TemplateAdapter) render(ctx *core.SystemContext) (string, error) {
 	// Template dosyasını oku
-	tmplContent, err := ctx.FS.ReadFile(r.Src)
+	tmplContent, _, err := readSource(ctx, r.Src)
 	if err != nil {
 		return "", err
 	}
 
 	// Parse et
-	t, err := template.New(filepath.Base(r.Src)).Parse(string(tmplContent))
+	t, err := template.New(filepath.Base(r.Src)).Funcs(core.TemplateFuncs(ctx)).Parse(string(tmplContent))
 	if err != nil {
 		return "", err
 	}
@@ -18,23 +18,31 @@ type FontAdapter struct {
 	Source string // URL to zip/tar
 	System bool   // System-wide install?
 	Params map[string]interface{}
+
+	BackupPath string        // Backup of the destination taken before installing over it
+	Before     core.Snapshot // State before Apply: exists, dir
 }
 
 func NewFontAdapter(name string, params map[string]interface{}, ctx *core.SystemContext) (core.Resource, error) {
 	source, _ := params["source"].(string)
 	system, _ := params["system"].(bool)
 
+	backupPath, _ := params["backup_path"].(string)
+
 	return &FontAdapter{
-		Name:   name,
-		Source: source,
-		System: system,
-		Params: params,
+		Name:       name,
+		Source:     source,
+		System:     system,
+		Params:     params,
+		BackupPath: backupPath,
 	}, nil
 }
 
 func (a *FontAdapter) GetName() string { return a.Name }
 func (a *FontAdapter) GetType() string { return "font" }
 
+func (a *FontAdapter) GetBackupPath() string { return a.BackupPath }
+
 func (a *FontAdapter) Validate(ctx *core.SystemContext) error {
 	if a.Source == "" {
 		return fmt.Errorf("source url is required for font %s", a.Name)
@@ -43,6 +51,9 @@ func (a *FontAdapter) Validate(ctx *core.SystemContext) error {
 }
 
 func (a *FontAdapter) getDestPath(ctx *core.SystemContext) string {
+	if dir := a.Before.String("dir"); dir != "" {
+		return dir // Where it was installed, when rolling back
+	}
 	if a.System {
 		return filepath.Join("/usr/share/fonts", a.Name)
 	}
@@ -94,6 +105,13 @@ func (a *FontAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 		return core.Failure(err, "Failed to copy font to target"), err
 	}
 
+	// Files already in the destination are overwritten
+	backupPath, err := core.BackupTree(ctx, dest, a.Params)
+	if err != nil {
+		return core.Failure(err, "Failed to back up destination"), err
+	}
+	a.BackupPath = backupPath
+
 	// 3. Prepare Commands
 	// Ensure unzip installed?
 	// Extract
@@ -131,3 +149,20 @@ func (a *FontAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 
 	return core.SuccessChange(fmt.Sprintf("Font %s installed to %s", a.Name, dest)), nil
 }
+
+// Snapshot records whether the destination existed before Apply.
+func (a *FontAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	return core.DirSnapshot(ctx, a.getDestPath(ctx))
+}
+
+func (a *FontAdapter) SetSnapshot(s core.Snapshot) {
+	a.Before = s
+}
+
+func (a *FontAdapter) Revert(ctx *core.SystemContext) error {
+	return core.RevertDir(ctx, a.getDestPath(ctx), a.BackupPath, a.Before)
+}
+
+func (a *FontAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	return core.RevertDir(ctx, a.getDestPath(ctx), a.BackupPath, a.Before)
+}
@@ -27,9 +27,17 @@ type GitAdapter struct {
 	Commit      string
 	Remote      string
 	Update      bool
-	State       string // present (clone/pull), absent (delete)
-	PreviousSHA string // Rollback için
-	IsNew       bool   // Yeni klonlandı mı?
+	State       string        // present (clone/pull), absent (delete)
+	PreviousSHA string        // Rollback için
+	IsNew       bool          // Yeni klonlandı mı?
+	Before      core.Snapshot // State before Apply: cloned, sha, branch
+	BackupPath  string        // Backup of Dest taken before it was removed
+
+	params map[string]interface{}
+}
+
+func (r *GitAdapter) GetBackupPath() string {
+	return r.BackupPath
 }
 
 func NewGitAdapter(name string, params map[string]interface{}) core.Resource {
@@ -45,6 +53,7 @@ func NewGitAdapter(name string, params map[string]interface{}) core.Resource {
 		update = u
 	}
 
+	backupPath, _ := params["backup_path"].(string)
 	remote, _ := params["remote"].(string)
 	if remote == "" {
 		remote = "origin"
@@ -67,6 +76,8 @@ func NewGitAdapter(name string, params map[string]interface{}) core.Resource {
 		Remote:       remote,
 		Update:       update,
 		State:        state,
+		BackupPath:   backupPath,
+		params:       params,
 	}
 }
 
@@ -175,6 +186,12 @@ func (r *GitAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	}
 
 	if r.State == "absent" {
+		// The working tree may hold changes that exist nowhere else
+		backupPath, err := core.BackupTree(ctx, r.Dest, r.params)
+		if err != nil {
+			return core.Failure(err, "Failed to back up repository"), err
+		}
+		r.BackupPath = backupPath
 		if err := ctx.FS.RemoveAll(r.Dest); err != nil {
 			return core.Failure(err, "Failed to remove directory"), err
 		}
@@ -254,7 +271,86 @@ func (r *GitAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange("Git repo updated/checked out"), nil
 }
 
+// Snapshot records whether the repository was cloned before Apply, its HEAD SHA and
+// the branch checked out ("HEAD" when detached), along with its URL and destination
+// for a rollback that only knows the resource name.
+func (r *GitAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	if !r.isGitRepo(ctx, r.Dest) {
+		return core.Snapshot{"cloned": false, "repo": r.Repo, "dest": r.Dest}, nil
+	}
+	sha, err := getHeadSHA(ctx, r.Dest)
+	if err != nil {
+		return nil, err
+	}
+	branch, _ := getCurrentBranch(ctx, r.Dest)
+	return core.Snapshot{"cloned": true, "sha": sha, "branch": branch, "repo": r.Repo, "dest": r.Dest}, nil
+}
+
+func (r *GitAdapter) SetSnapshot(s core.Snapshot) {
+	r.Before = s
+	if r.Repo == "" {
+		r.Repo = s.String("repo")
+	}
+	if r.Dest == "" {
+		r.Dest = s.String("dest")
+	}
+}
+
+func (r *GitAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	if r.Before == nil {
+		pterm.Warning.Printf("No previous state recorded for %s. Skipping rollback.\n", r.Dest)
+		return nil
+	}
+	return r.revertSnapshot(ctx)
+}
+
+// revertSnapshot returns the repository to the recorded state: a fresh clone is
+// removed, a removed repository is restored from its backup (or cloned again), and
+// the recorded branch is reset to the recorded SHA.
+func (r *GitAdapter) revertSnapshot(ctx *core.SystemContext) error {
+	if !r.Before.Bool("cloned") {
+		pterm.Warning.Printf("Reverting git clone: removing %s\n", r.Dest)
+		return ctx.FS.RemoveAll(r.Dest)
+	}
+
+	sha := r.Before.String("sha")
+	if !r.isGitRepo(ctx, r.Dest) && r.BackupPath != "" {
+		// The backup holds the working tree as it was, uncommitted changes included
+		return core.RestoreTree(ctx, r.BackupPath, r.Dest)
+	}
+	if !r.isGitRepo(ctx, r.Dest) {
+		out, err := ctx.Transport.Execute(ctx.Context, fmt.Sprintf("git clone %s %s", r.Repo, r.Dest))
+		if err != nil {
+			return fmt.Errorf("failed to clone %s again: %s: %w", r.Repo, out, err)
+		}
+	}
+
+	branch := r.Before.String("branch")
+	if branch == "" || branch == "HEAD" {
+		if err := checkout(ctx, r.Dest, sha); err != nil {
+			return fmt.Errorf("failed to revert git repo to %s: %w", sha, err)
+		}
+		return nil
+	}
+	if err := checkout(ctx, r.Dest, branch); err != nil {
+		return fmt.Errorf("failed to revert git repo to branch %s: %w", branch, err)
+	}
+	fullCmd := fmt.Sprintf("git -C %s reset --hard %s", r.Dest, sha)
+	if out, err := ctx.Transport.Execute(ctx.Context, fullCmd); err != nil {
+		return fmt.Errorf("failed to reset %s to %s: %s: %w", branch, sha, out, err)
+	}
+	return nil
+}
+
 func (r *GitAdapter) Revert(ctx *core.SystemContext) error {
+	if r.Before != nil {
+		return r.revertSnapshot(ctx)
+	}
+
+	if r.BackupPath != "" && !r.isGitRepo(ctx, r.Dest) {
+		return core.RestoreTree(ctx, r.BackupPath, r.Dest)
+	}
+
 	// Yeni klonlandıysa sil
 	if r.IsNew {
 		pterm.Warning.Printf("Reverting git clone: removing %s\n", r.Dest)
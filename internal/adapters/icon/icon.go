@@ -18,23 +18,31 @@ type IconAdapter struct {
 	Source string
 	System bool
 	Params map[string]interface{}
+
+	BackupPath string        // Backup of the destination taken before installing over it
+	Before     core.Snapshot // State before Apply: exists, dir
 }
 
 func NewIconAdapter(name string, params map[string]interface{}, ctx *core.SystemContext) (core.Resource, error) {
 	source, _ := params["source"].(string)
 	system, _ := params["system"].(bool)
 
+	backupPath, _ := params["backup_path"].(string)
+
 	return &IconAdapter{
-		Name:   name,
-		Source: source,
-		System: system,
-		Params: params,
+		Name:       name,
+		Source:     source,
+		System:     system,
+		Params:     params,
+		BackupPath: backupPath,
 	}, nil
 }
 
 func (a *IconAdapter) GetName() string { return a.Name }
 func (a *IconAdapter) GetType() string { return "icon" }
 
+func (a *IconAdapter) GetBackupPath() string { return a.BackupPath }
+
 func (a *IconAdapter) Validate(ctx *core.SystemContext) error {
 	if a.Source == "" {
 		return fmt.Errorf("source url is required for icon %s", a.Name)
@@ -43,6 +51,9 @@ func (a *IconAdapter) Validate(ctx *core.SystemContext) error {
 }
 
 func (a *IconAdapter) getDestPath(ctx *core.SystemContext) string {
+	if dir := a.Before.String("dir"); dir != "" {
+		return dir // Where it was installed, when rolling back
+	}
 	if a.System {
 		return filepath.Join("/usr/share/icons", a.Name)
 	}
@@ -81,6 +92,13 @@ func (a *IconAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 		return core.Failure(err, "Failed to copy icon to target"), err
 	}
 
+	// Files already in the destination are overwritten
+	backupPath, err := core.BackupTree(ctx, dest, a.Params)
+	if err != nil {
+		return core.Failure(err, "Failed to back up destination"), err
+	}
+	a.BackupPath = backupPath
+
 	// 3. Extract & Move
 	tmpExtract := fmt.Sprintf("/tmp/veto_extract_icon_%s", a.Name)
 
@@ -158,3 +176,20 @@ func (a *IconAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 
 	return core.SuccessChange(fmt.Sprintf("Icon theme %s installed", a.Name)), nil
 }
+
+// Snapshot records whether the destination existed before Apply.
+func (a *IconAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	return core.DirSnapshot(ctx, a.getDestPath(ctx))
+}
+
+func (a *IconAdapter) SetSnapshot(s core.Snapshot) {
+	a.Before = s
+}
+
+func (a *IconAdapter) Revert(ctx *core.SystemContext) error {
+	return core.RevertDir(ctx, a.getDestPath(ctx), a.BackupPath, a.Before)
+}
+
+func (a *IconAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	return core.RevertDir(ctx, a.getDestPath(ctx), a.BackupPath, a.Before)
+}
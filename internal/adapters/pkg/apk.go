@@ -2,13 +2,19 @@ package pkg
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/melih-ucgun/veto/internal/core"
 )
 
+// apkCacheDir is the apk package cache.
+const apkCacheDir = "/var/cache/apk"
+
 type ApkAdapter struct {
 	core.BaseResource
-	State string
+	State           string
+	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -58,34 +64,49 @@ func (r *ApkAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	var args []string
 	if r.State == "absent" {
 		args = []string{"del", r.Name}
+		r.ActionPerformed = "removed"
 	} else {
 		args = []string{"add", r.Name}
+		r.ActionPerformed = "installed"
 	}
 
 	out, err := runCommand(ctx, "apk", args...)
 	if err != nil {
+		r.ActionPerformed = ""
 		return core.Failure(err, "Apk failed: "+out), err
 	}
 
 	return core.SuccessChange(fmt.Sprintf("Apk processed %s", r.Name)), nil
 }
 
-func (r *ApkAdapter) Revert(ctx *core.SystemContext) error {
-	// Revert işlemini yapmamız için, az önce yaptığımız işlemin tersini yapmalıyız.
-	// Eğer State="present" ise -> "absent" yap (sil)
-	// Eğer State="absent" ise -> "present" yap (kur)
+// Snapshot records whether the package is installed and its version.
+func (r *ApkAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	// apk list -I prints e.g. "curl-8.5.0-r0 x86_64 {curl} (curl) [installed]"
+	out, err := runCommand(ctx, "apk", "list", "-I", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(strings.TrimPrefix(field(out, 0), r.Name+"-")), nil
+}
 
-	// TODO: Bu mantık "önceden sistemde var mıydı?" kontrolünü içermiyor.
-	// İdeal dünyada, "önceden yoktu, ben kurdum, şimdi siliyorum" demeliyiz.
-	// Ama basit atomic rollback için ters işlem şimdilik yeterli.
+func (r *ApkAdapter) Revert(ctx *core.SystemContext) error {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
 
+func (r *ApkAdapter) RevertAction(action string, ctx *core.SystemContext) error {
 	var args []string
-	if r.State == "present" {
+	switch r.revertAction(action) {
+	case "installed":
 		// Biz kurduk, geri alırken siliyoruz
 		args = []string{"del", r.Name}
-	} else {
-		// Biz sildik, geri alırken kuruyoruz
-		args = []string{"add", r.Name}
+	case "removed":
+		// Biz sildik, geri alırken kayıtlı sürümü kuruyoruz
+		if ctx.DryRun {
+			return nil
+		}
+		return r.reinstall(ctx, r, r.Name, r.versionSources(ctx), "apk add "+r.Name)
+	default:
+		return nil
 	}
 
 	if ctx.DryRun {
@@ -99,6 +120,28 @@ func (r *ApkAdapter) Revert(ctx *core.SystemContext) error {
 	return nil
 }
 
+// versionSources returns the ways to install the version recorded before Apply:
+// the .apk in the apk cache (/etc/apk/cache points to it when enabled),
+// then that version from the repositories.
+func (r *ApkAdapter) versionSources(ctx *core.SystemContext) []installSource {
+	version := r.Before.String("version")
+	if version == "" {
+		return nil
+	}
+	var sources []installSource
+	// Cached files are named <name>-<version>.<hash>.apk
+	file := findCached(ctx, apkCacheDir, "", func(f string) bool {
+		return strings.HasPrefix(f, r.Name+"-"+version+".") && strings.HasSuffix(f, ".apk")
+	})
+	if file != "" {
+		sources = append(sources, installSource{From: file, Cmd: "apk add " + file})
+	}
+	return append(sources, installSource{
+		From: "the repositories",
+		Cmd:  fmt.Sprintf("apk add %s=%s", r.Name, version),
+	})
+}
+
 func (r *ApkAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	// apk info: lists all installed packages
 	output, err := runCommand(ctx, "apk", "info")
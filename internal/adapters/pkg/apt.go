@@ -2,14 +2,19 @@ package pkg
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/melih-ucgun/veto/internal/core"
 )
 
+// aptArchiveDir holds the .deb files apt downloaded.
+const aptArchiveDir = "/var/cache/apt/archives"
+
 type AptAdapter struct {
 	core.BaseResource
 	State           string
 	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -77,21 +82,54 @@ func (r *AptAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(fmt.Sprintf("Apt processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *AptAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, _ := runCommand(ctx, "dpkg-query", "-W", "-f='${Status} ${Version}'", r.Name)
+	// Removed packages whose config files are left behind are still listed
+	version, ok := strings.CutPrefix(strings.TrimSpace(out), "install ok installed ")
+	if !ok {
+		version = ""
+	}
+	return versionSnapshot(version), nil
+}
+
 func (r *AptAdapter) Revert(ctx *core.SystemContext) error {
 	return r.RevertAction(r.ActionPerformed, ctx)
 }
 
 func (r *AptAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	action = r.revertAction(action)
 	if action == "installed" {
 		_, err := runCommand(ctx, "apt-get", "remove", "-y", r.Name)
 		return err
 	} else if action == "removed" {
-		_, err := runCommand(ctx, "apt-get", "install", "-y", r.Name)
-		return err
+		return r.reinstall(ctx, r, r.Name, r.versionSources(ctx), "apt-get install -y "+r.Name)
 	}
 	return nil
 }
 
+// versionSources returns the ways to install the version recorded before Apply:
+// the .deb in the apt archives, then that version from the repositories.
+func (r *AptAdapter) versionSources(ctx *core.SystemContext) []installSource {
+	version := r.Before.String("version")
+	if version == "" {
+		return nil
+	}
+	var sources []installSource
+	// Archive file names escape the epoch colon
+	prefix := r.Name + "_" + strings.ReplaceAll(version, ":", "%3a") + "_"
+	deb := findCached(ctx, aptArchiveDir, "", func(f string) bool {
+		return strings.HasPrefix(f, prefix) && strings.HasSuffix(f, ".deb")
+	})
+	if deb != "" {
+		sources = append(sources, installSource{From: deb, Cmd: "dpkg -i " + deb})
+	}
+	return append(sources, installSource{
+		From: "the repositories",
+		Cmd:  fmt.Sprintf("apt-get install -y --allow-downgrades %s=%s", r.Name, version),
+	})
+}
+
 func (r *AptAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	// apt-mark showmanual: lists explicitly installed packages
 	output, err := runCommand(ctx, "apt-mark", "showmanual")
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -133,3 +134,53 @@ func TestAptAdapter_Apply(t *testing.T) {
 		}
 	})
 }
+
+func TestAptAdapter_SnapshotRevert(t *testing.T) {
+	installed := "install ok installed 1.6-2"
+	var executed []string
+	mockTr := &MockTransport{
+		ExecuteFunc: func(ctx context.Context, cmd string) (string, error) {
+			if strings.HasPrefix(cmd, "dpkg-query") {
+				return installed, nil
+			}
+			executed = append(executed, cmd)
+			installed = "install ok installed 1.6-2"
+			return "", nil
+		},
+	}
+	ctx := core.NewSystemContext(false, mockTr)
+	var logs bytes.Buffer
+	ctx.Logger = core.NewDefaultLogger(&logs, core.LevelDebug)
+
+	adapter := NewAptAdapter("jq", map[string]interface{}{"state": "absent"}).(*AptAdapter)
+	snap, err := adapter.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snap.Bool("installed") || snap.String("version") != "1.6-2" {
+		t.Fatalf("Unexpected snapshot: %v", snap)
+	}
+
+	// Rollback of a generic "applied" change reinstalls the recorded version
+	installed = "deinstall ok config-files 1.6-2"
+	rolled := NewAptAdapter("jq", nil).(*AptAdapter)
+	rolled.SetSnapshot(snap)
+	if err := rolled.RevertAction("applied", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(executed) != 1 || executed[0] != "apt-get install -y --allow-downgrades jq=1.6-2" {
+		t.Errorf("Unexpected commands: %v", executed)
+	}
+	if !strings.Contains(logs.String(), `msg="Restored jq 1.6-2 from `) || strings.Contains(logs.String(), "BADKEY") {
+		t.Errorf("Unexpected log: %s", logs.String())
+	}
+
+	// Nothing to do when the recorded version is installed
+	executed = nil
+	if err := rolled.RevertAction("applied", ctx); err != nil || len(executed) != 0 {
+		t.Errorf("Expected no commands, got %v (%v)", executed, err)
+	}
+	if !strings.Contains(logs.String(), `msg="jq 1.6-2 is already installed"`) {
+		t.Errorf("Unexpected log: %s", logs.String())
+	}
+}
@@ -8,7 +8,9 @@ import (
 
 type BrewAdapter struct {
 	core.BaseResource
-	State string
+	State           string
+	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -58,18 +60,46 @@ func (r *BrewAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	var args []string
 	if r.State == "absent" {
 		args = []string{"uninstall", r.Name}
+		r.ActionPerformed = "removed"
 	} else {
 		args = []string{"install", r.Name}
+		r.ActionPerformed = "installed"
 	}
 
 	out, err := runCommand(ctx, "brew", args...)
 	if err != nil {
+		r.ActionPerformed = ""
 		return core.Failure(err, "Brew failed: "+out), err
 	}
 
 	return core.SuccessChange(fmt.Sprintf("Brew processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *BrewAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "brew", "list", "--versions", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(field(out, 1)), nil
+}
+
+func (r *BrewAdapter) Revert(ctx *core.SystemContext) error {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *BrewAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
+		_, err := runCommand(ctx, "brew", "uninstall", r.Name)
+		return err
+	case "removed":
+		// Homebrew only installs the current version of a formula
+		return r.reinstall(ctx, r, r.Name, nil, "brew install "+r.Name)
+	}
+	return nil
+}
+
 func (r *BrewAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	// brew leaves: lists packages that are not dependencies of other packages
 	output, err := runCommand(ctx, "brew", "leaves")
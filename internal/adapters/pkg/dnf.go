@@ -2,6 +2,8 @@ package pkg
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/melih-ucgun/veto/internal/core"
 )
@@ -10,6 +12,7 @@ type DnfAdapter struct {
 	core.BaseResource
 	State           string
 	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -75,17 +78,109 @@ func (r *DnfAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(fmt.Sprintf("Dnf processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version. For an
+// installed package it also records the newest dnf history transaction, which
+// tells the transaction of Apply apart from older ones on rollback.
+func (r *DnfAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "rpm", "-q", "--qf", "'%{VERSION}-%{RELEASE}'", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	snap := versionSnapshot(out)
+	if txs := dnfHistory(ctx); len(txs) > 0 {
+		snap["dnf_history"] = strconv.Itoa(txs[0].ID)
+	}
+	return snap, nil
+}
+
 func (r *DnfAdapter) Revert(ctx *core.SystemContext) error {
-	if r.ActionPerformed == "installed" {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *DnfAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
 		_, err := runCommand(ctx, "dnf", "remove", "-y", r.Name)
 		return err
-	} else if r.ActionPerformed == "removed" {
-		_, err := runCommand(ctx, "dnf", "install", "-y", r.Name)
-		return err
+	case "removed":
+		return r.reinstall(ctx, r, r.Name, r.versionSources(ctx), "dnf install -y "+r.Name)
 	}
 	return nil
 }
 
+// versionSources returns the ways to install the version recorded before Apply:
+// the rpm in the dnf cache (kept with keepcache=1), that version from the
+// repositories, then undoing the dnf transaction of Apply.
+func (r *DnfAdapter) versionSources(ctx *core.SystemContext) []installSource {
+	version := r.Before.String("version")
+	if version == "" {
+		return nil
+	}
+	var sources []installSource
+	rpm := findCached(ctx, "/var/cache/dnf", "packages", func(f string) bool {
+		return strings.HasPrefix(f, r.Name+"-"+version+".") && strings.HasSuffix(f, ".rpm")
+	})
+	if rpm != "" {
+		sources = append(sources, installSource{From: rpm, Cmd: "dnf install -y " + rpm})
+	}
+	sources = append(sources, installSource{
+		From: "the repositories",
+		Cmd:  fmt.Sprintf("dnf install -y %s-%s", r.Name, version),
+	})
+	if id, ok := r.applyTransaction(ctx); ok {
+		sources = append(sources, installSource{
+			From: fmt.Sprintf("dnf history (transaction %d)", id),
+			Cmd:  fmt.Sprintf("dnf history undo -y %d", id),
+		})
+	}
+	return sources
+}
+
+// applyTransaction returns the dnf transaction in which Apply removed the package:
+// the newest transaction that touched it, if it is newer than the one recorded
+// in the snapshot and its command line is the one Apply runs. Undoing any other
+// transaction could revert changes veto didn't make.
+func (r *DnfAdapter) applyTransaction(ctx *core.SystemContext) (int, bool) {
+	before, err := strconv.Atoi(r.Before.String("dnf_history"))
+	if err != nil {
+		return 0, false
+	}
+	txs := dnfHistory(ctx, r.Name)
+	if len(txs) == 0 || txs[0].ID <= before || txs[0].Command != "remove -y "+r.Name {
+		return 0, false
+	}
+	return txs[0].ID, true
+}
+
+// dnfTransaction is a row of `dnf history list`.
+type dnfTransaction struct {
+	ID      int
+	Command string
+}
+
+// dnfHistory returns the dnf history transactions, newest first, optionally only
+// those that touched the given packages.
+func dnfHistory(ctx *core.SystemContext, pkgs ...string) []dnfTransaction {
+	out, err := runCommand(ctx, "dnf", append([]string{"history", "list"}, pkgs...)...)
+	if err != nil {
+		return nil
+	}
+	// Rows are "ID | Command line | Date and time | Action(s) | Altered"
+	var txs []dnfTransaction
+	for _, line := range splitLines(out) {
+		cols := strings.Split(line, "|")
+		if len(cols) < 2 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(cols[0]))
+		if err != nil {
+			continue
+		}
+		txs = append(txs, dnfTransaction{ID: id, Command: strings.TrimSpace(cols[1])})
+	}
+	return txs
+}
+
 func (r *DnfAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	// rpm -qa --qf "%{NAME}\n" lists all installed package names
 	output, err := runCommand(ctx, "rpm", "-qa", "--qf", "%{NAME}\n")
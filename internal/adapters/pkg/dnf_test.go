@@ -133,3 +133,50 @@ func TestDnfAdapter_Apply(t *testing.T) {
 		}
 	})
 }
+
+func TestDnfAdapter_HistoryUndo(t *testing.T) {
+	history := `ID     | Command line             | Date and time    | Action(s)      | Altered
+-------------------------------------------------------------------------------
+    13 | remove -y vetotest       | 2024-05-01 10:00 | Removed        |    1
+    12 | install vetotest         | 2024-04-01 09:00 | Install        |    1`
+
+	run := func(before core.Snapshot, history string) []string {
+		var executed []string
+		mockTr := &MockTransport{
+			ExecuteFunc: func(ctx context.Context, cmd string) (string, error) {
+				switch {
+				case strings.HasPrefix(cmd, "rpm -q"):
+					return "", errors.New("not installed")
+				case cmd == "dnf history list vetotest":
+					return history, nil
+				case cmd == "dnf install -y vetotest-1.0-1":
+					return "No match for argument", errors.New("exit status 1")
+				}
+				executed = append(executed, cmd)
+				return "", nil
+			},
+		}
+		adapter := NewDnfAdapter("vetotest", nil).(*DnfAdapter)
+		adapter.SetSnapshot(before)
+		if err := adapter.RevertAction("applied", core.NewSystemContext(false, mockTr)); err != nil {
+			t.Fatal(err)
+		}
+		return executed
+	}
+
+	// The transaction of Apply is undone when the recorded version is gone
+	before := core.Snapshot{"installed": true, "version": "1.0-1", "dnf_history": "12"}
+	if got := run(before, history); len(got) != 1 || got[0] != "dnf history undo -y 13" {
+		t.Errorf("Unexpected commands: %v", got)
+	}
+
+	// A transaction veto didn't run, or one older than the snapshot, is left alone
+	other := strings.Replace(history, "remove -y vetotest", "remove vetotest", 1)
+	if got := run(before, other); len(got) != 1 || got[0] != "dnf install -y vetotest" {
+		t.Errorf("Unexpected commands: %v", got)
+	}
+	before["dnf_history"] = "13"
+	if got := run(before, history); len(got) != 1 || got[0] != "dnf install -y vetotest" {
+		t.Errorf("Unexpected commands: %v", got)
+	}
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/melih-ucgun/veto/internal/core"
 )
@@ -10,6 +11,7 @@ type FlatpakAdapter struct {
 	core.BaseResource
 	State           string
 	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -75,10 +77,38 @@ func (r *FlatpakAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(fmt.Sprintf("Flatpak processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *FlatpakAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "flatpak", "info", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	snap := core.Snapshot{"installed": true}
+	for _, line := range splitLines(out) {
+		if v, ok := strings.CutPrefix(line, "Version:"); ok {
+			snap["version"] = strings.TrimSpace(v)
+		} else if c, ok := strings.CutPrefix(line, "Commit:"); ok {
+			snap["commit"] = strings.TrimSpace(c)
+		}
+	}
+	return snap, nil
+}
+
 func (r *FlatpakAdapter) Revert(ctx *core.SystemContext) error {
-	if r.ActionPerformed == "installed" {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *FlatpakAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
 		_, err := runCommand(ctx, "flatpak", "uninstall", "-y", r.Name)
 		return err
+	case "removed":
+		var sources []installSource
+		if commit := r.Before.String("commit"); commit != "" {
+			sources = append(sources, installSource{From: "commit " + commit, Cmd: fmt.Sprintf("flatpak update -y --commit=%s %s", commit, r.Name)})
+		}
+		return r.reinstall(ctx, r, r.Name, sources, "flatpak install -y "+r.Name)
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/melih-ucgun/veto/internal/core"
@@ -24,18 +25,19 @@ func DetectPackageManager(name string, params map[string]interface{}, ctx *core.
 		state = "present"
 	}
 
-	switch ctx.Distro {
-	case "arch", "cachyos", "manjaro", "endeavouros":
+	// Select by family (ID + ID_LIKE) so derivatives like Garuda or Zorin work
+	switch ctx.DistroFamily() {
+	case core.FamilyArch:
 		return NewPacmanAdapter(pkgName, params), nil
-	case "ubuntu", "debian", "pop", "mint", "kali":
+	case core.FamilyDebian:
 		return NewAptAdapter(pkgName, params), nil
-	case "fedora", "rhel", "centos", "almalinux":
+	case core.FamilyRHEL:
 		return NewDnfAdapter(pkgName, params), nil
-	case "alpine":
+	case core.FamilyAlpine:
 		return NewApkAdapter(pkgName, params), nil
-	case "opensuse", "sles":
+	case core.FamilySUSE:
 		return NewZypperAdapter(pkgName, params), nil
-	case "darwin":
+	case core.FamilyDarwin:
 		return NewBrewAdapter(pkgName, params), nil
 	default:
 		// Fallback to searching available commands
@@ -81,3 +83,127 @@ func splitLines(s string) []string {
 	}
 	return lines
 }
+
+// pkgSnapshot is embedded by the package adapters. It holds whether the package was
+// installed before Apply, and at which version.
+type pkgSnapshot struct {
+	Before core.Snapshot
+}
+
+func (p *pkgSnapshot) SetSnapshot(s core.Snapshot) {
+	p.Before = s
+}
+
+// revertAction returns the action that has to be undone to get back to the
+// snapshot: "installed" if the package was absent before, "removed" if it was
+// present, in which case the recorded version is reinstalled. Without a snapshot
+// the recorded action is returned unchanged.
+func (p *pkgSnapshot) revertAction(action string) string {
+	if p.Before == nil {
+		return action
+	}
+	if p.Before.Bool("installed") {
+		return "removed"
+	}
+	return "installed"
+}
+
+// versionSnapshot builds the snapshot of a package from its installed version;
+// an empty version means the package is not installed.
+func versionSnapshot(version string) core.Snapshot {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return core.Snapshot{"installed": false}
+	}
+	return core.Snapshot{"installed": true, "version": version}
+}
+
+// field returns the n-th whitespace separated field of the first line of out.
+func field(out string, n int) string {
+	lines := splitLines(out)
+	if len(lines) == 0 {
+		return ""
+	}
+	fields := strings.Fields(lines[0])
+	if n >= len(fields) {
+		return ""
+	}
+	return fields[n]
+}
+
+// installSource is one way to install the recorded version of a package.
+type installSource struct {
+	From string // Where the version comes from, for the log
+	Cmd  string
+}
+
+// reinstall brings back the package recorded before Apply. The sources that can
+// install the exact recorded version are tried first, then fallback (the plain
+// install command). The version that ends up installed is logged, with a warning
+// when it isn't the recorded one.
+func (p *pkgSnapshot) reinstall(ctx *core.SystemContext, res core.Snapshotter, name string, sources []installSource, fallback string) error {
+	version := p.Before.String("version")
+	if version != "" {
+		if now, err := res.Snapshot(ctx); err == nil && now.String("version") == version {
+			ctx.Logger.Info(fmt.Sprintf("%s %s is already installed", name, version))
+			return nil
+		}
+	}
+
+	for _, src := range sources {
+		out, err := ctx.Transport.Execute(ctx.Context, src.Cmd)
+		if err != nil {
+			ctx.Logger.Debug(fmt.Sprintf("Could not install %s %s from %s: %v: %s", name, version, src.From, err, strings.TrimSpace(out)))
+			continue
+		}
+		p.reportRestored(ctx, res, name, src.From)
+		return nil
+	}
+
+	if out, err := ctx.Transport.Execute(ctx.Context, fallback); err != nil {
+		return fmt.Errorf("failed to reinstall %s: %w: %s", name, err, strings.TrimSpace(out))
+	}
+	p.reportRestored(ctx, res, name, "the repositories")
+	return nil
+}
+
+// reportRestored logs the version of name now installed against the recorded one.
+func (p *pkgSnapshot) reportRestored(ctx *core.SystemContext, res core.Snapshotter, name, from string) {
+	version := p.Before.String("version")
+	installed := ""
+	if now, err := res.Snapshot(ctx); err == nil {
+		installed = now.String("version")
+	}
+	switch {
+	case version == "" || installed == version:
+		ctx.Logger.Info(fmt.Sprintf("Restored %s %s from %s", name, installed, from))
+	case installed == "":
+		ctx.Logger.Warn(fmt.Sprintf("Reinstalled %s from %s; could not verify that version %s was restored", name, from, version))
+	default:
+		ctx.Logger.Warn(fmt.Sprintf("Version %s of %s is not available; installed %s from %s instead", version, name, installed, from))
+	}
+}
+
+// findCached returns the path of the first file in dir, or in the given
+// subdirectory of each directory in dir when sub is set (e.g. "packages" for
+// /var/cache/dnf/<repo>/packages), for which match is true.
+func findCached(ctx *core.SystemContext, dir, sub string, match func(file string) bool) string {
+	entries, err := ctx.FS.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if sub != "" {
+			if e.IsDir() {
+				if p := findCached(ctx, filepath.Join(dir, e.Name(), sub), "", match); p != "" {
+					return p
+				}
+			}
+			continue
+		}
+		if !e.IsDir() && match(e.Name()) {
+			return filepath.Join(dir, e.Name())
+		}
+	}
+	return ""
+}
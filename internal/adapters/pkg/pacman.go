@@ -12,6 +12,7 @@ type PacmanAdapter struct {
 	core.BaseResource        // Ortak alanlar (Name, Type) buradan gelir
 	State             string // "present", "absent"
 	ActionPerformed   string // "installed", "removed", ""
+	pkgSnapshot
 }
 
 func init() {
@@ -100,24 +101,51 @@ func (r *PacmanAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(fmt.Sprintf("Successfully %s package %s", r.State, r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *PacmanAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "pacman", "-Q", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(field(out, 1)), nil
+}
+
 func (r *PacmanAdapter) Revert(ctx *core.SystemContext) error {
 	return r.RevertAction("installed", ctx)
 }
 
 func (r *PacmanAdapter) RevertAction(action string, ctx *core.SystemContext) error {
 	// Revert the given action
+	action = r.revertAction(action)
 	if action == "installed" {
 		// Undo install -> Remove
 		_, err := runCommand(ctx, "pacman", "-Rns", "--noconfirm", r.Name)
 		return err
 	} else if action == "removed" {
-		// Undo remove -> Install
-		_, err := runCommand(ctx, "pacman", "-S", "--noconfirm", "--needed", r.Name)
-		return err
+		// Undo remove or upgrade -> Install the previous version
+		return r.reinstall(ctx, r, r.Name, pacmanSources(ctx, "pacman", r.Name, r.Before.String("version")), "pacman -S --noconfirm --needed "+r.Name)
 	}
 	return fmt.Errorf("unknown action to revert: %s", action)
 }
 
+// pacmanCacheDir holds the package files pacman downloaded.
+const pacmanCacheDir = "/var/cache/pacman/pkg"
+
+// pacmanSources returns the cached package file of name at version for the
+// pacman-based managers (pacman, yay, paru), if there is one.
+func pacmanSources(ctx *core.SystemContext, bin, name, version string) []installSource {
+	if version == "" {
+		return nil
+	}
+	file := findCached(ctx, pacmanCacheDir, "", func(f string) bool {
+		return strings.HasPrefix(f, name+"-"+version+"-") && strings.Contains(f, ".pkg.tar") && !strings.HasSuffix(f, ".sig")
+	})
+	if file == "" {
+		return nil
+	}
+	return []installSource{{From: file, Cmd: bin + " -U --noconfirm " + file}}
+}
+
 // ListInstalled returns a list of explicitly installed packages.
 func (r *PacmanAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	// pacman -Qqe: Query, Quiet (name only), Explicitly installed
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -186,4 +189,48 @@ func TestPacmanAdapter_Revert(t *testing.T) {
 			t.Errorf("Unexpected command: got %s, want %s", executedCmd, expected)
 		}
 	})
+	t.Run("Revert upgrade from the package cache", func(t *testing.T) {
+		root := t.TempDir()
+		cache := filepath.Join(root, pacmanCacheDir)
+		os.MkdirAll(cache, 0755)
+		for _, f := range []string{"vim-9.1.0-1-x86_64.pkg.tar.zst", "vim-9.1.0-1-x86_64.pkg.tar.zst.sig", "vim-9.2.0-1-x86_64.pkg.tar.zst"} {
+			os.WriteFile(filepath.Join(cache, f), nil, 0644)
+		}
+
+		version := "9.2.0-1"
+		var executed []string
+		mockTr := &MockTransport{
+			ExecuteFunc: func(ctx context.Context, cmd string) (string, error) {
+				if cmd == "pacman -Q vim" {
+					return "vim " + version, nil
+				}
+				executed = append(executed, cmd)
+				version = "9.1.0-1"
+				return "", nil
+			},
+		}
+		ctx := core.NewSystemContext(false, mockTr)
+		ctx.FS = &rootFS{root: root}
+
+		adapter := NewPacmanAdapter("vim", nil).(*PacmanAdapter)
+		adapter.SetSnapshot(core.Snapshot{"installed": true, "version": "9.1.0-1"})
+		if err := adapter.RevertAction("applied", ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "pacman -U --noconfirm " + filepath.Join(pacmanCacheDir, "vim-9.1.0-1-x86_64.pkg.tar.zst")
+		if len(executed) != 1 || executed[0] != expected {
+			t.Errorf("Unexpected commands: %v", executed)
+		}
+	})
+}
+
+// rootFS reads directories below root, as if root were /.
+type rootFS struct {
+	core.RealFS
+	root string
+}
+
+func (f *rootFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.Join(f.root, name))
 }
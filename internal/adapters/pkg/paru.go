@@ -10,6 +10,7 @@ type ParuAdapter struct {
 	core.BaseResource
 	State           string
 	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -72,10 +73,26 @@ func (r *ParuAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(fmt.Sprintf("Paru processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *ParuAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "paru", "-Q", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(field(out, 1)), nil
+}
+
 func (r *ParuAdapter) Revert(ctx *core.SystemContext) error {
-	if r.ActionPerformed == "installed" {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *ParuAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
 		_, err := runCommand(ctx, "paru", "-Rns", "--noconfirm", r.Name)
 		return err
+	case "removed":
+		return r.reinstall(ctx, r, r.Name, pacmanSources(ctx, "paru", r.Name, r.Before.String("version")), "paru -S --noconfirm --needed "+r.Name)
 	}
 	return nil
 }
@@ -8,7 +8,9 @@ import (
 
 type SnapAdapter struct {
 	core.BaseResource
-	State string
+	State           string
+	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -58,14 +60,50 @@ func (r *SnapAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	var args []string
 	if r.State == "absent" {
 		args = []string{"remove", r.Name}
+		r.ActionPerformed = "removed"
 	} else {
 		args = []string{"install", r.Name}
+		r.ActionPerformed = "installed"
 	}
 
 	out, err := runCommand(ctx, "snap", args...)
 	if err != nil {
+		r.ActionPerformed = ""
 		return core.Failure(err, "Snap failed: "+out), err
 	}
 
 	return core.SuccessChange(fmt.Sprintf("Snap processed %s", r.Name)), nil
 }
+
+// Snapshot records whether the package is installed and its version.
+func (r *SnapAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	// snap list prints a header line, then: name version rev tracking publisher notes
+	out, err := runCommand(ctx, "snap", "list", r.Name)
+	lines := splitLines(out)
+	if err != nil || len(lines) < 2 {
+		return versionSnapshot(""), nil
+	}
+	snap := versionSnapshot(field(lines[1], 1))
+	snap["revision"] = field(lines[1], 2)
+	return snap, nil
+}
+
+func (r *SnapAdapter) Revert(ctx *core.SystemContext) error {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *SnapAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
+		_, err := runCommand(ctx, "snap", "remove", r.Name)
+		return err
+	case "removed":
+		// snapd keeps the previous revisions of a refreshed snap
+		var sources []installSource
+		if rev := r.Before.String("revision"); rev != "" {
+			sources = append(sources, installSource{From: "revision " + rev, Cmd: fmt.Sprintf("snap revert %s --revision=%s", r.Name, rev)})
+		}
+		return r.reinstall(ctx, r, r.Name, sources, "snap install "+r.Name)
+	}
+	return nil
+}
@@ -8,7 +8,9 @@ import (
 
 type YayAdapter struct {
 	core.BaseResource
-	State string
+	State           string
+	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -56,14 +58,41 @@ func (r *YayAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	var args []string
 	if r.State == "absent" {
 		args = []string{"-Rns", "--noconfirm", r.Name}
+		r.ActionPerformed = "removed"
 	} else {
 		args = []string{"-S", "--noconfirm", "--needed", r.Name}
+		r.ActionPerformed = "installed"
 	}
 
 	out, err := runCommand(ctx, "yay", args...)
 	if err != nil {
+		r.ActionPerformed = ""
 		return core.Failure(err, "Yay failed: "+out), err
 	}
 
 	return core.SuccessChange(fmt.Sprintf("Yay processed %s", r.Name)), nil
 }
+
+// Snapshot records whether the package is installed and its version.
+func (r *YayAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "yay", "-Q", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(field(out, 1)), nil
+}
+
+func (r *YayAdapter) Revert(ctx *core.SystemContext) error {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *YayAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
+		_, err := runCommand(ctx, "yay", "-Rns", "--noconfirm", r.Name)
+		return err
+	case "removed":
+		return r.reinstall(ctx, r, r.Name, pacmanSources(ctx, "yay", r.Name, r.Before.String("version")), "yay -S --noconfirm --needed "+r.Name)
+	}
+	return nil
+}
@@ -8,7 +8,9 @@ import (
 
 type YumAdapter struct {
 	core.BaseResource
-	State string
+	State           string
+	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -56,18 +58,58 @@ func (r *YumAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	var args []string
 	if r.State == "absent" {
 		args = []string{"remove", "-y", r.Name}
+		r.ActionPerformed = "removed"
 	} else {
 		args = []string{"install", "-y", r.Name}
+		r.ActionPerformed = "installed"
 	}
 
 	out, err := runCommand(ctx, "yum", args...)
 	if err != nil {
+		r.ActionPerformed = ""
 		return core.Failure(err, "Yum failed: "+out), err
 	}
 
 	return core.SuccessChange(fmt.Sprintf("Yum processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *YumAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "rpm", "-q", "--qf", "'%{VERSION}-%{RELEASE}'", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(out), nil
+}
+
+func (r *YumAdapter) Revert(ctx *core.SystemContext) error {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *YumAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
+		_, err := runCommand(ctx, "yum", "remove", "-y", r.Name)
+		return err
+	case "removed":
+		return r.reinstall(ctx, r, r.Name, r.versionSources(ctx), "yum install -y "+r.Name)
+	}
+	return nil
+}
+
+// versionSources returns the ways to install the version recorded before Apply:
+// that version from the repositories, which yum takes from its cache if present.
+func (r *YumAdapter) versionSources(ctx *core.SystemContext) []installSource {
+	version := r.Before.String("version")
+	if version == "" {
+		return nil
+	}
+	return []installSource{{
+		From: "the repositories",
+		Cmd:  fmt.Sprintf("yum install -y %s-%s", r.Name, version),
+	}}
+}
+
 func (r *YumAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	output, err := runCommand(ctx, "rpm", "-qa", "--qf", "%{NAME}\n")
 	if err != nil {
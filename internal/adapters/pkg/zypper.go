@@ -8,7 +8,9 @@ import (
 
 type ZypperAdapter struct {
 	core.BaseResource
-	State string
+	State           string
+	ActionPerformed string
+	pkgSnapshot
 }
 
 func init() {
@@ -59,19 +61,59 @@ func (r *ZypperAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	var args []string
 	if r.State == "absent" {
 		args = []string{"remove", "-y", r.Name}
+		r.ActionPerformed = "removed"
 	} else {
 		// --non-interactive = -n
 		args = []string{"install", "-n", r.Name}
+		r.ActionPerformed = "installed"
 	}
 
 	out, err := runCommand(ctx, "zypper", args...)
 	if err != nil {
+		r.ActionPerformed = ""
 		return core.Failure(err, "Zypper failed: "+out), err
 	}
 
 	return core.SuccessChange(fmt.Sprintf("Zypper processed %s", r.Name)), nil
 }
 
+// Snapshot records whether the package is installed and its version.
+func (r *ZypperAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	out, err := runCommand(ctx, "rpm", "-q", "--qf", "'%{VERSION}-%{RELEASE}'", r.Name)
+	if err != nil {
+		return versionSnapshot(""), nil
+	}
+	return versionSnapshot(out), nil
+}
+
+func (r *ZypperAdapter) Revert(ctx *core.SystemContext) error {
+	return r.RevertAction(r.ActionPerformed, ctx)
+}
+
+func (r *ZypperAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	switch r.revertAction(action) {
+	case "installed":
+		_, err := runCommand(ctx, "zypper", "remove", "-y", r.Name)
+		return err
+	case "removed":
+		return r.reinstall(ctx, r, r.Name, r.versionSources(ctx), "zypper install -n "+r.Name)
+	}
+	return nil
+}
+
+// versionSources returns the ways to install the version recorded before Apply:
+// that version from the repositories; zypper uses cached packages by itself.
+func (r *ZypperAdapter) versionSources(ctx *core.SystemContext) []installSource {
+	version := r.Before.String("version")
+	if version == "" {
+		return nil
+	}
+	return []installSource{{
+		From: "the repositories",
+		Cmd:  fmt.Sprintf("zypper install -n --oldpackage %s-%s", r.Name, version),
+	}}
+}
+
 func (r *ZypperAdapter) ListInstalled(ctx *core.SystemContext) ([]string, error) {
 	// rpm -qa --qf "%{NAME}\n"
 	output, err := runCommand(ctx, "rpm", "-qa", "--qf", "%{NAME}\n")
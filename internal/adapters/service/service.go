@@ -12,7 +12,8 @@ type ServiceAdapter struct {
 	State           string // active, stopped, restarted
 	Enabled         bool   // true, false
 	Manager         ServiceManager
-	ActionPerformed []string // To track actions for Revert (e.g., "started", "enabled")
+	ActionPerformed []string      // To track actions for Revert (e.g., "started", "enabled")
+	Before          core.Snapshot // State before Apply: enabled, active
 }
 
 func init() {
@@ -151,7 +152,70 @@ func (r *ServiceAdapter) Apply(ctx *core.SystemContext) (core.Result, error) {
 	return core.SuccessChange(strings.Join(messages, ", ")), nil
 }
 
+// Snapshot records whether the service was enabled and active before Apply.
+func (r *ServiceAdapter) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	enabled, err := r.Manager.IsEnabled(ctx, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	active, err := r.Manager.IsActive(ctx, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	return core.Snapshot{"enabled": enabled, "active": active}, nil
+}
+
+func (r *ServiceAdapter) SetSnapshot(s core.Snapshot) {
+	r.Before = s
+}
+
+func (r *ServiceAdapter) RevertAction(action string, ctx *core.SystemContext) error {
+	if r.Before == nil {
+		ctx.Logger.Warn(fmt.Sprintf("No previous state recorded for service %s. Skipping rollback.", r.Name))
+		return nil
+	}
+	return r.revertSnapshot(ctx)
+}
+
+// revertSnapshot enables or disables, starts or stops the service as recorded.
+func (r *ServiceAdapter) revertSnapshot(ctx *core.SystemContext) error {
+	enabled, err := r.Manager.IsEnabled(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	if want := r.Before.Bool("enabled"); want != enabled {
+		if want {
+			err = r.Manager.Enable(ctx, r.Name)
+		} else {
+			err = r.Manager.Disable(ctx, r.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore enabled state of %s: %w", r.Name, err)
+		}
+	}
+
+	active, err := r.Manager.IsActive(ctx, r.Name)
+	if err != nil {
+		return err
+	}
+	if want := r.Before.Bool("active"); want != active {
+		if want {
+			err = r.Manager.Start(ctx, r.Name)
+		} else {
+			err = r.Manager.Stop(ctx, r.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore active state of %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
 func (r *ServiceAdapter) Revert(ctx *core.SystemContext) error {
+	if r.Before != nil {
+		return r.revertSnapshot(ctx)
+	}
+
 	// Revert order: reverse of apply
 	for i := len(r.ActionPerformed) - 1; i >= 0; i-- {
 		action := r.ActionPerformed[i]
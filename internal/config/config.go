@@ -1,16 +1,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 
-	"github.com/melih-ucgun/veto/internal/consts"
 	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/crypto"
+	"github.com/melih-ucgun/veto/internal/secrets"
+	"github.com/melih-ucgun/veto/internal/state"
 	"github.com/melih-ucgun/veto/internal/system"
 	"github.com/pterm/pterm"
 )
@@ -24,6 +27,8 @@ type Config struct {
 	RuleSets  []string          `yaml:"rulesets,omitempty"`  // RuleSet paths to include
 	Resources []ResourceConfig  `yaml:"resources"`           // Resource list
 	Hosts     []Host            `yaml:"hosts,omitempty"`     // Remote hosts (Optional)
+
+	State *state.BackendConfig `yaml:"state,omitempty"` // State backend (Optional, root file only)
 }
 
 // ResourceConfig holds the configuration for each resource (file, user, package, etc.).
@@ -59,8 +64,23 @@ type Host struct {
 	BecomePassword string `yaml:"become_password"` // Optional (Recommended to come from Vault)
 }
 
+// LoadOptions tune LoadConfigWith.
+type LoadOptions struct {
+	Decrypt bool
+	// HostSecrets leaves ENC[X25519:...] resource parameters encrypted, for fleet
+	// hosts to decrypt with their own identity.
+	HostSecrets bool
+	// Facts detects the system for template expansion, nil uses the default TTL
+	Facts *system.FactCache
+}
+
 // LoadConfig reads the YAML file at the specified path and converts it into a Config struct.
 func LoadConfig(path string, decrypt bool) (*Config, error) {
+	return LoadConfigWith(path, LoadOptions{Decrypt: decrypt})
+}
+
+// LoadConfigWith is LoadConfig with options.
+func LoadConfigWith(path string, opts LoadOptions) (*Config, error) {
 	// Get absolute path
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -71,9 +91,14 @@ func LoadConfig(path string, decrypt bool) (*Config, error) {
 	// This happens BEFORE loading config so {{.OS}} works in 'includes'
 	// Passing nil transport as system.Detect should handle local fallback
 	ctx := core.NewSystemContext(false, nil)
-	system.Detect(ctx) // Lightweight detection
+	facts := opts.Facts
+	if facts == nil {
+		facts = system.NewFactCache(system.DefaultFactsTTL, false)
+	}
+	facts.Detect(ctx, "") // Cached lightweight detection
 	os.Setenv("VETO_OS", ctx.OS)
 	os.Setenv("VETO_DISTRO", ctx.Distro)
+	os.Setenv("VETO_FAMILY", ctx.Family)
 	os.Setenv("VETO_HOSTNAME", ctx.Hostname)
 
 	// 1. If .env exists in project root or next to config, load it
@@ -102,8 +127,8 @@ func LoadConfig(path string, decrypt bool) (*Config, error) {
 
 	// Recursive loading finished, now perform variable expansion on all string values
 	expandConfig(cfg)
-	if decrypt {
-		decryptConfig(cfg)
+	if opts.Decrypt {
+		decryptConfig(cfg, opts.HostSecrets)
 	}
 
 	return cfg, nil
@@ -174,32 +199,11 @@ func loadConfigRecursive(path string, visited map[string]bool) (*Config, error)
 
 	// Include
 	for _, includePath := range blockCfg.Includes {
-		fullIncludePath := filepath.Join(baseDir, includePath)
-		absIncludePath, err := filepath.Abs(fullIncludePath)
+		absIncludePath, err := resolveIncludePath(baseDir, includePath)
 		if err != nil {
 			return nil, err
 		}
 
-		// Directory Check
-		info, err := os.Stat(absIncludePath)
-		if err == nil && info.IsDir() {
-			// Try rules.yaml first, then main.yaml
-			rulesPath := filepath.Join(absIncludePath, "rules.yaml")
-			if _, err := os.Stat(rulesPath); err == nil {
-				absIncludePath = rulesPath
-			} else {
-				mainPath := filepath.Join(absIncludePath, "main.yaml")
-				if _, err := os.Stat(mainPath); err == nil {
-					absIncludePath = mainPath
-				} else {
-					// Directory exists but no known config file? skip or error?
-					// Use filepath.Join just to fail gracefully later or log warning
-					// For now, let's proceed and fail at ReadFile
-					fmt.Printf("Warning: Included directory '%s' has no rules.yaml or main.yaml\n", includePath)
-				}
-			}
-		}
-
 		subCfg, err := loadConfigRecursive(absIncludePath, visited)
 		if err != nil {
 			return nil, err
@@ -223,6 +227,32 @@ func loadConfigRecursive(path string, visited map[string]bool) (*Config, error)
 	return blockCfg, nil
 }
 
+// resolveIncludePath makes an include/ruleset path absolute. Directories resolve
+// to their rules.yaml, then main.yaml.
+func resolveIncludePath(baseDir, includePath string) (string, error) {
+	absIncludePath, err := filepath.Abs(filepath.Join(baseDir, includePath))
+	if err != nil {
+		return "", err
+	}
+
+	// Directory Check
+	info, err := os.Stat(absIncludePath)
+	if err == nil && info.IsDir() {
+		// Try rules.yaml first, then main.yaml
+		rulesPath := filepath.Join(absIncludePath, "rules.yaml")
+		if _, err := os.Stat(rulesPath); err == nil {
+			return rulesPath, nil
+		}
+		mainPath := filepath.Join(absIncludePath, "main.yaml")
+		if _, err := os.Stat(mainPath); err == nil {
+			return mainPath, nil
+		}
+		// Directory exists but no known config file: proceed and fail at ReadFile
+		fmt.Printf("Warning: Included directory '%s' has no rules.yaml or main.yaml\n", includePath)
+	}
+	return absIncludePath, nil
+}
+
 // expandConfig performs Env Var substitution on all string values in the configuration.
 func expandConfig(cfg *Config) {
 	// 1. Global Vars
@@ -245,6 +275,9 @@ func expandConfig(cfg *Config) {
 		cfg.Hosts[i].BecomePassword = os.ExpandEnv(cfg.Hosts[i].BecomePassword)
 		cfg.Hosts[i].SSHKeyPath = os.ExpandEnv(cfg.Hosts[i].SSHKeyPath)
 	}
+
+	// 4. State backend
+	expandStateConfig(cfg.State)
 }
 
 func expandResource(res *ResourceConfig) {
@@ -284,38 +317,46 @@ func expandMap(m map[string]interface{}) {
 
 // Security & Decryption
 
-func decryptConfig(cfg *Config) {
+func decryptConfig(cfg *Config, hostSecrets bool) {
 	if !hasEncryptedContent(cfg) {
 		return
 	}
 
-	key := getMasterKey()
-	if key == "" {
-		// No key found. If there are encrypted values, they will remain as is (encrypted).
-		// This might cause errors later if the app expects plaintext, but safer than crashing.
-		// Alternatively, we could scan for IsEncrypted and warn.
-		return
+	keyring := Keyring()
+	resourceKeyring := keyring
+	if hostSecrets {
+		resourceKeyring = keyring.WithoutIdentities()
 	}
 
 	// 1. Global Vars
 	for k, v := range cfg.Vars {
-		if decrypted, err := crypto.Decrypt(v, key); err == nil {
+		if !crypto.IsEncrypted(v) {
+			continue
+		}
+		if decrypted, err := keyring.Decrypt(v); err == nil {
 			cfg.Vars[k] = decrypted
+			// Hooks need the plain value; their output is redacted instead
 			os.Setenv(k, decrypted)
 		}
 	}
 
 	// 2. Resources
 	for i := range cfg.Resources {
-		decryptResource(&cfg.Resources[i], key)
+		decryptResource(&cfg.Resources[i], resourceKeyring)
 	}
 
 	// 3. Hosts
 	for i := range cfg.Hosts {
-		if val, err := crypto.Decrypt(cfg.Hosts[i].BecomePassword, key); err == nil {
+		if !crypto.IsEncrypted(cfg.Hosts[i].BecomePassword) {
+			continue
+		}
+		if val, err := keyring.Decrypt(cfg.Hosts[i].BecomePassword); err == nil {
 			cfg.Hosts[i].BecomePassword = val
 		}
 	}
+
+	// 4. State backend credentials
+	decryptStateConfig(cfg.State, keyring)
 }
 
 func hasEncryptedContent(cfg *Config) bool {
@@ -340,6 +381,11 @@ func hasEncryptedContent(cfg *Config) bool {
 		}
 	}
 
+	// 4. State backend
+	if st := cfg.State; st != nil && (crypto.IsEncrypted(st.AccessKey) || crypto.IsEncrypted(st.SecretKey)) {
+		return true
+	}
+
 	return false
 }
 
@@ -375,52 +421,74 @@ func hasEncryptedMap(m map[string]interface{}) bool {
 	return false
 }
 
-func decryptResource(res *ResourceConfig, key string) {
+func decryptResource(res *ResourceConfig, keyring *crypto.Keyring) {
 	// Params (Recursive Map traversal)
-	decryptMap(res.Params, key)
+	decryptMap(res.Params, keyring)
 }
 
-func decryptMap(m map[string]interface{}, key string) {
+func decryptMap(m map[string]interface{}, keyring *crypto.Keyring) {
 	for k, v := range m {
 		switch val := v.(type) {
 		case string:
 			if crypto.IsEncrypted(val) {
-				if decrypted, err := crypto.Decrypt(val, key); err == nil {
+				if decrypted, err := keyring.Decrypt(val); err == nil {
 					m[k] = decrypted
 				}
 			}
 		case map[string]interface{}:
-			decryptMap(val, key)
+			decryptMap(val, keyring)
 		case []interface{}:
 			for i, item := range val {
 				if str, ok := item.(string); ok {
 					if crypto.IsEncrypted(str) {
-						if decrypted, err := crypto.Decrypt(str, key); err == nil {
+						if decrypted, err := keyring.Decrypt(str); err == nil {
 							val[i] = decrypted
 						}
 					}
 				} else if subMap, ok := item.(map[string]interface{}); ok {
-					decryptMap(subMap, key)
+					decryptMap(subMap, keyring)
 				}
 			}
 		}
 	}
 }
 
+var (
+	keyringOnce   sync.Once
+	sharedKeyring *crypto.Keyring
+)
+
+// Keyring returns the keyring shared by config decryption and encrypted file sources.
+// It loads the X25519 identities; the master key is only resolved (and prompted for,
+// once) when an AES256 value is actually found. Decrypted values are tainted so they
+// are redacted from logs, diffs and the state history.
+func Keyring() *crypto.Keyring {
+	keyringOnce.Do(func() {
+		identities, err := crypto.LoadIdentities()
+		if err != nil {
+			pterm.Warning.Printf("Failed to load identities: %v\n", err)
+		}
+		sharedKeyring = &crypto.Keyring{
+			Identities:    identities,
+			LoadMasterKey: getMasterKey,
+			OnDecrypt:     secrets.Taint,
+		}
+	})
+	return sharedKeyring
+}
+
 func getMasterKey() string {
-	// 1. Env Var
-	if key := os.Getenv("VETO_MASTER_KEY"); key != "" {
+	// 1-3. Env var, key agent, key file (passphrase prompt if it is wrapped)
+	key, err := ReadMasterKey(PromptPassphrase)
+	if err == nil {
 		return key
 	}
-
-	// 2. File
-	if keyPath, err := consts.GetMasterKeyPath(); err == nil {
-		if content, err := os.ReadFile(keyPath); err == nil {
-			return string(content)
-		}
+	if !errors.Is(err, ErrNoMasterKey) {
+		pterm.Error.Println(err)
+		return ""
 	}
 
-	// 3. Interactive Prompt
+	// 4. Interactive Prompt
 	// Only if stdin is a terminal (interactive session)
 	if isInteractive() {
 		// Use pterm to ask for password
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/crypto"
+)
+
+// ErrNoMasterKey is returned when no master key is configured at all.
+var ErrNoMasterKey = errors.New("master key not found")
+
+const passphraseAttempts = 3
+
+// ReadMasterKey resolves the master key without asking for the key itself:
+//  1. VETO_MASTER_KEY
+//  2. the key agent started by 'veto secret unlock'
+//  3. ~/.veto/master.key, opened with passphrase if it is passphrase-protected
+func ReadMasterKey(passphrase func() (string, error)) (string, error) {
+	if key := os.Getenv("VETO_MASTER_KEY"); key != "" {
+		return strings.TrimSpace(key), nil
+	}
+
+	if socket, err := consts.GetAgentSocketPath(); err == nil {
+		if key, err := crypto.AgentKey(socket); err == nil {
+			return key, nil
+		}
+	}
+
+	keyPath, err := consts.GetMasterKeyPath()
+	if err != nil {
+		return "", ErrNoMasterKey
+	}
+	content, err := os.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		return "", ErrNoMasterKey
+	}
+	if err != nil {
+		return "", err
+	}
+	if !crypto.IsWrappedKey(string(content)) {
+		return strings.TrimSpace(string(content)), nil
+	}
+	return UnwrapMasterKey(string(content), passphrase)
+}
+
+// UnwrapMasterKey opens a passphrase-protected key, asking for the passphrase up to
+// three times.
+func UnwrapMasterKey(wrapped string, passphrase func() (string, error)) (string, error) {
+	for attempt := 1; ; attempt++ {
+		pass, err := passphrase()
+		if err != nil {
+			return "", err
+		}
+		key, err := crypto.UnwrapKey(wrapped, pass)
+		if errors.Is(err, crypto.ErrWrongPassphrase) && attempt < passphraseAttempts && isInteractive() {
+			pterm.Warning.Println("Wrong passphrase, try again.")
+			continue
+		}
+		return key, err
+	}
+}
+
+// PromptPassphrase asks for the passphrase of the master key. Non-interactive runs
+// (watch mode, CI) fail instead and point at the key agent.
+func PromptPassphrase() (string, error) {
+	if !isInteractive() {
+		return "", fmt.Errorf("master key is passphrase-protected: run 'veto secret unlock' first or set VETO_MASTER_KEY")
+	}
+	return pterm.DefaultInteractiveTextInput.
+		WithMask("*").
+		WithDefaultText("Passphrase for master key").
+		Show()
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/melih-ucgun/veto/internal/crypto"
+)
+
+// SecretTag marks plaintext values that are encrypted when an edited file is saved.
+const SecretTag = "!secret"
+
+// EditedSecret is an encrypted value of a file opened for editing.
+type EditedSecret struct {
+	Path      string // YAML path of the value, e.g. vars.db_pass or resources[0].params.token
+	Token     string // Value exactly as written in the file, including quotes
+	Plaintext string
+}
+
+// scalarRef is a scalar node together with its YAML path.
+type scalarRef struct {
+	node *yaml.Node
+	path string
+	flow bool // Inside a flow collection ([...] or {...})
+}
+
+// DecryptSecrets replaces every encrypted scalar of a YAML document with its plaintext
+// tagged !secret, leaving the rest of the document untouched. The returned secrets are
+// passed to EncryptSecrets so unchanged values keep their original ciphertext.
+func DecryptSecrets(data []byte, decrypt func(string) (string, error)) ([]byte, []EditedSecret, error) {
+	refs, err := scalarRefs(data, func(n *yaml.Node) bool {
+		return crypto.IsEncrypted(strings.TrimSpace(n.Value))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var secrets []EditedSecret
+	var edits []textEdit
+	for _, ref := range refs {
+		start, end := scalarSpan(data, ref)
+		plain, err := decrypt(strings.TrimSpace(ref.node.Value))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", ref.node.Line, err)
+		}
+		secrets = append(secrets, EditedSecret{
+			Path:      ref.path,
+			Token:     string(data[start:end]),
+			Plaintext: plain,
+		})
+		edits = append(edits, textEdit{start, end, SecretTag + " " + strconv.Quote(plain)})
+	}
+	return applyEdits(data, edits), secrets, nil
+}
+
+// EncryptSecrets replaces every !secret value of an edited document with a ciphertext.
+// A value whose plaintext is unchanged at the same path gets its original ciphertext
+// back byte for byte; anything else is passed to encrypt. Returns the document and the
+// number of values that were encrypted.
+func EncryptSecrets(data []byte, previous []EditedSecret, encrypt func(string) (string, error)) ([]byte, int, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, 0, err
+	}
+	if line := nonScalarSecret(&root); line > 0 {
+		return nil, 0, fmt.Errorf("line %d: %s can only be used on scalar values", line, SecretTag)
+	}
+
+	refs, err := scalarRefs(data, func(n *yaml.Node) bool {
+		return n.Tag == SecretTag
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	known := make(map[string]EditedSecret, len(previous))
+	for _, s := range previous {
+		known[s.Path] = s
+	}
+
+	encrypted := 0
+	var edits []textEdit
+	for _, ref := range refs {
+		start, end := scalarSpan(data, ref)
+		prev, ok := known[ref.path]
+		token := prev.Token
+		if !ok || prev.Plaintext != ref.node.Value {
+			value, err := encrypt(ref.node.Value)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d: %w", ref.node.Line, err)
+			}
+			// Keep the quoting style of the value being replaced; new values are quoted
+			token = strconv.Quote(value)
+			if ok && !ref.flow && !strings.HasPrefix(prev.Token, `"`) && !strings.HasPrefix(prev.Token, "'") {
+				token = value
+			}
+			encrypted++
+		}
+		edits = append(edits, textEdit{start, end, token})
+	}
+	return applyEdits(data, edits), encrypted, nil
+}
+
+// scalarRefs returns the value scalars of a document that match, in document order.
+// Mapping keys are never returned.
+func scalarRefs(data []byte, match func(*yaml.Node) bool) ([]scalarRef, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var refs []scalarRef
+	var walk func(n *yaml.Node, path string, flow bool)
+	walk = func(n *yaml.Node, path string, flow bool) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path, flow)
+			}
+		case yaml.MappingNode:
+			inner := flow || n.Style&yaml.FlowStyle != 0
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				walk(n.Content[i+1], key, inner)
+			}
+		case yaml.SequenceNode:
+			inner := flow || n.Style&yaml.FlowStyle != 0
+			for i, c := range n.Content {
+				walk(c, fmt.Sprintf("%s[%d]", path, i), inner)
+			}
+		case yaml.ScalarNode:
+			if match(n) {
+				refs = append(refs, scalarRef{node: n, path: path, flow: flow})
+			}
+		}
+	}
+	walk(&root, "", false)
+	return refs, nil
+}
+
+// nonScalarSecret returns the line of the first mapping or sequence tagged !secret, or 0.
+func nonScalarSecret(n *yaml.Node) int {
+	if n.Kind != yaml.ScalarNode && n.Tag == SecretTag {
+		return n.Line
+	}
+	for _, c := range n.Content {
+		if line := nonScalarSecret(c); line > 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+// scalarSpan returns the byte range of a scalar in data, including its tag and quotes.
+func scalarSpan(data []byte, ref scalarRef) (int, int) {
+	start := nodeOffset(data, ref.node.Line, ref.node.Column)
+
+	// Skip node properties (tag, anchor) to reach the value itself
+	i := start
+	for i < len(data) && (data[i] == '!' || data[i] == '&') {
+		for i < len(data) && !isSpace(data[i]) {
+			i++
+		}
+		for i < len(data) && isSpace(data[i]) {
+			i++
+		}
+	}
+	if i >= len(data) {
+		return start, len(data)
+	}
+
+	switch data[i] {
+	case '"':
+		for j := i + 1; j < len(data); j++ {
+			if data[j] == '\\' {
+				j++
+			} else if data[j] == '"' {
+				return start, j + 1
+			}
+		}
+		return start, len(data)
+	case '\'':
+		for j := i + 1; j < len(data); j++ {
+			if data[j] == '\'' {
+				if j+1 < len(data) && data[j+1] == '\'' {
+					j++
+					continue
+				}
+				return start, j + 1
+			}
+		}
+		return start, len(data)
+	case '|', '>':
+		// Block scalar: every following line indented deeper than the line it starts on
+		indent := lineIndent(data, i)
+		end := lineEnd(data, i)
+		last := end
+		for end < len(data) {
+			next := lineEnd(data, end+1)
+			line := data[end+1 : next]
+			if len(bytes.TrimSpace(line)) > 0 {
+				if lineIndent(data, end+1) <= indent {
+					break
+				}
+				last = next
+			}
+			end = next
+		}
+		return start, last
+	}
+
+	// Plain scalar: up to a comment, the end of the line or a flow indicator
+	j := i
+	for j < len(data) && data[j] != '\n' {
+		if data[j] == '#' && isSpace(data[j-1]) {
+			break
+		}
+		if ref.flow && (data[j] == ',' || data[j] == ']' || data[j] == '}') {
+			break
+		}
+		j++
+	}
+	return start, i + len(bytes.TrimRight(data[i:j], " \t\r"))
+}
+
+// nodeOffset converts a 1-based line and (rune) column into a byte offset.
+func nodeOffset(data []byte, line, column int) int {
+	off := 0
+	for l := 1; l < line && off < len(data); l++ {
+		idx := bytes.IndexByte(data[off:], '\n')
+		if idx < 0 {
+			return len(data)
+		}
+		off += idx + 1
+	}
+	for c := 1; c < column && off < len(data); c++ {
+		_, size := utf8.DecodeRune(data[off:])
+		off += size
+	}
+	return off
+}
+
+func lineEnd(data []byte, i int) int {
+	if i >= len(data) {
+		return len(data)
+	}
+	if idx := bytes.IndexByte(data[i:], '\n'); idx >= 0 {
+		return i + idx
+	}
+	return len(data)
+}
+
+// lineIndent returns the number of leading spaces of the line containing offset i.
+func lineIndent(data []byte, i int) int {
+	begin := bytes.LastIndexByte(data[:i], '\n') + 1
+	n := 0
+	for begin+n < len(data) && data[begin+n] == ' ' {
+		n++
+	}
+	return n
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits replaces non-overlapping byte ranges of data.
+func applyEdits(data []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out bytes.Buffer
+	prev := 0
+	for _, e := range edits {
+		out.Write(data[prev:e.start])
+		out.WriteString(e.text)
+		prev = e.end
+	}
+	out.Write(data[prev:])
+	return out.Bytes()
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/melih-ucgun/veto/internal/crypto"
+	"github.com/melih-ucgun/veto/internal/utils"
+)
+
+// ConfigFiles returns the config file at path followed by every file it pulls in
+// through includes, imports and rulesets, each listed once.
+func ConfigFiles(path string) ([]string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	visited := make(map[string]bool)
+
+	var walk func(string) error
+	walk = func(file string) error {
+		if visited[file] {
+			return nil
+		}
+		visited[file] = true
+		files = append(files, file)
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("file read error (%s): %w", file, err)
+		}
+		var refs struct {
+			Includes []string `yaml:"includes"`
+			Imports  []string `yaml:"imports"`
+			RuleSets []string `yaml:"rulesets"`
+		}
+		if err := yaml.Unmarshal(data, &refs); err != nil {
+			return fmt.Errorf("yaml parse error (%s): %w", file, err)
+		}
+
+		all := append(append(refs.Includes, refs.Imports...), refs.RuleSets...)
+		for _, inc := range all {
+			incPath, err := resolveIncludePath(filepath.Dir(file), os.ExpandEnv(inc))
+			if err != nil {
+				return err
+			}
+			if err := walk(incPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(absPath); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// EncryptedValues returns every encrypted scalar of a YAML document, in document order.
+// Comments are not scalars, so encrypted-looking text inside them is ignored.
+func EncryptedValues(data []byte) ([]string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var values []string
+	var walk func(*yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.ScalarNode && crypto.IsEncrypted(strings.TrimSpace(n.Value)) {
+			values = append(values, strings.TrimSpace(n.Value))
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(&root)
+	return values, nil
+}
+
+// RewriteSecrets passes every encrypted scalar of a YAML file to rewrite and replaces it
+// with the returned value. Each scalar is replaced at its position in the file, so
+// comments, ordering, formatting and the quoting of the value are preserved. Returns
+// the number of values changed. Nothing is written if rewrite fails for any value.
+func RewriteSecrets(path string, rewrite func(value string) (string, error)) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	refs, err := scalarRefs(data, func(n *yaml.Node) bool {
+		return crypto.IsEncrypted(strings.TrimSpace(n.Value))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("yaml parse error (%s): %w", path, err)
+	}
+
+	// Each distinct value is rewritten once, so repeated values stay identical
+	rewritten := make(map[string]string)
+	var edits []textEdit
+	for _, ref := range refs {
+		old := strings.TrimSpace(ref.node.Value)
+		updated, ok := rewritten[old]
+		if !ok {
+			if updated, err = rewrite(old); err != nil {
+				return 0, fmt.Errorf("%s: %w", path, err)
+			}
+			rewritten[old] = updated
+		}
+		if updated == old {
+			continue
+		}
+		start, end := scalarSpan(data, ref)
+		edits = append(edits, textEdit{start, end, replaceScalar(string(data[start:end]), updated)})
+	}
+	changed := len(edits)
+	content := applyEdits(data, edits)
+
+	if changed == 0 {
+		return 0, nil
+	}
+
+	if err := utils.WriteFileAtomic(path, content, info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// replaceScalar returns the token of a scalar with its value replaced by value, keeping
+// its tag, anchor and quotes. Block scalars become plain ones.
+func replaceScalar(token, value string) string {
+	props := ""
+	for strings.HasPrefix(token, "!") || strings.HasPrefix(token, "&") {
+		i := strings.IndexAny(token, " \t\r\n")
+		if i < 0 {
+			break
+		}
+		rest := strings.TrimLeft(token[i:], " \t\r\n")
+		props += token[:len(token)-len(rest)]
+		token = rest
+	}
+	if strings.HasPrefix(token, `"`) || strings.HasPrefix(token, "'") {
+		return props + token[:1] + value + token[:1]
+	}
+	return props + value
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "rules", "web"), 0755)
+	os.WriteFile(filepath.Join(dir, "veto.yaml"), []byte("includes: [base.yaml]\nrulesets: [rules/web]\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("includes: [veto.yaml]\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "rules", "web", "rules.yaml"), []byte("resources: []\n"), 0644)
+
+	files, err := ConfigFiles(filepath.Join(dir, "veto.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"veto.yaml", "base.yaml", filepath.Join("rules", "web", "rules.yaml")}
+	if len(files) != len(want) {
+		t.Fatalf("Expected %d files, got %v", len(want), files)
+	}
+	for i, f := range files {
+		if !strings.HasSuffix(f, want[i]) {
+			t.Errorf("File %d: expected %s, got %s", i, want[i], f)
+		}
+	}
+}
+
+func TestRewriteSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "veto.yaml")
+	content := `# Database
+vars:
+  db_pass: "ENC[AES256:aaaa:b2xk]" # rotated yearly
+  user: admin
+resources:
+  - type: file
+    name: cfg
+    params:
+      # ENC[AES256:comment:aWdub3Jl] is not a value
+      token: ENC[AES256:aaaa:dG9rZW4=]
+`
+	os.WriteFile(path, []byte(content), 0600)
+
+	n, err := RewriteSecrets(path, func(v string) (string, error) {
+		return strings.Replace(v, "aaaa", "bbbb", 1), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 rewritten values, got %d", n)
+	}
+
+	data, _ := os.ReadFile(path)
+	got := string(data)
+	want := strings.ReplaceAll(content, "ENC[AES256:aaaa:", "ENC[AES256:bbbb:")
+	if got != want {
+		t.Errorf("Unexpected rewrite result:\n%s", got)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("File mode not preserved: %v", info.Mode().Perm())
+	}
+}
+
+func TestRewriteSecretsInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "veto.yaml")
+	content := `vars:
+  # old: ENC[AES256:aaaa:b2xk]
+  a: ENC[AES256:aaaa:b2xk]
+  note: "was ENC[AES256:aaaa:b2xk]"
+  b: 'ENC[AES256:aaaa:b2xk]'
+  c: !!str ENC[AES256:aaaa:b2xk]
+  d: |
+    ENC[AES256:aaaa:b2xk]
+  e: done
+`
+	os.WriteFile(path, []byte(content), 0600)
+
+	calls := 0
+	n, err := RewriteSecrets(path, func(v string) (string, error) {
+		calls++
+		return "ENC[AES256:bbbb:bmV3]", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || calls != 1 {
+		t.Errorf("Expected 4 rewritten values in 1 call, got %d in %d", n, calls)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := `vars:
+  # old: ENC[AES256:aaaa:b2xk]
+  a: ENC[AES256:bbbb:bmV3]
+  note: "was ENC[AES256:aaaa:b2xk]"
+  b: 'ENC[AES256:bbbb:bmV3]'
+  c: !!str ENC[AES256:bbbb:bmV3]
+  d: ENC[AES256:bbbb:bmV3]
+  e: done
+`
+	if string(data) != want {
+		t.Errorf("Unexpected rewrite result:\n%s", data)
+	}
+}
+
+func TestSecretEditRoundTrip(t *testing.T) {
+	decrypt := func(v string) (string, error) {
+		return strings.TrimSuffix(strings.TrimPrefix(v, "ENC[AES256:k:"), "]"), nil
+	}
+	encrypt := func(p string) (string, error) { return "ENC[AES256:k:" + p + "]", nil }
+
+	original := `# secrets
+vars:
+  a: "ENC[AES256:k:alpha]" # keep
+  b: ENC[AES256:k:beta]
+  list: ["ENC[AES256:k:alpha]", x]
+  plain: hello
+`
+	plain, secrets, err := DecryptSecrets([]byte(original), decrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPlain := `# secrets
+vars:
+  a: !secret "alpha" # keep
+  b: !secret "beta"
+  list: [!secret "alpha", x]
+  plain: hello
+`
+	if string(plain) != wantPlain {
+		t.Fatalf("Unexpected decrypted document:\n%s", plain)
+	}
+
+	// Unchanged values get their original ciphertext back
+	out, n, err := EncryptSecrets(plain, secrets, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || string(out) != original {
+		t.Errorf("Expected byte-identical output, got %d changes:\n%s", n, out)
+	}
+
+	edited := strings.Replace(string(plain), `"beta"`, `"gamma"`, 1)
+	edited = strings.Replace(edited, "plain: hello", "plain: !secret hello", 1)
+	edited += "  cert: !secret |\n    line1\n    line2\n"
+	out, n, err = EncryptSecrets([]byte(edited), secrets, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# secrets
+vars:
+  a: "ENC[AES256:k:alpha]" # keep
+  b: ENC[AES256:k:gamma]
+  list: ["ENC[AES256:k:alpha]", x]
+  plain: "ENC[AES256:k:hello]"
+  cert: "ENC[AES256:k:line1\nline2\n]"
+`
+	if n != 3 || string(out) != want {
+		t.Errorf("Unexpected result (%d changes):\n%s", n, out)
+	}
+
+	if _, _, err := EncryptSecrets([]byte("a: !secret\n  b: c\n"), nil, encrypt); err == nil {
+		t.Error("Expected error for !secret on a mapping")
+	}
+}
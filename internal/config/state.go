@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/melih-ucgun/veto/internal/crypto"
+	"github.com/melih-ucgun/veto/internal/state"
+)
+
+// LoadStateConfig reads only the 'state' section of a config file, for commands that
+// work on the state without applying the config (status, log, rollback, unlock).
+// A missing file or section means the default local backend (nil).
+func LoadStateConfig(path string, decrypt bool) (*state.BackendConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		State *state.BackendConfig `yaml:"state"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("yaml parse error (%s): %w", path, err)
+	}
+
+	expandStateConfig(doc.State)
+	if decrypt {
+		decryptStateConfig(doc.State, Keyring())
+	}
+	return doc.State, nil
+}
+
+func expandStateConfig(st *state.BackendConfig) {
+	if st == nil {
+		return
+	}
+	st.Path = os.ExpandEnv(st.Path)
+	st.Bucket = os.ExpandEnv(st.Bucket)
+	st.Key = os.ExpandEnv(st.Key)
+	st.Endpoint = os.ExpandEnv(st.Endpoint)
+	st.Region = os.ExpandEnv(st.Region)
+	st.AccessKey = os.ExpandEnv(st.AccessKey)
+	st.SecretKey = os.ExpandEnv(st.SecretKey)
+	st.MaxAge = os.ExpandEnv(st.MaxAge)
+}
+
+func decryptStateConfig(st *state.BackendConfig, keyring *crypto.Keyring) {
+	if st == nil {
+		return
+	}
+	for _, field := range []*string{&st.AccessKey, &st.SecretKey} {
+		if !crypto.IsEncrypted(*field) {
+			continue
+		}
+		if val, err := keyring.Decrypt(*field); err == nil {
+			*field = val
+		}
+	}
+}
@@ -12,11 +12,17 @@ const (
 	SystemProfileName = "system.yaml"
 	IgnoreFileName    = ".vetoignore"
 	MasterKeyFileName = "master.key"
+	IdentityFileName  = "identity"
+	RecipientsFile    = "recipients"
+	AgentSocketName   = "agent.sock"
 	BackupDirName     = "backups"
 	HubDirName        = "hub"
 	HubIndexDir       = "index"
 	RecipesDirName    = "recipes"
 	FilesDirName      = "files"
+	FactsDirName      = "facts"
+	HostsDirName      = "hosts"
+	CustomFactsDir    = "facts.d"
 	DefaultHubRepo    = "https://github.com/melih-ucgun/veto-recipes.git"
 )
 
@@ -33,6 +39,16 @@ func GetStateFilePath() string {
 	return filepath.Join(GetVetoDir(), StateFileName)
 }
 
+// GetFactsDir returns the directory holding cached host facts
+func GetFactsDir() string {
+	return filepath.Join(GetVetoDir(), FactsDirName)
+}
+
+// GetCustomFactsDir returns the directory holding user-defined facts.d sources
+func GetCustomFactsDir() string {
+	return filepath.Join(GetVetoDir(), CustomFactsDir)
+}
+
 // GetSystemProfilePath returns the path to the system profile file
 func GetSystemProfilePath() string {
 	return filepath.Join(GetVetoDir(), SystemProfileName)
@@ -52,6 +68,33 @@ func GetMasterKeyPath() (string, error) {
 	return filepath.Join(home, DefaultDirName, MasterKeyFileName), nil
 }
 
+// GetIdentityPath returns the default path for the user's X25519 identity
+func GetIdentityPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, DefaultDirName, IdentityFileName), nil
+}
+
+// GetAgentSocketPath returns the socket of the master key agent started by 'veto secret unlock'
+// (VETO_AGENT_SOCK overrides it)
+func GetAgentSocketPath() (string, error) {
+	if env := os.Getenv("VETO_AGENT_SOCK"); env != "" {
+		return env, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, DefaultDirName, AgentSocketName), nil
+}
+
+// GetRecipientsPath returns the path to the project's recipients file
+func GetRecipientsPath() string {
+	return filepath.Join(GetVetoDir(), RecipientsFile)
+}
+
 // GetHubIndexPath returns the path where the hub index is stored
 func GetHubIndexPath() (string, error) {
 	home, err := os.UserHomeDir()
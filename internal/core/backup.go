@@ -1,81 +1,156 @@
 package core
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
-	"time"
+	"strconv"
+	"strings"
+
+	"github.com/melih-ucgun/veto/internal/state"
 )
 
-// BackupManager, dosya yedekleme işlemlerini yönetir.
-type BackupManager struct {
-	BackupDir string
-	FS        FileSystem
-}
+// DefaultTreeBackupMaxSize limits directory backups unless backup_max_size is given.
+const DefaultTreeBackupMaxSize = 256 << 20
 
-// Global backup instance (basitlik için)
-var GlobalBackup *BackupManager
+// treeBackuper is implemented by backup managers that can back up whole directories.
+type treeBackuper interface {
+	CreateTreeBackup(txID, dir string, maxSize int64, exclude []string) (string, error)
+}
 
-// InitBackupManager, yedekleme dizinini hazırlar.
-func InitBackupManager(fs FileSystem) error {
-	// .veto/backups/YYYYMMDD-HHMMSS formatında klasör oluştur
-	timestamp := time.Now().Format("20060102-150405")
-	backupDir := filepath.Join(".veto", "backups", timestamp)
+// treeRestorer is implemented by backup managers that can restore directory backups.
+type treeRestorer interface {
+	RestoreTree(backupPath, target string) error
+}
 
-	if err := fs.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup dir: %w", err)
+// BackupTree backs up the directory path before a resource changes or removes it,
+// and returns the backup path (empty when there is nothing to back up). It follows
+// the resource parameters: backup (false disables it), backup_max_size (bytes, or
+// with a K/M/G suffix) and backup_exclude (globs). A directory over the limit is an
+// error, so nothing is destroyed without a way back.
+func BackupTree(ctx *SystemContext, path string, params map[string]interface{}) (string, error) {
+	if enabled, ok := params["backup"].(bool); ok && !enabled {
+		return "", nil
 	}
-
-	GlobalBackup = &BackupManager{
-		BackupDir: backupDir,
-		FS:        fs,
+	if ctx.BackupManager == nil || ctx.TxID == "" || ctx.DryRun {
+		return "", nil
 	}
-	return nil
-}
-
-// BackupFile, verilen dosyayı yedekler ve yedek yolunu döner.
-// Eğer dosya yoksa (yeni oluşturulacaksa), boş string döner (hata değil).
-func (bm *BackupManager) BackupFile(path string) (string, error) {
-	info, err := bm.FS.Stat(path)
+	info, err := ctx.FS.Stat(path)
 	if os.IsNotExist(err) {
-		return "", nil // Dosya yok, yedeklemeye gerek yok
+		return "", nil
 	}
 	if err != nil {
 		return "", err
 	}
-	if info.IsDir() {
-		// Klasör yedekleme şu an kapsam dışı (veya recursive yapılabilir)
-		return "", nil
+	if !info.IsDir() {
+		return ctx.BackupManager.CreateBackup(ctx.TxID, path)
 	}
 
-	// Yedek dosya yolu: backups/<timestamp>/hash_filename
-	// Hash kullanarak path çakışmalarını önlüyoruz (örn: /etc/hosts vs /tmp/hosts)
-	hasher := sha256.New()
-	hasher.Write([]byte(path))
-	pathHash := hex.EncodeToString(hasher.Sum(nil))[:8]
-
-	flatFilename := fmt.Sprintf("%s_%s", pathHash, filepath.Base(path))
-	backupPath := filepath.Join(bm.BackupDir, flatFilename)
+	tb, ok := ctx.BackupManager.(treeBackuper)
+	if !ok {
+		return "", fmt.Errorf("backup manager cannot back up directory %s", path)
+	}
+	maxSize := int64(DefaultTreeBackupMaxSize)
+	if v, ok := params["backup_max_size"]; ok {
+		if maxSize, err = parseSize(v); err != nil {
+			return "", fmt.Errorf("invalid backup_max_size: %w", err)
+		}
+	}
+	var exclude []string
+	switch v := params["backup_exclude"].(type) {
+	case string:
+		exclude = []string{v}
+	case []string:
+		exclude = v
+	case []interface{}:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				exclude = append(exclude, s)
+			}
+		}
+	}
 
-	// Dosyayı kopyala
-	srcFile, err := bm.FS.Open(path)
+	backupPath, err := tb.CreateTreeBackup(ctx.TxID, path, maxSize, exclude)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w (raise backup_max_size, add backup_exclude patterns or set backup: false)", err)
+	}
+	return backupPath, nil
+}
+
+// RestoreTree replaces the directory target with the backup at backupPath.
+func RestoreTree(ctx *SystemContext, backupPath, target string) error {
+	tr, ok := ctx.BackupManager.(treeRestorer)
+	if !ok {
+		return fmt.Errorf("backup manager cannot restore directory %s", target)
 	}
-	defer srcFile.Close()
+	ctx.Logger.Info(fmt.Sprintf("Restoring directory %s from %s", target, backupPath))
+	return tr.RestoreTree(backupPath, target)
+}
 
-	destFile, err := bm.FS.Create(backupPath)
-	if err != nil {
-		return "", err
+// parseSize parses a size in bytes: a number, or a string such as "512K", "100M" or
+// "2G" (an optional trailing "B" or "iB" is ignored). 0 means no limit.
+func parseSize(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float64:
+		return int64(n), nil
+	case string:
+		s := strings.ToUpper(strings.TrimSpace(n))
+		s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+		mult := int64(1)
+		if s != "" {
+			switch s[len(s)-1] {
+			case 'K':
+				mult = 1 << 10
+			case 'M':
+				mult = 1 << 20
+			case 'G':
+				mult = 1 << 30
+			}
+			if mult > 1 {
+				s = s[:len(s)-1]
+			}
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || size < 0 {
+			return 0, fmt.Errorf("'%s' is not a size", n)
+		}
+		return size * mult, nil
 	}
-	defer destFile.Close()
+	return 0, fmt.Errorf("%v is not a size", v)
+}
 
-	if _, err := io.Copy(destFile, srcFile); err != nil {
-		return "", err
+// IsTreeBackup reports whether backupPath holds a directory backup.
+func IsTreeBackup(backupPath string) bool {
+	return state.IsTreeBackup(backupPath)
+}
+
+// DirSnapshot records whether the directory a resource installs into exists, and
+// the directory itself for a rollback that only knows the resource name.
+func DirSnapshot(ctx *SystemContext, dir string) (Snapshot, error) {
+	_, err := ctx.FS.Stat(dir)
+	if os.IsNotExist(err) {
+		return Snapshot{"exists": false, "dir": dir}, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	return Snapshot{"exists": true, "dir": dir}, nil
+}
 
-	return backupPath, nil
+// RevertDir returns dir to its state before a resource installed into it: it is
+// restored from backupPath when there is one, and removed when before says it
+// didn't exist. Without either, it is left alone.
+func RevertDir(ctx *SystemContext, dir, backupPath string, before Snapshot) error {
+	if backupPath != "" {
+		return RestoreTree(ctx, backupPath, dir)
+	}
+	if before != nil && !before.Bool("exists") {
+		ctx.Logger.Info(fmt.Sprintf("Reverting creation of %s (deleting)", dir))
+		return ctx.FS.RemoveAll(dir)
+	}
+	ctx.Logger.Warn(fmt.Sprintf("No backup found for %s. Skipping rollback.", dir))
+	return nil
 }
@@ -3,6 +3,9 @@ package core
 import (
 	"context"
 	"os"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/secrets"
 )
 
 // SystemContext, uygulamanın çalışma anındaki bağlamını (context) tutar.
@@ -14,6 +17,7 @@ type SystemContext struct {
 	OS         string `yaml:"os"`          // runtime.GOOS (linux, darwin)
 	Kernel     string `yaml:"kernel"`      // 6.6.7-arch1-1
 	Distro     string `yaml:"distro"`      // ubuntu, arch, fedora
+	Family     string `yaml:"family"`      // debian, arch, rhel (ID + ID_LIKE)
 	Version    string `yaml:"version"`     // 22.04, 38, rolling
 	InitSystem string `yaml:"init_system"` // systemd, openrc, sysvinit
 	Hostname   string `yaml:"hostname"`    // Makine adı
@@ -27,6 +31,16 @@ type SystemContext struct {
 	// Dosya Sistemi Metadatası
 	FSInfo SystemFS `yaml:"fs"`
 
+	// Ağ Bilgileri
+	Network SystemNetwork `yaml:"network"`
+
+	// Disk ve Mount Bilgileri
+	Storage SystemStorage `yaml:"storage"`
+
+	// Sanallaştırma / Konteyner
+	Virtualization string `yaml:"virtualization"` // "none", "kvm", "vmware", "oracle"...
+	Container      string `yaml:"container"`      // "none", "docker", "podman", "lxc"...
+
 	// Dosya Sistemi Soyutlaması (İşlemler)
 	FS FileSystem `yaml:"-"`
 
@@ -51,6 +65,27 @@ type SystemContext struct {
 	// Logger (Yeni loglama sistemi)
 	Logger Logger `yaml:"-"`
 
+	// Facts holds user-defined facts from facts.d, accessible as .Facts.<name>
+	Facts map[string]interface{} `yaml:"facts,omitempty"`
+
+	// FactErrors holds the error of every custom fact that failed to load
+	FactErrors map[string]string `yaml:"-"`
+
+	// FactTimes records when each fact group (os, kernel, hardware...) was collected.
+	// Filled by system.Detect or restored from the fact cache.
+	FactTimes map[string]time.Time `yaml:"-"`
+
+	// Secrets resolves secret references for templates and conditions.
+	// Nil uses secrets.Default.
+	Secrets *secrets.Resolver `yaml:"-"`
+
+	// Keyring decrypts encrypted file sources (.enc) and encrypts backups of the files
+	// written from them. Nil when no keys are configured.
+	Keyring interface {
+		Decrypt(encrypted string) (string, error)
+		Encrypt(plaintext string) (string, error)
+	} `yaml:"-"`
+
 	// Transaction Context
 	TxID          string      `yaml:"-"`
 	BackupManager interface { // Avoid direct dependency cycle if possible, or use state.BackupManager
@@ -63,12 +98,37 @@ func (c *SystemContext) Variables() map[string]string {
 	return c.Vars
 }
 
+// Secret resolves a secret reference such as "pass:work/vpn", available as
+// {{ secret "..." }} in templates and Secret("...") in conditions.
+func (c *SystemContext) Secret(ref string) (string, error) {
+	resolver := c.Secrets
+	if resolver == nil {
+		resolver = secrets.Default
+	}
+	var ctx context.Context = c
+	if c.Context == nil {
+		ctx = context.Background()
+	}
+	return resolver.Lookup(ctx, ref)
+}
+
+// FactAge returns how old the given fact group is. Unknown facts report zero.
+func (c *SystemContext) FactAge(name string) time.Duration {
+	if t, ok := c.FactTimes[name]; ok {
+		return time.Since(t)
+	}
+	return 0
+}
+
 type SystemHardware struct {
 	CPUModel  string `yaml:"cpu_model"`  // "AMD Ryzen 7 5800X"
 	CPUCore   int    `yaml:"cpu_core"`   // Çekirdek sayısı
 	RAMTotal  string `yaml:"ram_total"`  // "16GB"
 	GPUVendor string `yaml:"gpu_vendor"` // "NVIDIA", "AMD", "Intel"
 	GPUModel  string `yaml:"gpu_model"`  // "RTX 3070"
+
+	IsLaptop   bool `yaml:"is_laptop"`   // Chassis type or battery presence
+	HasBattery bool `yaml:"has_battery"` // /sys/class/power_supply/*/type == Battery
 }
 
 type SystemEnv struct {
@@ -76,12 +136,48 @@ type SystemEnv struct {
 	Lang     string `yaml:"lang"`     // "en_US.UTF-8"
 	Term     string `yaml:"term"`     // "xterm-256color"
 	Timezone string `yaml:"timezone"` // "Europe/Istanbul"
+
+	Desktop     string `yaml:"desktop"`      // "GNOME", "KDE", "Hyprland" (XDG_CURRENT_DESKTOP)
+	SessionType string `yaml:"session_type"` // "wayland", "x11", "tty"
 }
 
 type SystemFS struct {
 	RootFSType string `yaml:"root_fs_type"` // "ext4", "btrfs", "zfs"
 }
 
+type SystemNetwork struct {
+	Interfaces     []NetInterface `yaml:"interfaces"`
+	DefaultGateway string         `yaml:"default_gateway"` // "192.168.1.1"
+	DefaultIface   string         `yaml:"default_iface"`   // "eth0"
+	PrimaryIP      string         `yaml:"primary_ip"`      // First IPv4 of the default interface
+}
+
+type NetInterface struct {
+	Name      string   `yaml:"name"`
+	MAC       string   `yaml:"mac"`
+	Up        bool     `yaml:"up"`
+	Addresses []string `yaml:"addresses"` // CIDR notation: "10.0.0.5/24"
+}
+
+type SystemStorage struct {
+	Disks  []BlockDevice `yaml:"disks"`
+	Mounts []Mount       `yaml:"mounts"`
+}
+
+type BlockDevice struct {
+	Name       string `yaml:"name"`       // "nvme0n1"
+	Size       int64  `yaml:"size"`       // Bytes
+	Type       string `yaml:"type"`       // "disk", "part", "crypt", "lvm"
+	Rotational bool   `yaml:"rotational"` // HDD
+	Model      string `yaml:"model"`
+}
+
+type Mount struct {
+	Device string `yaml:"device"`
+	Path   string `yaml:"path"`
+	FSType string `yaml:"fs_type"`
+}
+
 func NewSystemContext(dryRun bool, tr Transport) *SystemContext {
 	return &SystemContext{
 		Context:    context.Background(),
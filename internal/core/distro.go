@@ -0,0 +1,63 @@
+package core
+
+import "strings"
+
+// Distro families. Adapters select providers by family so derivatives
+// (Garuda, Zorin, Rocky...) work without being listed one by one.
+const (
+	FamilyArch   = "arch"
+	FamilyDebian = "debian"
+	FamilyRHEL   = "rhel"
+	FamilySUSE   = "suse"
+	FamilyAlpine = "alpine"
+	FamilyGentoo = "gentoo"
+	FamilyVoid   = "void"
+	FamilyNixOS  = "nixos"
+	FamilyDarwin = "darwin"
+)
+
+// distroFamilies maps well-known os-release IDs to their family.
+var distroFamilies = map[string]string{
+	"arch": FamilyArch, "archarm": FamilyArch, "cachyos": FamilyArch, "manjaro": FamilyArch,
+	"endeavouros": FamilyArch, "garuda": FamilyArch, "artix": FamilyArch,
+
+	"debian": FamilyDebian, "ubuntu": FamilyDebian, "pop": FamilyDebian, "mint": FamilyDebian,
+	"linuxmint": FamilyDebian, "kali": FamilyDebian, "raspbian": FamilyDebian, "zorin": FamilyDebian,
+	"elementary": FamilyDebian, "neon": FamilyDebian,
+
+	"fedora": FamilyRHEL, "rhel": FamilyRHEL, "centos": FamilyRHEL, "almalinux": FamilyRHEL,
+	"rocky": FamilyRHEL, "ol": FamilyRHEL, "amzn": FamilyRHEL, "nobara": FamilyRHEL,
+
+	"opensuse": FamilySUSE, "opensuse-leap": FamilySUSE, "opensuse-tumbleweed": FamilySUSE,
+	"sles": FamilySUSE, "suse": FamilySUSE,
+
+	"alpine": FamilyAlpine,
+	"gentoo": FamilyGentoo,
+	"void":   FamilyVoid,
+	"nixos":  FamilyNixOS,
+	"darwin": FamilyDarwin,
+}
+
+// DistroFamily derives the distro family from the os-release ID and ID_LIKE values.
+// The ID wins when it is known; otherwise the ID_LIKE entries are tried in order.
+// Unknown distributions return an empty string.
+func DistroFamily(id string, idLike string) string {
+	if family, ok := distroFamilies[strings.ToLower(id)]; ok {
+		return family
+	}
+	for _, like := range strings.Fields(strings.ToLower(idLike)) {
+		if family, ok := distroFamilies[like]; ok {
+			return family
+		}
+	}
+	return ""
+}
+
+// DistroFamily returns the detected family, deriving it from Distro when
+// detection did not run (e.g. a hand-written system profile).
+func (c *SystemContext) DistroFamily() string {
+	if c.Family != "" {
+		return c.Family
+	}
+	return DistroFamily(c.Distro, "")
+}
@@ -0,0 +1,31 @@
+package core
+
+import "testing"
+
+func TestDistroFamily(t *testing.T) {
+	tests := []struct {
+		id     string
+		idLike string
+		want   string
+	}{
+		{"arch", "", FamilyArch},
+		{"garuda", "arch", FamilyArch},
+		{"zorin", "ubuntu debian", FamilyDebian},
+		{"someubuntufork", "ubuntu", FamilyDebian},
+		{"rocky", "rhel centos fedora", FamilyRHEL},
+		{"opensuse-tumbleweed", "opensuse suse", FamilySUSE},
+		{"exotic", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := DistroFamily(tt.id, tt.idLike); got != tt.want {
+			t.Errorf("DistroFamily(%q, %q) = %q, want %q", tt.id, tt.idLike, got, tt.want)
+		}
+	}
+
+	// Family falls back to the distro ID when detection did not run
+	ctx := &SystemContext{Distro: "manjaro"}
+	if got := ctx.DistroFamily(); got != FamilyArch {
+		t.Errorf("Expected arch family fallback, got %q", got)
+	}
+}
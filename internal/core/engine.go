@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pterm/pterm"
 
+	"github.com/melih-ucgun/veto/internal/secrets"
 	"github.com/melih-ucgun/veto/internal/state"
 	"github.com/melih-ucgun/veto/internal/types"
 )
@@ -49,8 +50,6 @@ type Engine struct {
 
 // NewEngine creates a new engine instance.
 func NewEngine(ctx *SystemContext, updater StateUpdater) *Engine {
-	// Initialize Backup Manager
-	_ = InitBackupManager(ctx.FS) // Ignore error for now (or log)
 	return &Engine{
 		Context:      ctx,
 		StateUpdater: updater,
@@ -114,7 +113,7 @@ func (e *Engine) runSequential(items []ConfigItem, createFn ResourceCreator) err
 
 	// Initialize Backup Manager for this transaction
 	e.Context.TxID = transaction.ID
-	e.Context.BackupManager = state.NewBackupManager("") // Use default path
+	e.initBackupManager()
 
 	for _, item := range items {
 		// Params preparation
@@ -177,6 +176,7 @@ func (e *Engine) runSequential(items []ConfigItem, createFn ResourceCreator) err
 				pendingDiff = d
 			}
 		}
+		before := e.captureSnapshot(res)
 
 		// 2. Apply resource
 		result, err := res.Apply(e.Context)
@@ -210,6 +210,7 @@ func (e *Engine) runSequential(items []ConfigItem, createFn ResourceCreator) err
 				Name:   item.Name,
 				Action: "applied",
 				Diff:   pendingDiff,
+				Before: before,
 			}
 
 			// Try to get target path (specifically for file)
@@ -285,7 +286,7 @@ func (e *Engine) RunParallel(layer []ConfigItem, createFn ResourceCreator) error
 
 	// Initialize Backup Manager
 	e.Context.TxID = transaction.ID
-	e.Context.BackupManager = state.NewBackupManager("") // Use default path
+	e.initBackupManager()
 
 	for _, item := range layer {
 		wg.Add(1)
@@ -303,7 +304,7 @@ func (e *Engine) RunParallel(layer []ConfigItem, createFn ResourceCreator) error
 			if it.When != "" {
 				shouldRun, err := EvaluateCondition(it.When, e.Context)
 				if err != nil {
-					pterm.Error.Printf("[%s] Condition Error: %v\n", it.Name, err)
+					pterm.Error.Printf("[%s] Condition Error: %s\n", it.Name, secrets.Redact(err.Error()))
 					errChan <- err
 					return
 				}
@@ -352,6 +353,7 @@ func (e *Engine) RunParallel(layer []ConfigItem, createFn ResourceCreator) error
 					pendingDiff = d
 				}
 			}
+			before := e.captureSnapshot(res)
 
 			// 2. Apply resource
 			result, err := res.Apply(e.Context)
@@ -406,6 +408,7 @@ func (e *Engine) RunParallel(layer []ConfigItem, createFn ResourceCreator) error
 					Name:   it.Name,
 					Action: "applied",
 					Diff:   pendingDiff,
+					Before: before,
 				}
 
 				// Try to get target path
@@ -576,7 +579,7 @@ func (e *Engine) Plan(items []ConfigItem, createFn ResourceCreator) (*PlanResult
 				Type:   item.Type,
 				Name:   item.Name,
 				Action: action,
-				Diff:   diff,
+				Diff:   secrets.Redact(diff),
 			})
 		}
 	}
@@ -592,7 +595,7 @@ func (e *Engine) rollback(resources []Resource) {
 		if rev, ok := res.(Revertable); ok {
 			pterm.Warning.Printf("Visualizing Rollback for %s...\n", res.GetName())
 			if err := rev.Revert(e.Context); err != nil {
-				pterm.Error.Printf("Failed to revert %s: %v\n", res.GetName(), err)
+				pterm.Error.Printf("Failed to revert %s: %s\n", res.GetName(), secrets.Redact(err.Error()))
 				if !e.Context.DryRun && e.StateUpdater != nil {
 					_ = e.StateUpdater.UpdateResource(res.GetType(), res.GetName(), "any", "revert_failed")
 				}
@@ -838,6 +841,33 @@ func (e *Engine) Prune(configItems []ConfigItem, createFn ResourceCreator) error
 	return nil
 }
 
+// initBackupManager sets up the default backup store unless the caller provided one
+// (fleet hosts have their own). Files are backed up through the context filesystem.
+func (e *Engine) initBackupManager() {
+	if e.Context.BackupManager != nil {
+		return
+	}
+	bm := state.NewBackupManager("") // Use default path
+	bm.FS = e.Context.FS
+	e.Context.BackupManager = bm
+}
+
+// captureSnapshot records the state of res before Apply, if it supports snapshots.
+// A failed snapshot only costs rollback precision, so it is logged and ignored.
+func (e *Engine) captureSnapshot(res Resource) map[string]interface{} {
+	s, ok := res.(Snapshotter)
+	if !ok || e.Context.DryRun {
+		return nil
+	}
+	snap, err := s.Snapshot(e.Context)
+	if err != nil {
+		e.Context.Logger.Warn(fmt.Sprintf("[%s] Failed to capture state before apply: %v", res.GetName(), err))
+		return nil
+	}
+	s.SetSnapshot(snap)
+	return snap
+}
+
 // executeHook executes a shell command using the context's transport.
 func executeHook(ctx *SystemContext, cmd string) error {
 	if ctx.DryRun {
@@ -847,7 +877,8 @@ func executeHook(ctx *SystemContext, cmd string) error {
 	// Use Transport to execute
 	out, err := ctx.Transport.Execute(ctx.Context, cmd)
 	if err != nil {
-		return fmt.Errorf("command '%s' failed: %w, output: %s", cmd, err, string(out))
+		// Hooks may reference decrypted vars; keep them out of the error
+		return fmt.Errorf("command '%s' failed: %w, output: %s", secrets.Redact(cmd), err, secrets.Redact(string(out)))
 	}
 	return nil
 }
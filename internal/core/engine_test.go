@@ -70,6 +70,7 @@ type MockStateUpdater struct {
 	Updates []struct {
 		Type, Name, TargetState, Status string
 	}
+	Transactions []types.Transaction
 }
 
 func (m *MockStateUpdater) UpdateResource(resType, name, targetState, status string) error {
@@ -80,9 +81,45 @@ func (m *MockStateUpdater) UpdateResource(resType, name, targetState, status str
 }
 
 func (m *MockStateUpdater) AddTransaction(tx types.Transaction) error {
+	m.Transactions = append(m.Transactions, tx)
 	return nil
 }
 
+// SnapshotResource is a MockResource that records its state before Apply
+type SnapshotResource struct {
+	MockResource
+	Before core.Snapshot
+}
+
+func (m *SnapshotResource) Snapshot(ctx *core.SystemContext) (core.Snapshot, error) {
+	return core.Snapshot{"version": "1.0"}, nil
+}
+
+func (m *SnapshotResource) SetSnapshot(s core.Snapshot) { m.Before = s }
+
+func TestEngine_RecordsSnapshot(t *testing.T) {
+	updater := &MockStateUpdater{}
+	engine := core.NewEngine(core.NewSystemContext(false, nil), updater)
+
+	res := &SnapshotResource{MockResource: MockResource{Name: "res1", Type: "test", ApplyResult: core.SuccessChange("ok")}}
+	createFn := func(t, n string, p map[string]interface{}, c *core.SystemContext) (core.Resource, error) {
+		return res, nil
+	}
+
+	if err := engine.RunParallel([]core.ConfigItem{{Name: "res1", Type: "test"}}, createFn); err != nil {
+		t.Fatal(err)
+	}
+	if res.Before.String("version") != "1.0" {
+		t.Error("Snapshot not handed to the resource for Revert")
+	}
+	if len(updater.Transactions) != 1 || len(updater.Transactions[0].Changes) != 1 {
+		t.Fatalf("Unexpected transactions: %+v", updater.Transactions)
+	}
+	if v := updater.Transactions[0].Changes[0].Before["version"]; v != "1.0" {
+		t.Errorf("Snapshot not recorded with the change, got %v", v)
+	}
+}
+
 func TestEngine_RunParallel(t *testing.T) {
 	ctx := core.NewSystemContext(false, nil)
 
@@ -13,24 +13,43 @@ func EvaluateCondition(condition string, ctx *SystemContext) (bool, error) {
 		return true, nil
 	}
 
+	output, err := EvaluateExpression(condition, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Check result type
+	result, ok := output.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition must return a boolean, got %T", output)
+	}
+
+	return result, nil
+}
+
+// EvaluateExpression compiles and runs an expression against the SystemContext and
+// returns its raw result. Compile errors wrap expr's *file.Error, which carries the
+// line/column of the failure.
+func EvaluateExpression(expression string, ctx *SystemContext) (interface{}, error) {
 	// Compile the expression
 	// We pass the ctx struct directly so fields like OS, Hardware.GPUVendor can be accessed.
-	program, err := expr.Compile(condition, expr.Env(ctx))
+	program, err := expr.Compile(expression, expr.Env(ctx), secretFunction(ctx))
 	if err != nil {
-		return false, fmt.Errorf("invalid condition '%s': %v", condition, err)
+		return nil, fmt.Errorf("invalid condition '%s': %w", expression, err)
 	}
 
 	// Run the expression
 	output, err := expr.Run(program, ctx)
 	if err != nil {
-		return false, fmt.Errorf("evaluation failed: %v", err)
+		return nil, fmt.Errorf("evaluation failed: %w", err)
 	}
 
-	// Check result type
-	result, ok := output.(bool)
-	if !ok {
-		return false, fmt.Errorf("condition must return a boolean, got %T", output)
-	}
+	return output, nil
+}
 
-	return result, nil
+// secretFunction exposes secret("pass:work/vpn") in conditions, mirroring the template function.
+func secretFunction(ctx *SystemContext) expr.Option {
+	return expr.Function("secret", func(params ...any) (any, error) {
+		return ctx.Secret(params[0].(string))
+	}, new(func(string) (string, error)))
 }
@@ -4,6 +4,8 @@ import (
 	"io"
 	"io/fs"
 	"os"
+
+	"github.com/melih-ucgun/veto/internal/utils"
 )
 
 // FileSystem is an interface for filesystem operations
@@ -50,6 +52,24 @@ func (f *RealFS) Chmod(name string, mode os.FileMode) error    { return os.Chmod
 func (f *RealFS) Open(name string) (File, error)               { return os.Open(name) }
 func (f *RealFS) Create(name string) (File, error)             { return os.Create(name) }
 func (f *RealFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (f *RealFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (f *RealFS) Chown(name string, uid, gid int) error        { return os.Lchown(name, uid, gid) }
+
+// CreateExclusive writes a new file, failing with an os.ErrExist error if it exists.
+func (f *RealFS) CreateExclusive(name string, data []byte, perm os.FileMode) error {
+	return utils.CreateExclusive(name, data, perm)
+}
+
+// AppendFile appends data to name and fsyncs it.
+func (f *RealFS) AppendFile(name string, data []byte, perm os.FileMode) error {
+	return utils.AppendFile(name, data, perm)
+}
+
+// WriteFileAtomic replaces name through a synced temporary file and a rename, so
+// readers see either the old or the new content.
+func (f *RealFS) WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	return utils.WriteFileAtomic(name, data, perm)
+}
 
 // CopyFile is a helper to copy a file using the FileSystem abstraction
 func CopyFile(fs FileSystem, src, dst string, mode os.FileMode) error {
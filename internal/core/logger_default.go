@@ -6,6 +6,8 @@ import (
 	"log/slog"
 
 	"github.com/pterm/pterm"
+
+	"github.com/melih-ucgun/veto/internal/secrets"
 )
 
 type DefaultLogger struct {
@@ -42,6 +44,7 @@ func NewDefaultLogger(output io.Writer, level LogLevel) *DefaultLogger {
 
 func (l *DefaultLogger) Trace(msg string, args ...any) {
 	if l.level <= LevelTrace {
+		msg, args = redact(msg, args)
 		pterm.Debug.WithWriter(l.output).Println("TRACE: " + msg)
 		l.handler.Debug(msg, args...)
 	}
@@ -49,6 +52,7 @@ func (l *DefaultLogger) Trace(msg string, args ...any) {
 
 func (l *DefaultLogger) Debug(msg string, args ...any) {
 	if l.level <= LevelDebug {
+		msg, args = redact(msg, args)
 		pterm.Debug.WithWriter(l.output).Println(msg)
 		l.handler.Debug(msg, args...)
 	}
@@ -56,6 +60,7 @@ func (l *DefaultLogger) Debug(msg string, args ...any) {
 
 func (l *DefaultLogger) Info(msg string, args ...any) {
 	if l.level <= LevelInfo {
+		msg, args = redact(msg, args)
 		pterm.Info.WithWriter(l.output).Println(msg)
 		l.handler.Info(msg, args...)
 	}
@@ -63,6 +68,7 @@ func (l *DefaultLogger) Info(msg string, args ...any) {
 
 func (l *DefaultLogger) Warn(msg string, args ...any) {
 	if l.level <= LevelWarn {
+		msg, args = redact(msg, args)
 		pterm.Warning.WithWriter(l.output).Println(msg)
 		l.handler.Warn(msg, args...)
 	}
@@ -70,6 +76,7 @@ func (l *DefaultLogger) Warn(msg string, args ...any) {
 
 func (l *DefaultLogger) Error(msg string, args ...any) {
 	if l.level <= LevelError {
+		msg, args = redact(msg, args)
 		pterm.Error.WithWriter(l.output).Println(msg)
 		l.handler.Error(msg, args...)
 	}
@@ -87,5 +94,17 @@ func (l *DefaultLogger) SetLevel(level LogLevel) {
 	l.level = level
 }
 
+// redact masks secrets in a log message and its string arguments.
+func redact(msg string, args []any) (string, []any) {
+	masked := make([]any, len(args))
+	for i, a := range args {
+		if str, ok := a.(string); ok {
+			a = secrets.Redact(str)
+		}
+		masked[i] = a
+	}
+	return secrets.Redact(msg), masked
+}
+
 // Dummy context to satisfy slogans
 var ctx = context.Background()
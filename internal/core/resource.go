@@ -21,6 +21,31 @@ type Revertable interface {
 	RevertAction(action string, ctx *SystemContext) error
 }
 
+// Snapshot is the state of a system object before Apply changed it, e.g. whether a
+// package was installed and at which version. It is stored with the transaction
+// so that RevertAction can restore exactly that state.
+type Snapshot map[string]interface{}
+
+// Bool returns the boolean stored under key, false if absent.
+func (s Snapshot) Bool(key string) bool {
+	b, _ := s[key].(bool)
+	return b
+}
+
+// String returns the string stored under key, "" if absent.
+func (s Snapshot) String(key string) string {
+	str, _ := s[key].(string)
+	return str
+}
+
+// Snapshotter is implemented by resources that can capture their state before Apply.
+// The engine calls Snapshot right before Apply and passes the result to SetSnapshot,
+// so Revert can use it; rollback passes the recorded snapshot before RevertAction.
+type Snapshotter interface {
+	Snapshot(ctx *SystemContext) (Snapshot, error)
+	SetSnapshot(s Snapshot)
+}
+
 // Lister is the interface for resources that can enumerate installed instances.
 // Required for Prune operations.
 type Lister interface {
@@ -2,9 +2,12 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
+
+	"github.com/melih-ucgun/veto/internal/secrets"
 )
 
 // ExecuteTemplate, verilen içeriği (content) sağlanan veri (data) ile işler.
@@ -12,7 +15,7 @@ import (
 func ExecuteTemplate(content string, data interface{}) (string, error) {
 	// missingkey=zero allows optional variables (returning nil/zero), which works with Sprig's 'default'.
 	// Use 'required' function from Sprig for mandatory variables.
-	tmpl, err := template.New("veto").Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(content)
+	tmpl, err := template.New("veto").Funcs(TemplateFuncs(data)).Option("missingkey=zero").Parse(content)
 	if err != nil {
 		return "", err
 	}
@@ -24,3 +27,17 @@ func ExecuteTemplate(content string, data interface{}) (string, error) {
 
 	return buf.String(), nil
 }
+
+// TemplateFuncs returns the functions available in templates: Sprig plus
+// "secret", which resolves a secret reference when the template is rendered.
+// Secrets are looked up through data's resolver if data is a *SystemContext.
+func TemplateFuncs(data interface{}) template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	funcs["secret"] = func(ref string) (string, error) {
+		if ctx, ok := data.(*SystemContext); ok && ctx != nil {
+			return ctx.Secret(ref)
+		}
+		return secrets.Default.Lookup(context.Background(), ref)
+	}
+	return funcs
+}
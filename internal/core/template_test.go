@@ -76,3 +76,22 @@ func TestExecuteTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestSecretLookup(t *testing.T) {
+	t.Setenv("VETO_TEST_SECRET", "s3cret")
+	ctx := NewSystemContext(false, nil)
+
+	got, err := ExecuteTemplate(`pw={{ secret "env:VETO_TEST_SECRET" }}`, ctx)
+	if err != nil || got != "pw=s3cret" {
+		t.Errorf("secret template function: got %q, %v", got, err)
+	}
+
+	ok, err := EvaluateCondition(`secret("env:VETO_TEST_SECRET") == "s3cret"`, ctx)
+	if err != nil || !ok {
+		t.Errorf("secret in condition: got %v, %v", ok, err)
+	}
+
+	if _, err := ExecuteTemplate(`{{ secret "nope:x" }}`, ctx); err == nil {
+		t.Error("Expected error for unknown secret provider")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
@@ -26,8 +27,31 @@ func GenerateKey() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// ErrKeyMismatch is returned when a value was encrypted with a different master key.
+var ErrKeyMismatch = errors.New("value was encrypted with a different master key")
+
+// KeyID returns the short fingerprint of a hex-encoded master key, stored in every
+// AES256 value so values from different keys can be told apart (e.g. during rotation).
+func KeyID(keyHex string) string {
+	sum := sha256.Sum256([]byte("veto-aes256:" + strings.ToLower(strings.TrimSpace(keyHex))))
+	return hex.EncodeToString(sum[:keyIDSize])
+}
+
+// splitAESValue returns the key ID (empty for legacy values) and base64 payload of an AES256 value.
+func splitAESValue(encrypted string) (string, string, error) {
+	if !strings.HasPrefix(encrypted, Prefix) || !strings.HasSuffix(encrypted, Suffix) {
+		return "", "", errors.New("invalid encrypted format")
+	}
+	body := encrypted[len(Prefix) : len(encrypted)-len(Suffix)]
+	// Base64 never contains ':', so a colon separates the key ID
+	if kid, b64, ok := strings.Cut(body, ":"); ok {
+		return kid, b64, nil
+	}
+	return "", body, nil
+}
+
 // Encrypt encrypts plaintext using AES-GCM with the provided hex-encoded key.
-// Returns formatted string: ENC[AES256:<base64_ciphertext>]
+// Returns formatted string: ENC[AES256:<key_id>:<base64_ciphertext>]
 func Encrypt(plaintext, keyHex string) (string, error) {
 	key, err := hex.DecodeString(keyHex)
 	if err != nil {
@@ -50,18 +74,20 @@ func Encrypt(plaintext, keyHex string) (string, error) {
 	}
 
 	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return fmt.Sprintf("%s%s%s", Prefix, base64.StdEncoding.EncodeToString(ciphertext), Suffix), nil
+	return fmt.Sprintf("%s%s:%s%s", Prefix, KeyID(keyHex), base64.StdEncoding.EncodeToString(ciphertext), Suffix), nil
 }
 
 // Decrypt decrypts a formatted string using the provided hex-encoded key.
-// Expected format: ENC[AES256:<base64_ciphertext>]
+// Expected format: ENC[AES256:<key_id>:<base64_ciphertext>] or the legacy ENC[AES256:<base64_ciphertext>]
 func Decrypt(encrypted, keyHex string) (string, error) {
-	if !strings.HasPrefix(encrypted, Prefix) || !strings.HasSuffix(encrypted, Suffix) {
-		return "", errors.New("invalid encrypted format")
+	kid, b64, err := splitAESValue(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if kid != "" && kid != KeyID(keyHex) {
+		return "", fmt.Errorf("%w (value key %s, current key %s)", ErrKeyMismatch, kid, KeyID(keyHex))
 	}
 
-	// Remove Prefix and Suffix
-	b64 := encrypted[len(Prefix) : len(encrypted)-len(Suffix)]
 	ciphertext, err := base64.StdEncoding.DecodeString(b64)
 	if err != nil {
 		return "", fmt.Errorf("base64 decode failed: %w", err)
@@ -96,7 +122,27 @@ func Decrypt(encrypted, keyHex string) (string, error) {
 	return string(plaintext), nil
 }
 
-// IsEncrypted checks if a string follows the encrypted format.
+// ValueKeyIDs returns the key IDs a value can be decrypted with: the master key ID for
+// AES256 values ("legacy" when the value predates key IDs) or every recipient key ID
+// for X25519 values.
+func ValueKeyIDs(encrypted string) ([]string, error) {
+	if strings.HasPrefix(encrypted, X25519Prefix) {
+		return x25519KeyIDs(encrypted)
+	}
+	kid, _, err := splitAESValue(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if kid == "" {
+		kid = LegacyKeyID
+	}
+	return []string{kid}, nil
+}
+
+// LegacyKeyID stands for AES256 values written before key IDs were added.
+const LegacyKeyID = "legacy"
+
+// IsEncrypted checks if a string follows one of the encrypted formats (AES256 or X25519).
 func IsEncrypted(s string) bool {
-	return strings.HasPrefix(s, Prefix) && strings.HasSuffix(s, Suffix)
+	return (strings.HasPrefix(s, Prefix) || strings.HasPrefix(s, X25519Prefix)) && strings.HasSuffix(s, Suffix)
 }
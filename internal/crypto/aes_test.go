@@ -1,7 +1,12 @@
 package crypto
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerateKey(t *testing.T) {
@@ -64,3 +69,88 @@ func TestDecryptInvalidKey(t *testing.T) {
 		t.Error("Expected error for wrong key, got none")
 	}
 }
+
+func TestKeyIDs(t *testing.T) {
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+
+	encrypted, _ := Encrypt("value", oldKey)
+	ids, err := ValueKeyIDs(encrypted)
+	if err != nil || len(ids) != 1 || ids[0] != KeyID(oldKey) {
+		t.Errorf("Unexpected key IDs %v (%v), want %s", ids, err, KeyID(oldKey))
+	}
+
+	if _, err := Decrypt(encrypted, newKey); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Expected ErrKeyMismatch, got %v", err)
+	}
+
+	// Values written before key IDs existed still decrypt and report as legacy
+	_, b64, _ := splitAESValue(encrypted)
+	legacy := Prefix + b64 + Suffix
+	if plain, err := Decrypt(legacy, oldKey); err != nil || plain != "value" {
+		t.Errorf("Legacy value failed to decrypt: %q, %v", plain, err)
+	}
+	if ids, _ := ValueKeyIDs(legacy); len(ids) != 1 || ids[0] != LegacyKeyID {
+		t.Errorf("Expected legacy key ID, got %v", ids)
+	}
+}
+
+func TestWrapKey(t *testing.T) {
+	key, _ := GenerateKey()
+
+	wrapped, err := WrapKey(key, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsWrappedKey(wrapped) || IsWrappedKey(key) {
+		t.Error("IsWrappedKey misdetects")
+	}
+	if strings.Contains(wrapped, key) {
+		t.Error("Wrapped key contains the plaintext key")
+	}
+
+	if got, err := UnwrapKey(wrapped, "correct horse"); err != nil || got != key {
+		t.Errorf("Unwrap failed: %q, %v", got, err)
+	}
+	if _, err := UnwrapKey(wrapped, "wrong"); err != ErrWrongPassphrase {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	if _, err := AgentKey(socket); err != ErrAgentNotRunning {
+		t.Fatalf("Expected ErrAgentNotRunning, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- ServeAgent(socket, "cafe", time.Minute) }()
+
+	var key string
+	var err error
+	for i := 0; i < 50; i++ {
+		if key, err = AgentKey(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if key != "cafe" {
+		t.Fatalf("Agent returned %q, %v", key, err)
+	}
+	if left, err := AgentStatus(socket); err != nil || left <= 0 {
+		t.Errorf("Unexpected status: %v, %v", left, err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Socket must be owner-only: %v", err)
+	}
+
+	if err := LockAgent(socket); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AgentKey(socket); err != ErrAgentNotRunning {
+		t.Errorf("Agent still serving after lock: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package crypto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The agent keeps an unwrapped master key in memory for a limited time and hands it
+// out over a unix socket only the owner can connect to (mode 0600). Requests and
+// responses are single lines:
+//
+//	GET    -> OK <key>
+//	STATUS -> OK <seconds left>
+//	LOCK   -> OK            (the agent exits)
+const agentDialTimeout = 2 * time.Second
+
+// ErrAgentNotRunning is returned when no agent listens on the socket.
+var ErrAgentNotRunning = errors.New("key agent is not running")
+
+// ServeAgent serves key on socketPath until ttl expires or a LOCK request arrives.
+// A stale socket left by a crashed agent is replaced; a live one is an error.
+func ServeAgent(socketPath, key string, ttl time.Duration) error {
+	if _, err := AgentStatus(socketPath); err == nil {
+		return fmt.Errorf("a key agent is already running on %s", socketPath)
+	}
+	os.Remove(socketPath)
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return err
+	}
+
+	// Create the socket owner-only, not just chmod it afterwards
+	oldMask := syscall.Umask(0077)
+	listener, err := net.Listen("unix", socketPath)
+	syscall.Umask(oldMask)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	deadline := time.Now().Add(ttl)
+	var once sync.Once
+	stop := func() { once.Do(func() { listener.Close() }) }
+	timer := time.AfterFunc(ttl, stop)
+	defer timer.Stop()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Closed by the timer or a LOCK request
+			return nil
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(agentDialTimeout))
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch strings.TrimSpace(line) {
+			case "GET":
+				fmt.Fprintf(conn, "OK %s\n", key)
+			case "STATUS":
+				fmt.Fprintf(conn, "OK %d\n", int(time.Until(deadline).Seconds()))
+			case "LOCK":
+				fmt.Fprintln(conn, "OK")
+				stop()
+			default:
+				fmt.Fprintln(conn, "ERR unknown request")
+			}
+		}()
+	}
+}
+
+// AgentKey returns the master key cached by the agent.
+func AgentKey(socketPath string) (string, error) {
+	return agentRequest(socketPath, "GET")
+}
+
+// AgentStatus returns how long the agent keeps the key cached.
+func AgentStatus(socketPath string) (time.Duration, error) {
+	resp, err := agentRequest(socketPath, "STATUS")
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.Atoi(resp)
+	if err != nil {
+		return 0, fmt.Errorf("invalid agent response: %q", resp)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// LockAgent makes the agent forget the key and exit.
+func LockAgent(socketPath string) error {
+	_, err := agentRequest(socketPath, "LOCK")
+	return err
+}
+
+func agentRequest(socketPath, request string) (string, error) {
+	conn, err := net.DialTimeout("unix", socketPath, agentDialTimeout)
+	if err != nil {
+		return "", ErrAgentNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentDialTimeout))
+
+	if _, err := fmt.Fprintln(conn, request); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("key agent: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "OK" {
+		return "", nil
+	}
+	if resp, ok := strings.CutPrefix(line, "OK "); ok {
+		return resp, nil
+	}
+	return "", fmt.Errorf("key agent: %s", strings.TrimPrefix(line, "ERR "))
+}
@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EncryptedFileExt marks encrypted whole-file sources: id_ed25519.enc, wg0.conf.enc
+	EncryptedFileExt = ".enc"
+
+	FileHeader = "-----BEGIN VETO ENCRYPTED FILE-----"
+	FileFooter = "-----END VETO ENCRYPTED FILE-----"
+
+	fileLineWidth = 64
+)
+
+// ArmorFile wraps an encrypted value (ENC[...]) into the text format of encrypted
+// files: a header, the value split into 64-column lines and a footer. The result is
+// safe to commit and diff.
+func ArmorFile(encrypted string) []byte {
+	var b strings.Builder
+	b.WriteString(FileHeader + "\n")
+	for len(encrypted) > fileLineWidth {
+		b.WriteString(encrypted[:fileLineWidth] + "\n")
+		encrypted = encrypted[fileLineWidth:]
+	}
+	b.WriteString(encrypted + "\n")
+	b.WriteString(FileFooter + "\n")
+	return []byte(b.String())
+}
+
+// DearmorFile returns the encrypted value stored in an encrypted file. A bare
+// ENC[...] value without header is accepted as well.
+func DearmorFile(data []byte) (string, error) {
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, FileHeader) {
+		end := strings.Index(text, FileFooter)
+		if end < 0 {
+			return "", errors.New("encrypted file is truncated (missing footer)")
+		}
+		text = text[len(FileHeader):end]
+	}
+
+	value := strings.Join(strings.Fields(text), "")
+	if !IsEncrypted(value) {
+		return "", errors.New("not a veto encrypted file")
+	}
+	return value, nil
+}
+
+// IsEncryptedFile reports whether a file holds encrypted content, judged by its
+// extension or its header.
+func IsEncryptedFile(path string, data []byte) bool {
+	if filepath.Ext(path) == EncryptedFileExt {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(data)), FileHeader)
+}
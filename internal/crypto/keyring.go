@@ -0,0 +1,228 @@
+package crypto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/melih-ucgun/veto/internal/consts"
+)
+
+// Keyring holds every key able to decrypt values and picks the right one per format.
+type Keyring struct {
+	MasterKey  string      // Hex AES key for ENC[AES256:...]
+	Identities []*Identity // X25519 identities for ENC[X25519:...]
+
+	// LoadMasterKey is called once, on the first AES value, when MasterKey is empty.
+	// This keeps the interactive master key prompt out of X25519-only configs.
+	LoadMasterKey func() string
+	loaded        bool
+	mu            sync.Mutex // Guards the lazy master key load; adapters decrypt in parallel
+
+	// OnDecrypt, when set, receives every successfully decrypted plaintext
+	// (used to taint secrets for redaction).
+	OnDecrypt func(plaintext string)
+}
+
+// Decrypt decrypts an AES256 or X25519 value with the matching key.
+func (k *Keyring) Decrypt(encrypted string) (string, error) {
+	plaintext, err := k.decrypt(encrypted)
+	if err == nil && k.OnDecrypt != nil {
+		k.OnDecrypt(plaintext)
+	}
+	return plaintext, err
+}
+
+func (k *Keyring) decrypt(encrypted string) (string, error) {
+	switch {
+	case !IsEncrypted(encrypted):
+		return "", errors.New("value is not encrypted")
+	case strings.HasPrefix(encrypted, X25519Prefix):
+		if len(k.Identities) == 0 {
+			return "", errors.New("no X25519 identity available (run 'veto secret keygen --asymmetric')")
+		}
+		return DecryptX25519(encrypted, k.Identities)
+	default:
+		key := k.masterKey()
+		if key == "" {
+			return "", errors.New("master key not found")
+		}
+		return Decrypt(encrypted, key)
+	}
+}
+
+func (k *Keyring) masterKey() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.MasterKey == "" && !k.loaded && k.LoadMasterKey != nil {
+		k.MasterKey = strings.TrimSpace(k.LoadMasterKey())
+		k.loaded = true
+	}
+	return k.MasterKey
+}
+
+// WithoutIdentities returns a keyring that only decrypts AES256 values. Fleet mode
+// loads the config with it, so X25519 values reach each host encrypted and are
+// decrypted there with the host's own identity.
+func (k *Keyring) WithoutIdentities() *Keyring {
+	return &Keyring{
+		MasterKey:     k.MasterKey,
+		LoadMasterKey: k.LoadMasterKey,
+		OnDecrypt:     k.OnDecrypt,
+	}
+}
+
+// Encrypt encrypts a value so that this keyring can decrypt it again: to the public
+// keys of its identities, or with the master key if it has no identity. Used for
+// backups of decrypted files.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if len(k.Identities) > 0 {
+		recipients := make([]string, len(k.Identities))
+		for i, id := range k.Identities {
+			recipients[i] = id.Recipient()
+		}
+		return EncryptX25519(plaintext, recipients)
+	}
+	key := k.masterKey()
+	if key == "" {
+		return "", errors.New("master key not found")
+	}
+	return Encrypt(plaintext, key)
+}
+
+// Recipient is an entry of the recipients file.
+type Recipient struct {
+	PublicKey string
+	Name      string // Optional label (person or host)
+}
+
+// LoadRecipients reads a recipients file. Each line holds a public key and an optional
+// name; blank lines and '#' comments are ignored:
+//
+//	veto-pub-Xk3...  alice
+//	veto-pub-9aQ...  web1.example.com
+func LoadRecipients(path string) ([]Recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recipients []Recipient
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if _, err := ParseRecipient(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		r := Recipient{PublicKey: fields[0]}
+		if len(fields) > 1 {
+			r.Name = strings.Join(fields[1:], " ")
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, scanner.Err()
+}
+
+// AppendRecipient adds a public key to the recipients file, creating it if needed.
+func AppendRecipient(path string, r Recipient) error {
+	existing, err := LoadRecipients(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, e := range existing {
+		if e.PublicKey == r.PublicKey {
+			return fmt.Errorf("recipient already present in %s", path)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line := r.PublicKey
+	if r.Name != "" {
+		line += " " + r.Name
+	}
+	_, err = fmt.Fprintln(f, line)
+	return err
+}
+
+// PublicKeys returns the keys of the given recipients.
+func PublicKeys(recipients []Recipient) []string {
+	keys := make([]string, len(recipients))
+	for i, r := range recipients {
+		keys[i] = r.PublicKey
+	}
+	return keys
+}
+
+// LoadIdentities collects X25519 identities from VETO_IDENTITY (the key itself),
+// VETO_IDENTITY_FILE and ~/.veto/identity. Missing sources are skipped.
+func LoadIdentities() ([]*Identity, error) {
+	var identities []*Identity
+
+	if env := os.Getenv("VETO_IDENTITY"); env != "" {
+		id, err := ParseIdentity(env)
+		if err != nil {
+			return nil, fmt.Errorf("VETO_IDENTITY: %w", err)
+		}
+		identities = append(identities, id)
+	}
+
+	var paths []string
+	if p := os.Getenv("VETO_IDENTITY_FILE"); p != "" {
+		paths = append(paths, p)
+	}
+	if p, err := consts.GetIdentityPath(); err == nil {
+		paths = append(paths, p)
+	}
+
+	for _, p := range paths {
+		ids, err := ReadIdentityFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, ids...)
+	}
+	return identities, nil
+}
+
+// ReadIdentityFile parses every identity in a file (one per line, '#' comments allowed).
+func ReadIdentityFile(path string) ([]*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []*Identity
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		identities = append(identities, id)
+	}
+	return identities, nil
+}
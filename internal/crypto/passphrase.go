@@ -0,0 +1,123 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// WrappedKeyPrefix marks a master key file protected by a passphrase:
+//
+//	VETO-WRAPPED-KEY[ARGON2ID:t=3,m=65536,p=4:<salt>:<nonce|sealed key>]
+const WrappedKeyPrefix = "VETO-WRAPPED-KEY[ARGON2ID:"
+
+// Default argon2id parameters (RFC 9106 second recommended option).
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+	argonSaltLen = 16
+)
+
+// ErrWrongPassphrase is returned when a wrapped key cannot be opened with a passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// IsWrappedKey reports whether the content of a master key file is passphrase-protected.
+func IsWrappedKey(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, WrappedKeyPrefix) && strings.HasSuffix(s, Suffix)
+}
+
+// WrapKey seals a hex master key with a key derived from passphrase (argon2id, AES-GCM).
+func WrapKey(keyHex, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	gcm, err := passphraseCipher(passphrase, salt, argonTime, argonMemory, argonThreads)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(strings.TrimSpace(keyHex)), []byte(WrappedKeyPrefix))
+
+	return fmt.Sprintf("%st=%d,m=%d,p=%d:%s:%s%s", WrappedKeyPrefix, argonTime, argonMemory, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(sealed), Suffix), nil
+}
+
+// UnwrapKey opens a wrapped master key and returns the hex key.
+func UnwrapKey(wrapped, passphrase string) (string, error) {
+	wrapped = strings.TrimSpace(wrapped)
+	if !IsWrappedKey(wrapped) {
+		return "", errors.New("master key is not passphrase-protected")
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(wrapped, WrappedKeyPrefix), Suffix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("invalid wrapped key format")
+	}
+
+	var t, m, p uint64
+	for _, param := range strings.Split(parts[0], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid wrapped key parameter '%s'", param)
+		}
+		switch name {
+		case "t":
+			t = n
+		case "m":
+			m = n
+		case "p":
+			p = n
+		}
+	}
+	if t == 0 || m == 0 || p == 0 || p > 255 {
+		return "", errors.New("invalid wrapped key parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapped key salt: %w", err)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid wrapped key: %w", err)
+	}
+
+	gcm, err := passphraseCipher(passphrase, salt, uint32(t), uint32(m), uint8(p))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("wrapped key too short")
+	}
+	key, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(WrappedKeyPrefix))
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(key), nil
+}
+
+func passphraseCipher(passphrase string, salt []byte, t, m uint32, p uint8) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, t, m, p, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
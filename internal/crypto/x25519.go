@@ -0,0 +1,295 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	X25519Prefix = "ENC[X25519:"
+
+	// PublicKeyPrefix marks a recipient public key, IdentityPrefix a private identity.
+	PublicKeyPrefix = "veto-pub-"
+	IdentityPrefix  = "VETO-SECRET-KEY-"
+
+	x25519Version = 1
+	keyIDSize     = 8
+	hkdfInfo      = "veto-x25519-v1"
+
+	x25519HeaderSize = 1 + 32 + 2               // version | ephemeral pub | count
+	stanzaSize       = keyIDSize + 12 + 32 + 16 // key id | nonce | wrapped file key + tag
+)
+
+// ErrNoIdentity is returned when none of the available identities is a recipient of a value.
+var ErrNoIdentity = errors.New("no matching identity: this key is not a recipient of the value")
+
+// Identity is an X25519 private key able to decrypt values addressed to its public key.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity creates a new random X25519 identity.
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity parses a "VETO-SECRET-KEY-..." string.
+func ParseIdentity(s string) (*Identity, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, IdentityPrefix) {
+		return nil, fmt.Errorf("invalid identity: missing %s prefix", IdentityPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, IdentityPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// String returns the encoded private key. Never log it.
+func (i *Identity) String() string {
+	return IdentityPrefix + base64.RawURLEncoding.EncodeToString(i.key.Bytes())
+}
+
+// Recipient returns the public key of the identity in "veto-pub-..." form.
+func (i *Identity) Recipient() string {
+	return encodePublicKey(i.key.PublicKey())
+}
+
+// ParseRecipient parses a "veto-pub-..." public key.
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, PublicKeyPrefix) {
+		return nil, fmt.Errorf("invalid recipient '%s': missing %s prefix", s, PublicKeyPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, PublicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient '%s': %w", s, err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient '%s': %w", s, err)
+	}
+	return key, nil
+}
+
+func encodePublicKey(pub *ecdh.PublicKey) string {
+	return PublicKeyPrefix + base64.RawURLEncoding.EncodeToString(pub.Bytes())
+}
+
+// keyID is a short fingerprint so decryption can find its stanza without trying all of them.
+func keyID(pub *ecdh.PublicKey) []byte {
+	sum := sha256.Sum256(pub.Bytes())
+	return sum[:keyIDSize]
+}
+
+// EncryptX25519 encrypts plaintext for every recipient public key.
+//
+// A random file key encrypts the payload with AES-256-GCM; the file key is then
+// wrapped once per recipient with a key derived (HKDF-SHA256) from an ephemeral
+// X25519 exchange. Layout before base64:
+//
+//	version(1) | ephemeral pub(32) | count(2) | count * [key id(8) | nonce(12) | wrapped key(48)] | nonce(12) | ciphertext
+func EncryptX25519(plaintext string, recipients []string) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("at least one recipient is required")
+	}
+	if len(recipients) > 0xFFFF {
+		return "", errors.New("too many recipients")
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	fileKey := make([]byte, 32)
+	if _, err := rand.Read(fileKey); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(x25519Version)
+	buf.Write(ephemeral.PublicKey().Bytes())
+	binary.Write(&buf, binary.BigEndian, uint16(len(recipients)))
+
+	for _, r := range recipients {
+		pub, err := ParseRecipient(r)
+		if err != nil {
+			return "", err
+		}
+		wrapKey, err := deriveWrapKey(ephemeral, pub, ephemeral.PublicKey())
+		if err != nil {
+			return "", err
+		}
+		sealed, err := sealGCM(wrapKey, fileKey)
+		if err != nil {
+			return "", err
+		}
+		buf.Write(keyID(pub))
+		buf.Write(sealed)
+	}
+
+	payload, err := sealGCM(fileKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	buf.Write(payload)
+
+	return X25519Prefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + Suffix, nil
+}
+
+// DecryptX25519 decrypts a value with the first identity that is one of its recipients.
+func DecryptX25519(encrypted string, identities []*Identity) (string, error) {
+	if !strings.HasPrefix(encrypted, X25519Prefix) || !strings.HasSuffix(encrypted, Suffix) {
+		return "", errors.New("invalid encrypted format")
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted[len(X25519Prefix) : len(encrypted)-len(Suffix)])
+	if err != nil {
+		return "", fmt.Errorf("base64 decode failed: %w", err)
+	}
+
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil || version != x25519Version {
+		return "", fmt.Errorf("unsupported X25519 format version %d", version)
+	}
+	ephRaw := make([]byte, 32)
+	if _, err := io.ReadFull(r, ephRaw); err != nil {
+		return "", errors.New("ciphertext too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephRaw)
+	if err != nil {
+		return "", err
+	}
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return "", errors.New("ciphertext too short")
+	}
+
+	stanzas := make([][]byte, count)
+	for i := range stanzas {
+		stanzas[i] = make([]byte, stanzaSize)
+		if _, err := io.ReadFull(r, stanzas[i]); err != nil {
+			return "", errors.New("ciphertext too short")
+		}
+	}
+	payload, _ := io.ReadAll(r)
+
+	for _, id := range identities {
+		pub := id.key.PublicKey()
+		kid := keyID(pub)
+		for _, stanza := range stanzas {
+			if !bytes.Equal(stanza[:keyIDSize], kid) {
+				continue
+			}
+			wrapKey, err := deriveWrapKey(id.key, ephemeral, ephemeral)
+			if err != nil {
+				return "", err
+			}
+			fileKey, err := openGCM(wrapKey, stanza[keyIDSize:])
+			if err != nil {
+				continue // Key ID collision, keep looking
+			}
+			plaintext, err := openGCM(fileKey, payload)
+			if err != nil {
+				return "", fmt.Errorf("decryption failed: %w", err)
+			}
+			return string(plaintext), nil
+		}
+	}
+
+	return "", ErrNoIdentity
+}
+
+// RecipientKeyID returns the key ID (hex) of a "veto-pub-..." public key.
+func RecipientKeyID(recipient string) (string, error) {
+	pub, err := ParseRecipient(recipient)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(keyID(pub)), nil
+}
+
+// x25519KeyIDs lists the recipient key IDs (hex) stored in a value's header.
+func x25519KeyIDs(encrypted string) ([]string, error) {
+	if !strings.HasPrefix(encrypted, X25519Prefix) || !strings.HasSuffix(encrypted, Suffix) {
+		return nil, errors.New("invalid encrypted format")
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted[len(X25519Prefix) : len(encrypted)-len(Suffix)])
+	if err != nil || len(data) < x25519HeaderSize {
+		return nil, errors.New("invalid encrypted value")
+	}
+
+	count := int(binary.BigEndian.Uint16(data[x25519HeaderSize-2 : x25519HeaderSize]))
+	ids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		off := x25519HeaderSize + i*stanzaSize
+		if off+keyIDSize > len(data) {
+			return nil, errors.New("invalid encrypted value")
+		}
+		ids = append(ids, hex.EncodeToString(data[off:off+keyIDSize]))
+	}
+	return ids, nil
+}
+
+// deriveWrapKey computes HKDF(ECDH(priv, peer)) bound to the ephemeral public key.
+func deriveWrapKey(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeral *ecdh.PublicKey) ([]byte, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, shared, ephemeral.Bytes(), hkdfInfo, 32)
+}
+
+// sealGCM encrypts with AES-256-GCM and returns nonce || ciphertext.
+func sealGCM(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openGCM(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
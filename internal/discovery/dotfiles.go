@@ -0,0 +1,381 @@
+package discovery
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/melih-ucgun/veto/internal/config"
+)
+
+// DotfileImporter converts an existing dotfile manager tree (GNU Stow or chezmoi)
+// into Veto resources. Source files are copied into FilesDir (see
+// DotfileImportResult.CopyFiles) so the generated configuration no longer depends
+// on the original tree.
+type DotfileImporter struct {
+	SourceDir string // Stow directory or chezmoi source directory
+	TargetDir string // Directory the dotfiles are deployed into (usually $HOME)
+	FilesDir  string // Absolute path of the profile's files/ directory
+	HomeDir   string // Used to sanitize generated paths with ${HOME}
+
+	// StowDotfiles enables stow's --dotfiles handling ("dot-foo" -> ".foo").
+	StowDotfiles bool
+
+	// Warnings collects entries that were skipped or need manual review.
+	Warnings []string
+}
+
+// DotfileImportResult is the outcome of an import run. Nothing is written until
+// CopyFiles is called, so a cancelled import leaves the profile untouched.
+type DotfileImportResult struct {
+	Resources []config.ResourceConfig
+	Copies    []DotfileCopy // Files the resources expect in FilesDir
+}
+
+// DotfileCopy is a source file and where it is stored in the profile.
+type DotfileCopy struct {
+	Src string
+	Dst string
+}
+
+// CopyFiles copies the source files into the profile storage and returns how many
+// were copied.
+func (r *DotfileImportResult) CopyFiles() (int, error) {
+	for i, c := range r.Copies {
+		if err := copyDotfile(c.Src, c.Dst); err != nil {
+			return i, fmt.Errorf("failed to copy %s: %w", c.Src, err)
+		}
+	}
+	return len(r.Copies), nil
+}
+
+// stowIgnored mirrors the default ignore list of GNU Stow.
+var stowIgnored = []string{
+	".git", ".gitignore", ".gitmodules", ".stow-local-ignore", ".stow-global-ignore",
+	"README*", "LICENSE*", "COPYING", ".DS_Store", "*~", "#*#",
+}
+
+// ImportStow maps every package in a stow directory to symlink resources.
+// Each file in a package becomes a link from TargetDir/<rel> to the copied file.
+func (d *DotfileImporter) ImportStow() (*DotfileImportResult, error) {
+	entries, err := os.ReadDir(d.SourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stow directory: %w", err)
+	}
+
+	result := &DotfileImportResult{}
+	for _, pkg := range entries {
+		if !pkg.IsDir() || matchesAny(pkg.Name(), stowIgnored) {
+			continue
+		}
+
+		pkgDir := filepath.Join(d.SourceDir, pkg.Name())
+		err := filepath.WalkDir(pkgDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if matchesAny(entry.Name(), stowIgnored) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() {
+				return nil
+			}
+
+			rel, _ := filepath.Rel(pkgDir, path)
+			targetRel := rel
+			if d.StowDotfiles {
+				targetRel = stowDotfileName(rel)
+			}
+
+			stored := filepath.Join(d.FilesDir, pkg.Name(), rel)
+			result.Copies = append(result.Copies, DotfileCopy{Src: path, Dst: stored})
+
+			result.Resources = append(result.Resources, config.ResourceConfig{
+				Type:  "symlink",
+				Name:  fmt.Sprintf("%s/%s", pkg.Name(), filepath.ToSlash(targetRel)),
+				State: "present",
+				Params: map[string]interface{}{
+					"path":   d.sanitize(filepath.Join(d.TargetDir, targetRel)),
+					"target": d.sanitize(stored),
+					"force":  true,
+				},
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to import stow package '%s': %w", pkg.Name(), err)
+		}
+	}
+
+	sortResources(result.Resources)
+	return result, nil
+}
+
+// chezmoiAttrs holds the attributes decoded from a chezmoi source file name.
+type chezmoiAttrs struct {
+	name       string
+	private    bool
+	executable bool
+	readonly   bool
+	template   bool
+	symlink    bool
+	skipReason string
+}
+
+// ImportChezmoi maps a chezmoi source directory to file/template/symlink resources.
+func (d *DotfileImporter) ImportChezmoi() (*DotfileImportResult, error) {
+	result := &DotfileImportResult{}
+
+	err := filepath.WalkDir(d.SourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == d.SourceDir {
+			return nil
+		}
+
+		// chezmoi's own metadata (.chezmoiignore, .chezmoidata, .chezmoiscripts...) and VCS dirs
+		if strings.HasPrefix(entry.Name(), ".chezmoi") || entry.Name() == ".git" {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(d.SourceDir, path)
+		targetRel, err := chezmoiTargetDir(filepath.Dir(rel))
+		if err != nil {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("%s: %v", rel, err))
+			return nil
+		}
+
+		attrs := parseChezmoiName(entry.Name())
+		if attrs.skipReason != "" {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("%s: skipped (%s)", rel, attrs.skipReason))
+			return nil
+		}
+		targetPath := d.sanitize(filepath.Join(d.TargetDir, targetRel, attrs.name))
+		resName := filepath.ToSlash(filepath.Join(targetRel, attrs.name))
+
+		if attrs.symlink {
+			// The file content is the link target
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			linkTarget := strings.TrimSpace(string(data))
+			if attrs.template {
+				d.Warnings = append(d.Warnings, fmt.Sprintf("%s: templated symlink target '%s' needs manual review", rel, linkTarget))
+			}
+			result.Resources = append(result.Resources, config.ResourceConfig{
+				Type:  "symlink",
+				Name:  resName,
+				State: "present",
+				Params: map[string]interface{}{
+					"path":   targetPath,
+					"target": linkTarget,
+				},
+			})
+			return nil
+		}
+
+		stored := filepath.Join(d.FilesDir, targetRel, attrs.name)
+		if attrs.template {
+			stored += ".tmpl"
+		}
+		result.Copies = append(result.Copies, DotfileCopy{Src: path, Dst: stored})
+
+		mode := chezmoiMode(attrs)
+		if attrs.template {
+			d.Warnings = append(d.Warnings, fmt.Sprintf("%s: chezmoi template variables (.chezmoi.*) must be rewritten to 'vars'", rel))
+			result.Resources = append(result.Resources, config.ResourceConfig{
+				Type:  "template",
+				Name:  resName,
+				State: "present",
+				Params: map[string]interface{}{
+					"src":  d.sanitize(stored),
+					"dest": targetPath,
+					"mode": mode,
+				},
+			})
+			return nil
+		}
+
+		result.Resources = append(result.Resources, config.ResourceConfig{
+			Type:  "file",
+			Name:  resName,
+			State: "present",
+			Params: map[string]interface{}{
+				"path":   targetPath,
+				"source": d.sanitize(stored),
+				"mode":   mode,
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import chezmoi source: %w", err)
+	}
+
+	sortResources(result.Resources)
+	return result, nil
+}
+
+// parseChezmoiName decodes chezmoi source state prefixes and suffixes.
+// See https://www.chezmoi.io/reference/source-state-attributes/
+func parseChezmoiName(name string) chezmoiAttrs {
+	attrs := chezmoiAttrs{}
+
+	unsupported := map[string]string{
+		"run_":       "scripts are not supported, use a shell resource",
+		"modify_":    "modify scripts are not supported",
+		"remove_":    "remove entries are not supported, use state: absent",
+		"create_":    "create-only files are not supported",
+		"encrypted_": "encrypted files must be decrypted with chezmoi first",
+	}
+	for prefix, reason := range unsupported {
+		if strings.HasPrefix(name, prefix) {
+			attrs.skipReason = reason
+			return attrs
+		}
+	}
+
+	if strings.HasSuffix(name, ".tmpl") {
+		attrs.template = true
+		name = strings.TrimSuffix(name, ".tmpl")
+	}
+	name = strings.TrimSuffix(name, ".literal")
+
+	if strings.HasPrefix(name, "symlink_") {
+		attrs.symlink = true
+		name = strings.TrimPrefix(name, "symlink_")
+	}
+
+	// Prefix order is fixed by chezmoi: private_, readonly_, empty_, executable_, dot_
+	for {
+		switch {
+		case strings.HasPrefix(name, "private_"):
+			attrs.private = true
+			name = strings.TrimPrefix(name, "private_")
+		case strings.HasPrefix(name, "readonly_"):
+			attrs.readonly = true
+			name = strings.TrimPrefix(name, "readonly_")
+		case strings.HasPrefix(name, "empty_"):
+			name = strings.TrimPrefix(name, "empty_")
+		case strings.HasPrefix(name, "executable_"):
+			attrs.executable = true
+			name = strings.TrimPrefix(name, "executable_")
+		case strings.HasPrefix(name, "literal_"):
+			attrs.name = strings.TrimPrefix(name, "literal_")
+			return attrs
+		default:
+			if strings.HasPrefix(name, "dot_") {
+				name = "." + strings.TrimPrefix(name, "dot_")
+			}
+			attrs.name = name
+			return attrs
+		}
+	}
+}
+
+// chezmoiTargetDir converts a source directory path (e.g. "private_dot_ssh")
+// into its target form (".ssh").
+func chezmoiTargetDir(dir string) (string, error) {
+	if dir == "." {
+		return "", nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(dir), "/") {
+		if strings.HasPrefix(part, "external_") || strings.HasPrefix(part, "remove_") {
+			return "", fmt.Errorf("directory attribute of '%s' is not supported", part)
+		}
+		for _, prefix := range []string{"exact_", "private_", "readonly_"} {
+			if strings.HasPrefix(part, prefix) {
+				part = strings.TrimPrefix(part, prefix)
+			}
+		}
+		if strings.HasPrefix(part, "literal_") {
+			part = strings.TrimPrefix(part, "literal_")
+		} else if strings.HasPrefix(part, "dot_") {
+			part = "." + strings.TrimPrefix(part, "dot_")
+		}
+		parts = append(parts, part)
+	}
+	return filepath.Join(parts...), nil
+}
+
+// chezmoiMode derives the file mode from private_/executable_/readonly_ attributes.
+func chezmoiMode(attrs chezmoiAttrs) int {
+	mode := 0644
+	if attrs.executable {
+		mode = 0755
+	}
+	if attrs.private {
+		mode &^= 0077
+	}
+	if attrs.readonly {
+		mode &^= 0222
+	}
+	return mode
+}
+
+// stowDotfileName applies stow's --dotfiles renaming to every path component.
+func stowDotfileName(rel string) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, "dot-") {
+			parts[i] = "." + strings.TrimPrefix(p, "dot-")
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// sanitize replaces the home directory prefix with ${HOME} so the generated
+// configuration stays portable between users (same convention as 'veto add').
+func (d *DotfileImporter) sanitize(path string) string {
+	if d.HomeDir != "" && strings.HasPrefix(path, d.HomeDir+string(filepath.Separator)) {
+		return strings.Replace(path, d.HomeDir, "${HOME}", 1)
+	}
+	return path
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copyDotfile copies a single file into the profile storage, preserving its mode.
+func copyDotfile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
+func sortResources(resources []config.ResourceConfig) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+}
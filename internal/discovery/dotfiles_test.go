@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func findResource(resources []config.ResourceConfig, name string) *config.ResourceConfig {
+	for i := range resources {
+		if resources[i].Name == name {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+func TestImportStow(t *testing.T) {
+	tmp := t.TempDir()
+	stowDir := filepath.Join(tmp, "dotfiles")
+	writeFile(t, filepath.Join(stowDir, "zsh", "dot-zshrc"), "export EDITOR=vim")
+	writeFile(t, filepath.Join(stowDir, "nvim", ".config", "nvim", "init.lua"), "vim.o.number = true")
+	writeFile(t, filepath.Join(stowDir, "nvim", "README.md"), "ignored")
+
+	filesDir := filepath.Join(tmp, "profile", "files")
+	importer := &DotfileImporter{
+		SourceDir:    stowDir,
+		TargetDir:    "/home/test",
+		FilesDir:     filesDir,
+		StowDotfiles: true,
+	}
+
+	result, err := importer.ImportStow()
+	if err != nil {
+		t.Fatalf("ImportStow failed: %v", err)
+	}
+	if len(result.Resources) != 2 {
+		t.Fatalf("Expected 2 resources, got %d", len(result.Resources))
+	}
+
+	zsh := findResource(result.Resources, "zsh/.zshrc")
+	if zsh == nil {
+		t.Fatal("zsh/.zshrc resource not generated")
+	}
+	if zsh.Type != "symlink" {
+		t.Errorf("Expected symlink, got %s", zsh.Type)
+	}
+	if zsh.Params["path"] != "/home/test/.zshrc" {
+		t.Errorf("Unexpected link path: %v", zsh.Params["path"])
+	}
+	stored := filepath.Join(filesDir, "zsh", "dot-zshrc")
+	if zsh.Params["target"] != stored {
+		t.Errorf("Unexpected link target: %v", zsh.Params["target"])
+	}
+	// Files are only copied once the import is confirmed
+	if _, err := os.Stat(stored); !os.IsNotExist(err) {
+		t.Errorf("File copied before CopyFiles: %v", err)
+	}
+	if n, err := result.CopyFiles(); err != nil || n != 2 {
+		t.Fatalf("CopyFiles copied %d files: %v", n, err)
+	}
+	if _, err := os.Stat(stored); err != nil {
+		t.Errorf("File was not copied into files dir: %v", err)
+	}
+}
+
+func TestImportChezmoi(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "chezmoi")
+	writeFile(t, filepath.Join(src, "dot_gitconfig"), "[user]")
+	writeFile(t, filepath.Join(src, "private_dot_ssh", "private_config"), "Host *")
+	writeFile(t, filepath.Join(src, "dot_local", "bin", "executable_hello"), "#!/bin/sh")
+	writeFile(t, filepath.Join(src, "dot_bashrc.tmpl"), "{{ .chezmoi.hostname }}")
+	writeFile(t, filepath.Join(src, "symlink_dot_vimrc"), "/etc/vimrc\n")
+	writeFile(t, filepath.Join(src, "run_once_install.sh"), "echo hi")
+	writeFile(t, filepath.Join(src, ".chezmoiignore"), "README.md")
+
+	importer := &DotfileImporter{
+		SourceDir: src,
+		TargetDir: "/home/test",
+		FilesDir:  filepath.Join(tmp, "files"),
+	}
+
+	result, err := importer.ImportChezmoi()
+	if err != nil {
+		t.Fatalf("ImportChezmoi failed: %v", err)
+	}
+	if len(result.Resources) != 5 {
+		t.Fatalf("Expected 5 resources, got %d: %+v", len(result.Resources), result.Resources)
+	}
+
+	tests := []struct {
+		name    string
+		resType string
+		path    string
+		mode    int
+	}{
+		{".gitconfig", "file", "/home/test/.gitconfig", 0644},
+		{".ssh/config", "file", "/home/test/.ssh/config", 0600},
+		{".local/bin/hello", "file", "/home/test/.local/bin/hello", 0755},
+		{".bashrc", "template", "/home/test/.bashrc", 0644},
+	}
+
+	for _, tt := range tests {
+		res := findResource(result.Resources, tt.name)
+		if res == nil {
+			t.Errorf("Resource %s not generated", tt.name)
+			continue
+		}
+		if res.Type != tt.resType {
+			t.Errorf("%s: expected type %s, got %s", tt.name, tt.resType, res.Type)
+		}
+		pathKey := "path"
+		if tt.resType == "template" {
+			pathKey = "dest"
+		}
+		if res.Params[pathKey] != tt.path {
+			t.Errorf("%s: expected path %s, got %v", tt.name, tt.path, res.Params[pathKey])
+		}
+		if res.Params["mode"] != tt.mode {
+			t.Errorf("%s: expected mode %o, got %v", tt.name, tt.mode, res.Params["mode"])
+		}
+	}
+
+	link := findResource(result.Resources, ".vimrc")
+	if link == nil || link.Type != "symlink" || link.Params["target"] != "/etc/vimrc" {
+		t.Errorf("Unexpected symlink resource: %+v", link)
+	}
+
+	// run_ script is skipped and reported, template needs review
+	if len(importer.Warnings) != 2 {
+		t.Errorf("Expected 2 warnings, got %d: %v", len(importer.Warnings), importer.Warnings)
+	}
+}
+
+func TestParseChezmoiName(t *testing.T) {
+	attrs := parseChezmoiName("private_readonly_executable_dot_script.tmpl")
+	if attrs.name != ".script" || !attrs.private || !attrs.readonly || !attrs.executable || !attrs.template {
+		t.Errorf("Unexpected attributes: %+v", attrs)
+	}
+	if mode := chezmoiMode(attrs); mode != 0500 {
+		t.Errorf("Expected mode 0500, got %o", mode)
+	}
+
+	if literal := parseChezmoiName("literal_dot_keep"); literal.name != "dot_keep" {
+		t.Errorf("literal_ prefix not honoured: %+v", literal)
+	}
+}
@@ -0,0 +1,50 @@
+package fleet
+
+import (
+	"context"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/inventory"
+	"github.com/melih-ucgun/veto/internal/transport"
+)
+
+// Connect opens a transport to the given inventory host.
+// Local hosts get a LocalTransport, everything else is reached over SSH
+// using the become settings from the host vars.
+func Connect(h inventory.Host) (core.Transport, error) {
+	if h.Connection == "local" {
+		return transport.NewLocalTransport(), nil
+	}
+
+	port := h.Port
+	if port == 0 {
+		port = 22
+	}
+
+	sshConfig := transport.HostConfig{
+		Name:           h.Name,
+		Address:        h.Address,
+		User:           h.User,
+		Port:           port,
+		SSHKeyPath:     h.KeyPath,
+		BecomeMethod:   h.Vars["ansible_become_method"],
+		BecomePassword: h.Vars["ansible_become_password"],
+	}
+
+	// Set timeout for connection
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	return transport.NewSSHTransport(ctx, sshConfig)
+}
+
+// FindHost returns the inventory host with the given name.
+func FindHost(inv *inventory.Inventory, name string) (inventory.Host, bool) {
+	for _, h := range inv.Hosts {
+		if h.Name == name {
+			return h, true
+		}
+	}
+	return inventory.Host{}, false
+}
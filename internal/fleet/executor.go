@@ -9,6 +9,7 @@ import (
 
 	"github.com/melih-ucgun/veto/internal/core"
 	"github.com/melih-ucgun/veto/internal/inventory"
+	"github.com/melih-ucgun/veto/internal/secrets"
 	"github.com/melih-ucgun/veto/internal/transport"
 	"github.com/pterm/pterm"
 )
@@ -109,12 +110,12 @@ func (e *Executor) runOnHost(h inventory.Host, cmd string) error {
 	// Transport.Execute returns stdout/stderr combined
 	output, err := tr.Execute(ctx, cmd)
 	if err != nil {
-		pterm.Error.Printf("[%s] Failed: %v\n", h.Name, err)
+		pterm.Error.Printf("[%s] Failed: %s\n", h.Name, secrets.Redact(err.Error()))
 		if output != "" {
 			// Print output even on failure if any
 			lines := strings.Split(strings.TrimSpace(output), "\n")
 			for _, line := range lines {
-				pterm.Printf("[%s] %s\n", h.Name, line)
+				pterm.Printf("[%s] %s\n", h.Name, secrets.Redact(line))
 			}
 		}
 		return err
@@ -124,7 +125,7 @@ func (e *Executor) runOnHost(h inventory.Host, cmd string) error {
 	if output != "" {
 		lines := strings.Split(strings.TrimSpace(output), "\n")
 		for _, line := range lines {
-			pterm.Printf("[%s] %s\n", h.Name, line)
+			pterm.Printf("[%s] %s\n", h.Name, secrets.Redact(line))
 		}
 	}
 
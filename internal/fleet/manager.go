@@ -7,9 +7,10 @@ import (
 	"time"
 
 	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/crypto"
 	"github.com/melih-ucgun/veto/internal/inventory"
+	"github.com/melih-ucgun/veto/internal/state"
 	"github.com/melih-ucgun/veto/internal/system"
-	"github.com/melih-ucgun/veto/internal/transport"
 	"github.com/pterm/pterm"
 )
 
@@ -20,6 +21,11 @@ type FleetManager struct {
 	DryRun   bool
 	Prune    bool
 	Logger   core.Logger
+	Facts    *system.FactCache // Optional, nil always runs a full detection
+	Keyring  *crypto.Keyring   // Optional, decrypts encrypted file sources
+
+	State       *state.BackendConfig // 'state' section of the config, nil is the local backend
+	LockTimeout time.Duration        // How long to wait for a host's state lock
 }
 
 // NewFleetManager creates a new FleetManager.
@@ -53,37 +59,11 @@ func (f *FleetManager) ApplyConfig(layers [][]core.ConfigItem, concurrency int,
 			hostLogger.Info("Connecting to host")
 
 			// 1. Initialize Transport
-			var trans core.Transport
-			var err error
-
-			if h.Connection == "local" {
-				trans = transport.NewLocalTransport()
-			} else {
-				// Determine port
-				port := h.Port
-				if port == 0 {
-					port = 22
-				}
-
-				sshConfig := transport.HostConfig{
-					Name:           h.Name,
-					Address:        h.Address,
-					User:           h.User,
-					Port:           port,
-					SSHKeyPath:     h.KeyPath,
-					BecomeMethod:   h.Vars["ansible_become_method"],
-					BecomePassword: h.Vars["ansible_become_password"],
-				}
-				// Set timeout for connection
-				ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-				defer cancel()
-
-				trans, err = transport.NewSSHTransport(ctx, sshConfig)
-				if err != nil {
-					hostLogger.Error("Connection Failed: %v", err)
-					errChan <- fmt.Errorf("[%s] connection failed: %w", h.Name, err)
-					return
-				}
+			trans, err := Connect(h)
+			if err != nil {
+				hostLogger.Error("Connection Failed: %v", err)
+				errChan <- fmt.Errorf("[%s] connection failed: %w", h.Name, err)
+				return
 			}
 			defer trans.Close()
 
@@ -96,24 +76,52 @@ func (f *FleetManager) ApplyConfig(layers [][]core.ConfigItem, concurrency int,
 				TargetUser: h.User,
 				Logger:     hostLogger,
 			}
+			if f.Keyring != nil {
+				sysCtx.Keyring = f.Keyring
+			}
+			// Backups of the host's files are read over its transport and kept here
+			sysCtx.BackupManager = state.NewHostBackupManager(h.Name, sysCtx.FS)
 
 			// 3. Detect System
-			system.Detect(sysCtx)
+			f.Facts.Detect(sysCtx, h.Name)
 			hostLogger.Info("System detected: %s %s", sysCtx.Distro, sysCtx.Version)
 
-			// 4. Create Engine
-			engine := core.NewEngine(sysCtx, nil) // State updater per host TODO
+			// 4. Open the host's own state and transaction log
+			stateMgr, err := f.openState(h, trans)
+			if err != nil {
+				hostLogger.Error(fmt.Sprintf("State Failed: %v", err))
+				errChan <- fmt.Errorf("[%s] state: %w", h.Name, err)
+				return
+			}
+			if !f.DryRun {
+				lock, err := stateMgr.Lock("veto apply (fleet)", f.LockTimeout)
+				if err != nil {
+					hostLogger.Error(fmt.Sprintf("State Lock Failed: %v", err))
+					errChan <- fmt.Errorf("[%s] %w", h.Name, err)
+					return
+				}
+				defer func() {
+					if err := lock.Unlock(); err != nil {
+						hostLogger.Warn(fmt.Sprintf("Failed to release state lock: %v", err))
+					}
+				}()
+			}
+
+			// 5. Create Engine
+			engine := core.NewEngine(sysCtx, stateMgr)
 
-			// 5. Execute Layers
+			// 6. Execute Layers
+			hostSecrets := newHostSecrets(sysCtx)
 			for i, layer := range layers {
-				// Deep copy layer params for this host
+				// Deep copy layer params for this host, decrypting its X25519 secrets
 				hostLayer := make([]core.ConfigItem, len(layer))
 				for j, item := range layer {
 					newItem := item
-					// Copy Params map
-					newItem.Params = make(map[string]interface{})
-					for k, v := range item.Params {
-						newItem.Params[k] = v
+					newItem.Params, err = hostSecrets.params(item.Params)
+					if err != nil {
+						hostLogger.Error(fmt.Sprintf("Secret Failed: %v", err))
+						errChan <- fmt.Errorf("[%s] %s: %w", h.Name, item.Name, err)
+						return
 					}
 					hostLayer[j] = newItem
 				}
@@ -156,3 +164,9 @@ func (f *FleetManager) ApplyConfig(layers [][]core.ConfigItem, concurrency int,
 	pterm.Success.Println("Fleet execution completed successfully.")
 	return nil
 }
+
+// openState opens the state of host h. The remote backend keeps it on the host itself,
+// reached over trans; the others keep it centrally, keyed by the inventory name.
+func (f *FleetManager) openState(h inventory.Host, trans core.Transport) (*state.Manager, error) {
+	return state.OpenHost(f.State, state.Target{Name: h.Name, FS: trans.GetFileSystem()})
+}
@@ -0,0 +1,72 @@
+package fleet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/crypto"
+	"github.com/melih-ucgun/veto/internal/secrets"
+)
+
+// hostSecrets decrypts ENC[X25519:...] values on a host with the host's own
+// identity, so the controller never needs it.
+type hostSecrets struct {
+	ctx   *core.SystemContext
+	cache map[string]string
+}
+
+func newHostSecrets(ctx *core.SystemContext) *hostSecrets {
+	return &hostSecrets{ctx: ctx, cache: make(map[string]string)}
+}
+
+// params returns a copy of params with the host's secrets decrypted. Nested maps
+// and slices are copied too, since the originals are shared by all hosts.
+func (s *hostSecrets) params(params map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		dv, err := s.value(v)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = dv
+	}
+	return out, nil
+}
+
+func (s *hostSecrets) value(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		if !strings.HasPrefix(val, crypto.X25519Prefix) || !crypto.IsEncrypted(val) {
+			return val, nil
+		}
+		return s.decrypt(val)
+	case map[string]interface{}:
+		return s.params(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			dv, err := s.value(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = dv
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func (s *hostSecrets) decrypt(encrypted string) (string, error) {
+	if plain, ok := s.cache[encrypted]; ok {
+		return plain, nil
+	}
+	quoted := "'" + strings.ReplaceAll(encrypted, "'", `'\''`) + "'"
+	plain, err := s.ctx.Transport.Execute(s.ctx.Context, "veto secret decrypt --raw "+quoted)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt a secret: %w", err)
+	}
+	secrets.Taint(plain)
+	s.cache[encrypted] = plain
+	return plain, nil
+}
@@ -0,0 +1,36 @@
+package fleet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostSecretsParams(t *testing.T) {
+	mock := transport.NewMockTransport()
+	mock.AddResponse("veto secret decrypt --raw 'ENC[X25519:abc]'", "hunter22")
+	s := newHostSecrets(&core.SystemContext{Context: context.Background(), Transport: mock})
+
+	params := map[string]interface{}{
+		"content": "ENC[X25519:abc]",
+		"mode":    "0600",
+		"env":     map[string]interface{}{"TOKEN": "ENC[X25519:abc]"},
+		"args":    []interface{}{"ENC[X25519:abc]"},
+	}
+	got, err := s.params(params)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter22", got["content"])
+	assert.Equal(t, "0600", got["mode"])
+	assert.Equal(t, "hunter22", got["env"].(map[string]interface{})["TOKEN"])
+	assert.Equal(t, "hunter22", got["args"].([]interface{})[0])
+
+	// The shared params stay encrypted for the other hosts
+	assert.Equal(t, "ENC[X25519:abc]", params["env"].(map[string]interface{})["TOKEN"])
+
+	// A host that is not a recipient fails
+	_, err = s.params(map[string]interface{}{"content": "ENC[X25519:other]"})
+	assert.Error(t, err)
+}
@@ -100,6 +100,9 @@ func TestCreateResource_ContextAware(t *testing.T) {
 		{"fedora", "*pkg.DnfAdapter"},
 		{"alpine", "*pkg.ApkAdapter"},
 		{"opensuse", "*pkg.ZypperAdapter"},
+		{"garuda", "*pkg.PacmanAdapter"},
+		{"zorin", "*pkg.AptAdapter"},
+		{"rocky", "*pkg.DnfAdapter"},
 		// Unknown falls back to error
 		{"unknown-os", ""},
 	}
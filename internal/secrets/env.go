@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+func init() {
+	RegisterProvider("env", envProvider{})
+}
+
+// envProvider reads environment variables of the veto process: "env:VPN_PASSWORD".
+type envProvider struct{}
+
+func (envProvider) Lookup(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
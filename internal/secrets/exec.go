@@ -0,0 +1,36 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// HelperPrefix is prepended to helper names that are not paths: "exec:bw:github"
+// runs veto-secret-bw from PATH.
+const HelperPrefix = "veto-secret-"
+
+func init() {
+	RegisterProvider("exec", execProvider{})
+}
+
+// execProvider implements the helper protocol. "exec:<helper>:<key>" runs
+// `<helper> get <key>`; the helper prints the secret on stdout and exits 0, or prints an
+// error on stderr and exits non-zero. A single trailing newline is removed.
+type execProvider struct{}
+
+func (execProvider) Lookup(ctx context.Context, path string) (string, error) {
+	helper, key, ok := strings.Cut(path, ":")
+	if !ok || helper == "" || key == "" {
+		return "", fmt.Errorf("expected exec:<helper>:<key>")
+	}
+	if !strings.Contains(helper, "/") {
+		helper = HelperPrefix + helper
+	}
+
+	out, err := runCommand(ctx, helper, "get", key)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(out, "\n"), "\r"), nil
+}
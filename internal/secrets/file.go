@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	RegisterProvider("file", &fileProvider{contents: make(map[string][]byte)})
+}
+
+// fileProvider reads local secret files, decrypting them first by extension:
+// .age with `age --decrypt` (identity from VETO_AGE_IDENTITY, default
+// ~/.config/age/keys.txt) and .gpg/.asc with `gpg --decrypt`.
+//
+// "file:secrets.yaml.gpg#db.password" selects a (dotted) key of a YAML/JSON or dotenv
+// file; without "#key" the whole content is returned, minus trailing newlines.
+type fileProvider struct {
+	mu       sync.Mutex
+	contents map[string][]byte // Decrypted files, so each is decrypted once
+}
+
+func (p *fileProvider) Lookup(ctx context.Context, path string) (string, error) {
+	name, field := splitField(path)
+	data, err := p.read(ctx, expandHome(os.ExpandEnv(name)))
+	if err != nil {
+		return "", err
+	}
+	if field == "" {
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return fieldValue(data, field)
+}
+
+func (p *fileProvider) read(ctx context.Context, name string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if data, ok := p.contents[name]; ok {
+		return data, nil
+	}
+
+	var data []byte
+	switch filepath.Ext(name) {
+	case ".age":
+		out, err := runCommand(ctx, "age", "--decrypt", "-i", ageIdentity(), name)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(out)
+	case ".gpg", ".asc":
+		out, err := runCommand(ctx, "gpg", "--batch", "--quiet", "--decrypt", name)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(out)
+	default:
+		var err error
+		if data, err = os.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	p.contents[name] = data
+	return data, nil
+}
+
+// fieldValue looks up a key in YAML/JSON content, falling back to dotenv format.
+// Nested YAML keys are addressed with dots: "db.password".
+func fieldValue(data []byte, field string) (string, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err == nil && doc != nil {
+		if v, ok := doc[field]; ok {
+			return fmt.Sprint(v), nil
+		}
+		var cur interface{} = doc
+		for _, part := range strings.Split(field, ".") {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				cur = nil
+				break
+			}
+			cur = m[part]
+		}
+		if cur != nil {
+			return fmt.Sprint(cur), nil
+		}
+	}
+
+	if env, err := godotenv.Unmarshal(string(data)); err == nil {
+		if v, ok := env[field]; ok {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("key '%s' not found", field)
+}
+
+func ageIdentity() string {
+	if id := os.Getenv("VETO_AGE_IDENTITY"); id != "" {
+		return id
+	}
+	return expandHome("~/.config/age/keys.txt")
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
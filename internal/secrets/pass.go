@@ -0,0 +1,34 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	RegisterProvider("pass", passProvider{})
+}
+
+// passProvider reads from password-store. "pass:work/vpn" returns the first line of the
+// entry (the password), "pass:work/vpn#username" the value of a "username: ..." line.
+type passProvider struct{}
+
+func (passProvider) Lookup(ctx context.Context, path string) (string, error) {
+	name, field := splitField(path)
+	out, err := runCommand(ctx, "pass", "show", name)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if field == "" {
+		return lines[0], nil
+	}
+	for _, line := range lines[1:] {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == field {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("field '%s' not found in pass entry '%s'", field, name)
+}
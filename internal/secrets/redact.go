@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values in output.
+const Mask = "[REDACTED]"
+
+// minTaintLength keeps trivially short values (flags, "1", "on") from masking
+// unrelated output.
+const minTaintLength = 4
+
+var (
+	taintMu  sync.RWMutex
+	tainted  = make(map[string]struct{})
+	replacer *strings.Replacer // Rebuilt lazily after Taint
+	reveal   bool
+)
+
+// Taint marks a plaintext secret (a decrypted ENC[...] value or a looked-up secret)
+// so it is masked by Redact and Scrub. Each line of a multi-line secret is tainted
+// too, since diffs show them separately.
+func Taint(value string) {
+	taintMu.Lock()
+	defer taintMu.Unlock()
+
+	add := func(v string) {
+		v = strings.TrimSpace(v)
+		if len(v) >= minTaintLength {
+			if _, ok := tainted[v]; !ok {
+				tainted[v] = struct{}{}
+				replacer = nil
+			}
+		}
+	}
+	add(value)
+	if strings.Contains(value, "\n") {
+		for _, line := range strings.Split(value, "\n") {
+			add(line)
+		}
+	}
+}
+
+// SetReveal disables masking in Redact (--show-secrets). Scrub is not affected.
+func SetReveal(enabled bool) {
+	taintMu.Lock()
+	defer taintMu.Unlock()
+	reveal = enabled
+}
+
+// Redact masks tainted values in text shown to the user: logs, diffs, plan output.
+// It returns s unchanged when --show-secrets is set.
+func Redact(s string) string {
+	taintMu.RLock()
+	r := reveal
+	taintMu.RUnlock()
+	if r {
+		return s
+	}
+	return Scrub(s)
+}
+
+// Scrub masks tainted values regardless of --show-secrets. Used for anything
+// persisted, like the state history.
+func Scrub(s string) string {
+	if s == "" {
+		return s
+	}
+
+	taintMu.Lock()
+	defer taintMu.Unlock()
+	if len(tainted) == 0 {
+		return s
+	}
+	if replacer == nil {
+		// Longest first, so a secret containing another one is masked as a whole
+		values := make([]string, 0, len(tainted))
+		for v := range tainted {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+		pairs := make([]string, 0, 2*len(values))
+		for _, v := range values {
+			pairs = append(pairs, v, Mask)
+		}
+		replacer = strings.NewReplacer(pairs...)
+	}
+	return replacer.Replace(s)
+}
@@ -0,0 +1,30 @@
+package secrets
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	Taint("hunter2-password")
+	Taint("-----BEGIN KEY-----\nMIIEvQIBADANBg\n-----END KEY-----")
+	Taint("on") // Too short to be masked
+
+	tests := map[string]string{
+		"password=hunter2-password":        "password=" + Mask,
+		"+MIIEvQIBADANBg":                  "+" + Mask,
+		"feature: on":                      "feature: on",
+		"pw: hunter2-password-hunter2-pwd": "pw: " + Mask + "-hunter2-pwd",
+	}
+	for in, want := range tests {
+		if got := Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	SetReveal(true)
+	defer SetReveal(false)
+	if got := Redact("hunter2-password"); got != "hunter2-password" {
+		t.Errorf("Expected plaintext with reveal, got %q", got)
+	}
+	if got := Scrub("hunter2-password"); got != Mask {
+		t.Errorf("Scrub must mask even with reveal, got %q", got)
+	}
+}
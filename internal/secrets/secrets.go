@@ -0,0 +1,127 @@
+// Package secrets resolves secret references such as "pass:work/vpn" or
+// "vault:secret/data/app#password" through pluggable providers. References are
+// resolved lazily, when a template or condition asks for them, and cached per resolver.
+//
+// Every resolved value, and every decrypted ENC[...] value, is tainted: Redact and
+// Scrub mask it in logs, diffs, plan output and the state history.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a single lookup (helper process or HTTP request).
+const DefaultTimeout = 30 * time.Second
+
+// Provider looks up a secret by the part of the reference after "<scheme>:".
+type Provider interface {
+	Lookup(ctx context.Context, path string) (string, error)
+}
+
+var (
+	providerRegistry = make(map[string]Provider)
+	registryMu       sync.RWMutex
+)
+
+// RegisterProvider registers a provider for a reference scheme ("pass", "env"...).
+func RegisterProvider(scheme string, p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providerRegistry[scheme] = p
+}
+
+// Schemes returns the registered reference schemes, sorted.
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var schemes []string
+	for s := range providerRegistry {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Resolver resolves references through the registered providers and caches results,
+// so a secret used by many resources is only fetched once.
+type Resolver struct {
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// Default is the resolver used when a context does not carry its own.
+var Default = NewResolver()
+
+func NewResolver() *Resolver {
+	return &Resolver{cache: make(map[string]string)}
+}
+
+// Lookup resolves a "<scheme>:<path>" reference.
+func (r *Resolver) Lookup(ctx context.Context, ref string) (string, error) {
+	r.mu.Lock()
+	if v, ok := r.cache[ref]; ok {
+		r.mu.Unlock()
+		return v, nil
+	}
+	r.mu.Unlock()
+
+	scheme, path, ok := strings.Cut(ref, ":")
+	if !ok || path == "" {
+		return "", fmt.Errorf("invalid secret reference '%s' (expected <provider>:<path>)", ref)
+	}
+
+	registryMu.RLock()
+	p, ok := providerRegistry[scheme]
+	registryMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider '%s' (available: %s)", scheme, strings.Join(Schemes(), ", "))
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	value, err := p.Lookup(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("secret '%s': %w", ref, err)
+	}
+
+	Taint(value)
+	r.mu.Lock()
+	r.cache[ref] = value
+	r.mu.Unlock()
+	return value, nil
+}
+
+// splitField separates an optional "#field" suffix from a path.
+func splitField(path string) (string, string) {
+	if i := strings.LastIndex(path, "#"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// runCommand runs a local command and returns its stdout. Secrets are always resolved
+// on the machine running veto, never on the target host.
+var runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,144 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type countingProvider struct{ calls int }
+
+func (p *countingProvider) Lookup(ctx context.Context, path string) (string, error) {
+	p.calls++
+	return "v-" + path, nil
+}
+
+func TestResolverCachesAndDispatches(t *testing.T) {
+	p := &countingProvider{}
+	RegisterProvider("test", p)
+	r := NewResolver()
+
+	for i := 0; i < 3; i++ {
+		if v, err := r.Lookup(context.Background(), "test:a/b"); err != nil || v != "v-a/b" {
+			t.Fatalf("Lookup: %q, %v", v, err)
+		}
+	}
+	if p.calls != 1 {
+		t.Errorf("Expected provider to be called once, got %d", p.calls)
+	}
+
+	if _, err := r.Lookup(context.Background(), "unknown:x"); err == nil || !strings.Contains(err.Error(), "unknown secret provider") {
+		t.Errorf("Expected unknown provider error, got %v", err)
+	}
+	if _, err := r.Lookup(context.Background(), "no-scheme"); err == nil {
+		t.Error("Expected error for reference without provider")
+	}
+}
+
+func TestPassProvider(t *testing.T) {
+	orig := runCommand
+	defer func() { runCommand = orig }()
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		if name != "pass" || strings.Join(args, " ") != "show work/vpn" {
+			t.Errorf("Unexpected command %s %v", name, args)
+		}
+		return "hunter2\nusername: melih\nurl: vpn.example.com\n", nil
+	}
+
+	p := passProvider{}
+	if v, _ := p.Lookup(context.Background(), "work/vpn"); v != "hunter2" {
+		t.Errorf("Expected password line, got %q", v)
+	}
+	if v, _ := p.Lookup(context.Background(), "work/vpn#username"); v != "melih" {
+		t.Errorf("Expected username field, got %q", v)
+	}
+	if _, err := p.Lookup(context.Background(), "work/vpn#otp"); err == nil {
+		t.Error("Expected error for missing field")
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "token"), []byte("abc123\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "secrets.yaml"), []byte("db:\n  password: pw\napi_key: k\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "app.env"), []byte("API_TOKEN=xyz\n"), 0600)
+
+	p := &fileProvider{contents: make(map[string][]byte)}
+	tests := map[string]string{
+		filepath.Join(dir, "token"):                         "abc123",
+		filepath.Join(dir, "secrets.yaml") + "#db.password": "pw",
+		filepath.Join(dir, "secrets.yaml") + "#api_key":     "k",
+		filepath.Join(dir, "app.env") + "#API_TOKEN":        "xyz",
+	}
+	for ref, want := range tests {
+		if got, err := p.Lookup(context.Background(), ref); err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", ref, got, err, want)
+		}
+	}
+	if _, err := p.Lookup(context.Background(), filepath.Join(dir, "secrets.yaml")+"#db.user"); err == nil {
+		t.Error("Expected error for missing key")
+	}
+}
+
+func TestExecProvider(t *testing.T) {
+	helper := filepath.Join(t.TempDir(), "helper")
+	script := "#!/bin/sh\nif [ \"$1\" = get ] && [ \"$2\" = github ]; then echo gh-token; exit 0; fi\necho \"no such secret: $2\" >&2\nexit 1\n"
+	if err := os.WriteFile(helper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := execProvider{}
+	if v, err := p.Lookup(context.Background(), helper+":github"); err != nil || v != "gh-token" {
+		t.Errorf("Expected helper output, got %q, %v", v, err)
+	}
+	if _, err := p.Lookup(context.Background(), helper+":gitlab"); err == nil || !strings.Contains(err.Error(), "no such secret") {
+		t.Errorf("Expected helper error, got %v", err)
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			w.Write([]byte(`{"data":{"data":{"password":"v2-pw","port":5432},"metadata":{"version":3}}}`))
+		case "/v1/kv/app":
+			w.Write([]byte(`{"data":{"value":"v1-value"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	p := &vaultProvider{client: server.Client()}
+
+	tests := map[string]string{
+		"secret/data/app#password": "v2-pw",
+		"secret/data/app#port":     "5432",
+		"kv/app":                   "v1-value",
+	}
+	for ref, want := range tests {
+		if got, err := p.Lookup(context.Background(), ref); err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", ref, got, err, want)
+		}
+	}
+
+	if _, err := p.Lookup(context.Background(), "secret/data/missing"); err == nil {
+		t.Error("Expected error for missing path")
+	}
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := p.Lookup(context.Background(), "kv/app"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func init() {
+	RegisterProvider("vault", &vaultProvider{client: http.DefaultClient})
+}
+
+// vaultProvider reads from an HTTP KV store speaking Vault's API. The reference is the
+// API path after /v1/ plus an optional field (default "value"):
+//
+//	vault:secret/data/app#password    (KV v2)
+//	vault:kv/app#password             (KV v1)
+//
+// The server and token come from VAULT_ADDR and VAULT_TOKEN (or ~/.vault-token);
+// VAULT_NAMESPACE is sent when set.
+type vaultProvider struct {
+	client *http.Client
+}
+
+func (p *vaultProvider) Lookup(ctx context.Context, path string) (string, error) {
+	apiPath, field := splitField(path)
+	if field == "" {
+		field = "value"
+	}
+
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimPrefix(apiPath, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	if token := vaultToken(); token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			return "", fmt.Errorf("vault: %s (%s)", strings.Join(apiErr.Errors, "; "), resp.Status)
+		}
+		return "", fmt.Errorf("vault: %s", resp.Status)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("vault: invalid response: %w", err)
+	}
+
+	// KV v2 nests the key/value pairs under data.data next to data.metadata
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, v2 := data["metadata"]; v2 {
+			data = inner
+		}
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field '%s' not found at %s", field, apiPath)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func vaultToken() string {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token
+	}
+	if data, err := os.ReadFile(expandHome("~/.vault-token")); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
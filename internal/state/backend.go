@@ -0,0 +1,287 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/utils"
+)
+
+// Backend stores the serialized state document and its lock.
+// Read returns an error wrapping os.ErrNotExist when no state was saved yet;
+// Lock returns one wrapping os.ErrExist while another process holds the lock.
+// WriteBackup stores a copy of a state document next to it, named with suffix.
+type Backend interface {
+	Read() ([]byte, error)
+	Write(data []byte) error
+	WriteBackup(suffix string, data []byte) error
+	Lock(info LockInfo) error
+	ReadLock() (LockInfo, error)
+	Unlock() error
+	String() string
+}
+
+// Backend types accepted in the 'state' section of veto.yaml.
+const (
+	BackendLocal  = "local"  // File on the machine running veto (default)
+	BackendRemote = "remote" // File on the managed host, over its transport (SFTP for SSH hosts)
+	BackendS3     = "s3"     // Object in an S3-compatible bucket
+)
+
+// HostPlaceholder in a path or key is replaced with the inventory name of the host,
+// so one backend configuration can hold the state of a whole fleet.
+const HostPlaceholder = "{host}"
+
+// DefaultRemotePath is where the remote backend keeps the state on a host
+// (relative paths resolve to the login user's home directory over SFTP).
+const DefaultRemotePath = ".veto/state.json"
+
+// DefaultS3Key is the object key of the state in the bucket.
+const DefaultS3Key = "veto/" + HostPlaceholder + "/state.json"
+
+// BackendConfig is the 'state' section of veto.yaml:
+//
+//	state:
+//	  backend: s3
+//	  bucket: infra-state
+//	  key: veto/{host}/state.json
+//	  endpoint: https://minio.internal:9000
+//	  path_style: true
+//	  keep_transactions: 100
+//	  max_age: 90d
+type BackendConfig struct {
+	Backend string `yaml:"backend,omitempty"`
+	Path    string `yaml:"path,omitempty"` // local and remote
+
+	Bucket    string `yaml:"bucket,omitempty"`
+	Key       string `yaml:"key,omitempty"`
+	Endpoint  string `yaml:"endpoint,omitempty"`
+	Region    string `yaml:"region,omitempty"`
+	AccessKey string `yaml:"access_key,omitempty"` // Default AWS_ACCESS_KEY_ID, may be ENC[...]
+	SecretKey string `yaml:"secret_key,omitempty"` // Default AWS_SECRET_ACCESS_KEY, may be ENC[...]
+	PathStyle bool   `yaml:"path_style,omitempty"` // bucket in the path instead of the host name (MinIO)
+
+	KeepTransactions int    `yaml:"keep_transactions,omitempty"` // Newest transactions kept in the history, 0 keeps all
+	MaxAge           string `yaml:"max_age,omitempty"`           // Older transactions are dropped (720h, 30d)
+}
+
+// Target is the machine a state belongs to.
+type Target struct {
+	Name string     // Inventory name, empty for the machine running veto
+	FS   FileSystem // Filesystem of the target, used by the remote backend
+}
+
+// NewBackend creates the backend described by cfg for target. A nil cfg is the
+// local state file.
+func NewBackend(cfg *BackendConfig, target Target) (Backend, error) {
+	if cfg == nil {
+		cfg = &BackendConfig{}
+	}
+
+	switch cfg.Backend {
+	case "", BackendLocal:
+		path := cfg.Path
+		if path == "" {
+			path = consts.GetStateFilePath()
+		}
+		return &FileBackend{Path: expandHost(path, target.Name), FS: osFS{}}, nil
+	case BackendRemote:
+		if target.FS == nil {
+			return nil, errors.New("remote state backend needs a host filesystem")
+		}
+		path := cfg.Path
+		if path == "" {
+			path = DefaultRemotePath
+		}
+		return &FileBackend{Path: expandHost(path, target.Name), FS: target.FS}, nil
+	case BackendS3:
+		key := cfg.Key
+		if key == "" {
+			key = DefaultS3Key
+		}
+		return NewS3Backend(cfg, expandHost(key, target.Name))
+	default:
+		return nil, fmt.Errorf("unknown state backend '%s' (expected %s, %s or %s)", cfg.Backend, BackendLocal, BackendRemote, BackendS3)
+	}
+}
+
+// Open opens the state described by cfg for target, with its retention settings.
+func Open(cfg *BackendConfig, target Target) (*Manager, error) {
+	backend, err := NewBackend(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	return openManager(cfg, backend)
+}
+
+// OpenHost opens the state of a fleet host, see NewHostBackend.
+func OpenHost(cfg *BackendConfig, target Target) (*Manager, error) {
+	backend, err := NewHostBackend(cfg, target)
+	if err != nil {
+		return nil, err
+	}
+	return openManager(cfg, backend)
+}
+
+func openManager(cfg *BackendConfig, backend Backend) (*Manager, error) {
+	retention, err := cfg.Retention()
+	if err != nil {
+		return nil, err
+	}
+	mgr, err := NewManagerWithBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+	mgr.Retention = retention
+	return mgr, nil
+}
+
+// NewHostBackend creates the backend holding the state of a fleet host. Every host
+// keeps its own state: the local backend defaults to hosts/{host}/state.json in the
+// veto directory, and a local path or S3 key shared by the fleet must contain {host}.
+func NewHostBackend(cfg *BackendConfig, target Target) (Backend, error) {
+	if target.Name == "" || strings.ContainsAny(target.Name, `/\`) || target.Name == "." || target.Name == ".." {
+		return nil, fmt.Errorf("invalid host name '%s' for the fleet state", target.Name)
+	}
+
+	hostCfg := BackendConfig{}
+	if cfg != nil {
+		hostCfg = *cfg
+	}
+	switch hostCfg.Backend {
+	case "", BackendLocal:
+		if hostCfg.Path == "" {
+			hostCfg.Path = filepath.Join(consts.GetVetoDir(), consts.HostsDirName, HostPlaceholder, consts.StateFileName)
+		} else if !strings.Contains(hostCfg.Path, HostPlaceholder) {
+			return nil, fmt.Errorf("state path '%s' must contain %s in fleet mode, or every host would share it", hostCfg.Path, HostPlaceholder)
+		}
+	case BackendS3:
+		if hostCfg.Key != "" && !strings.Contains(hostCfg.Key, HostPlaceholder) {
+			return nil, fmt.Errorf("state key '%s' must contain %s in fleet mode, or every host would share it", hostCfg.Key, HostPlaceholder)
+		}
+	}
+	return NewBackend(&hostCfg, target)
+}
+
+func expandHost(s, host string) string {
+	if host == "" {
+		host = "local"
+	}
+	return strings.ReplaceAll(s, HostPlaceholder, host)
+}
+
+// FileBackend keeps the state in a file, with the lock in a file next to it.
+type FileBackend struct {
+	Path string
+	FS   FileSystem
+}
+
+func (b *FileBackend) String() string { return b.Path }
+
+func (b *FileBackend) lockPath() string { return b.Path + LockSuffix }
+
+func (b *FileBackend) Read() ([]byte, error) {
+	return b.FS.ReadFile(b.Path)
+}
+
+func (b *FileBackend) Write(data []byte) error {
+	if err := b.FS.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return err
+	}
+	if w, ok := b.FS.(AtomicWriter); ok {
+		return w.WriteFileAtomic(b.Path, data, 0644)
+	}
+	return b.FS.WriteFile(b.Path, data, 0644)
+}
+
+func (b *FileBackend) WriteBackup(suffix string, data []byte) error {
+	return b.FS.WriteFile(b.Path+suffix, data, 0644)
+}
+
+func (b *FileBackend) Lock(info LockInfo) error {
+	data, err := jsonLock(info)
+	if err != nil {
+		return err
+	}
+	if err := b.FS.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return err
+	}
+
+	if c, ok := b.FS.(ExclusiveCreator); ok {
+		return c.CreateExclusive(b.lockPath(), data, 0644)
+	}
+	// Not atomic, but the best a plain read/write filesystem offers
+	if _, err := b.FS.ReadFile(b.lockPath()); err == nil {
+		return os.ErrExist
+	}
+	return b.FS.WriteFile(b.lockPath(), data, 0644)
+}
+
+func (b *FileBackend) ReadLock() (LockInfo, error) {
+	data, err := b.FS.ReadFile(b.lockPath())
+	if err != nil {
+		return LockInfo{}, err
+	}
+	return parseLock(b.lockPath(), data)
+}
+
+func (b *FileBackend) Unlock() error {
+	r, ok := b.FS.(interface{ Remove(string) error })
+	if !ok {
+		return fmt.Errorf("filesystem cannot remove %s", b.lockPath())
+	}
+	return r.Remove(b.lockPath())
+}
+
+func jsonLock(info LockInfo) ([]byte, error) {
+	return json.MarshalIndent(info, "", "  ")
+}
+
+func parseLock(name string, data []byte) (LockInfo, error) {
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("invalid lock %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// osFS is the local filesystem, used by the local backend.
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (osFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+
+// CreateExclusive writes a new file, failing with an os.ErrExist error if it exists.
+func (osFS) CreateExclusive(name string, data []byte, perm os.FileMode) error {
+	return utils.CreateExclusive(name, data, perm)
+}
+
+// AppendFile appends data to name and fsyncs it.
+func (osFS) AppendFile(name string, data []byte, perm os.FileMode) error {
+	return utils.AppendFile(name, data, perm)
+}
+
+// WriteFileAtomic replaces name through a synced temporary file and a rename.
+func (osFS) WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	return utils.WriteFileAtomic(name, data, perm)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (osFS) RemoveAll(path string) error                { return os.RemoveAll(path) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (osFS) Readlink(name string) (string, error)       { return os.Readlink(name) }
+func (osFS) Symlink(oldname, newname string) error      { return os.Symlink(oldname, newname) }
+func (osFS) Chmod(name string, mode os.FileMode) error  { return os.Chmod(name, mode) }
+func (osFS) Rename(oldpath, newpath string) error       { return os.Rename(oldpath, newpath) }
+func (osFS) Chown(name string, uid, gid int) error      { return os.Lchown(name, uid, gid) }
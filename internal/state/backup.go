@@ -1,36 +1,122 @@
 package state
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
 
 	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+const (
+	blobsDir   = "blobs"   // Content, one file per sha256
+	entriesDir = "entries" // Metadata, entries/<txID>/<id>.json
 )
 
-// BackupManager handles creating copies of files before modification
+// BackupManager keeps copies of files taken before they are modified. Content is
+// stored once per sha256 under blobs/, so a file backed up unchanged by many
+// transactions takes its space once; each backup is an entry recording the
+// original path, mode, owner and transaction.
+//
+// The store is always on this machine. The files backed up and restored are read and
+// written through FS, so backups of a fleet host are streamed back over its transport.
 type BackupManager struct {
 	BaseDir string
+	FS      HostFS // Filesystem of the backed up files, the local one when nil
+	Host    string // Fleet host the files belong to, empty for this machine
+}
+
+// HostFS is the filesystem of the machine whose files are backed up. Both the local
+// filesystem and SFTP implement it; Rename and Chown are used when available.
+type HostFS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	RemoveAll(path string) error
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Chmod(name string, mode os.FileMode) error
+}
+
+// BackupEntry is the metadata of one backup.
+type BackupEntry struct {
+	ID   string      `json:"id"`
+	TxID string      `json:"tx_id"`
+	Path string      `json:"path"` // Original path of the file
+	Mode os.FileMode `json:"mode"`
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+	Size int64       `json:"size"`
+	Hash string      `json:"hash"` // sha256 of the stored content
+	Blob string      `json:"blob"` // Path of the stored content
+	Host string      `json:"host,omitempty"`
+	Time time.Time   `json:"time"`
 }
 
+// Encrypted reports whether the content was stored encrypted (.enc).
+func (e BackupEntry) Encrypted() bool {
+	return strings.HasSuffix(e.Blob, ".enc")
+}
+
+// GCStats counts what a garbage collection removed.
+type GCStats struct {
+	Entries int
+	Blobs   int
+	Bytes   int64
+}
+
+// NewBackupManager returns the store in baseDir, by default the backups directory
+// next to the state.
 func NewBackupManager(baseDir string) *BackupManager {
 	if baseDir == "" {
-		// Default to .veto/backups relative to user home is risky if not configured right,
-		// but Manager usually passes the base state path.
-		// Let's assume absolute path passed or handle standard location.
-		home, _ := os.UserHomeDir()
-		baseDir = filepath.Join(home, consts.GetVetoDir(), consts.BackupDirName)
+		baseDir = filepath.Join(consts.GetVetoDir(), consts.BackupDirName)
+	}
+	if abs, err := filepath.Abs(baseDir); err == nil {
+		// Backup paths are recorded in the history and must not depend on the cwd
+		baseDir = abs
 	}
 	return &BackupManager{BaseDir: baseDir}
 }
 
-// CreateBackup copies the source file to the backup directory associated with the txID.
-// Returns the absolute path to the backup file.
+// NewHostBackupManager returns the store of fleet host name, next to its state in
+// hosts/<name>/backups, reading and writing the host's files through hostFS.
+func NewHostBackupManager(name string, hostFS HostFS) *BackupManager {
+	bm := NewBackupManager(filepath.Join(consts.GetVetoDir(), consts.HostsDirName, name, consts.BackupDirName))
+	bm.FS = hostFS
+	bm.Host = name
+	return bm
+}
+
+// fs returns the filesystem of the backed up files.
+func (bm *BackupManager) fs() HostFS {
+	if bm.FS == nil {
+		return osFS{}
+	}
+	return bm.FS
+}
+
+// CreateBackup stores a copy of sourcePath for transaction txID and returns the
+// path of the stored content. A missing source is not an error; the returned path
+// is empty then.
 func (bm *BackupManager) CreateBackup(txID, sourcePath string) (string, error) {
-	// 1. Verify source exists
-	info, err := os.Stat(sourcePath)
+	info, err := bm.fs().Stat(sourcePath)
 	if os.IsNotExist(err) {
 		return "", nil // Nothing to backup
 	}
@@ -38,64 +124,368 @@ func (bm *BackupManager) CreateBackup(txID, sourcePath string) (string, error) {
 		return "", err
 	}
 	if info.IsDir() {
-		return "", fmt.Errorf("backup source '%s' is a directory, not supported", sourcePath)
+		return "", fmt.Errorf("backup source '%s' is a directory, use a tree backup", sourcePath)
 	}
 
-	// 2. Prepare backup path: baseDir / txID / <hash_of_path>
-	// Hashing the path ensures unique filenames without directory depth issues
-	pathHash := fmt.Sprintf("%x", sha256.Sum256([]byte(sourcePath)))
-	backupDir := filepath.Join(bm.BaseDir, txID)
-	backupPath := filepath.Join(backupDir, pathHash)
-
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create backup dir: %w", err)
+	data, err := bm.fs().ReadFile(sourcePath)
+	if err != nil {
+		return "", err
 	}
-
-	// 3. Copy file
-	src, err := os.Open(sourcePath)
+	hash, size, blob, err := bm.storeBlob(bytes.NewReader(data), "")
 	if err != nil {
 		return "", err
 	}
-	defer src.Close()
+	return blob, bm.addEntry(txID, sourcePath, info, hash, size, blob)
+}
 
-	dst, err := os.Create(backupPath)
+// StoreBackup stores already prepared backup content for sourcePath, e.g. a file
+// re-encrypted because it was decrypted from an encrypted source. The content gets
+// the given suffix (".enc") so restore knows how to treat it.
+func (bm *BackupManager) StoreBackup(txID, sourcePath, suffix string, data []byte) (string, error) {
+	info, err := bm.fs().Stat(sourcePath)
 	if err != nil {
 		return "", err
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, src); err != nil {
+	hash, size, blob, err := bm.storeBlob(bytes.NewReader(data), suffix)
+	if err != nil {
 		return "", err
 	}
+	return blob, bm.addEntry(txID, sourcePath, info, hash, size, blob)
+}
+
+// storeBlob writes the content of r to the store unless it is there already, and
+// returns its hash, size and path.
+func (bm *BackupManager) storeBlob(r io.Reader, suffix string) (string, int64, string, error) {
+	dir := filepath.Join(bm.BaseDir, blobsDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", 0, "", fmt.Errorf("failed to create backup dir: %w", err)
+	}
 
-	return backupPath, nil
+	// Hash while copying to a temporary file, then move it in place
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	blob := bm.blobPath(hash, suffix)
+	if _, err := os.Stat(blob); err == nil {
+		return hash, size, blob, nil // Deduplicated
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0700); err != nil {
+		return "", 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), blob); err != nil {
+		return "", 0, "", err
+	}
+	return hash, size, blob, nil
 }
 
-// RestoreBackup copies the backup file back to the target destination
-func (bm *BackupManager) RestoreBackup(backupPath, targetPath string) error {
-	// Verify backup exists
-	if _, err := os.Stat(backupPath); err != nil {
-		return fmt.Errorf("backup not found at %s: %w", backupPath, err)
+func (bm *BackupManager) blobPath(hash, suffix string) string {
+	return filepath.Join(bm.BaseDir, blobsDir, hash[:2], hash+suffix)
+}
+
+// addEntry records the metadata of a backup of path.
+func (bm *BackupManager) addEntry(txID, path string, info os.FileInfo, hash string, size int64, blob string) error {
+	if bm.FS == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	entry := BackupEntry{
+		ID:   backupID(txID, path),
+		TxID: txID,
+		Path: path,
+		Mode: info.Mode().Perm(),
+		UID:  -1,
+		GID:  -1,
+		Size: size,
+		Hash: hash,
+		Blob: blob,
+		Host: bm.Host,
+		Time: time.Now(),
 	}
+	entry.UID, entry.GID = owner(info)
 
-	// Ensure target dir exists
-	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+	dir := filepath.Join(bm.BaseDir, entriesDir, txID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup dir: %w", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
 		return err
 	}
+	return os.WriteFile(filepath.Join(dir, entry.ID+".json"), data, 0600)
+}
+
+// owner returns the owner of a file, or -1 when the filesystem doesn't tell.
+func owner(info fs.FileInfo) (int, int) {
+	switch st := info.Sys().(type) {
+	case *syscall.Stat_t:
+		return int(st.Uid), int(st.Gid)
+	case *sftp.FileStat:
+		return int(st.UID), int(st.GID)
+	}
+	return -1, -1
+}
+
+// chown gives path the recorded owner where the filesystem supports it. Only root may
+// do so, so failures are ignored.
+func (bm *BackupManager) chown(path string, uid, gid int) {
+	c, ok := bm.fs().(interface{ Chown(string, int, int) error })
+	if ok && uid >= 0 {
+		c.Chown(path, uid, gid)
+	}
+}
+
+// backupID derives the ID of the backup of path taken by transaction txID.
+func backupID(txID, path string) string {
+	sum := sha256.Sum256([]byte(txID + "\x00" + path))
+	return hex.EncodeToString(sum[:])[:12]
+}
 
-	// Copy back
-	src, err := os.Open(backupPath)
+// List returns all backups, oldest first.
+func (bm *BackupManager) List() ([]BackupEntry, error) {
+	var entries []BackupEntry
+	root := filepath.Join(bm.BaseDir, entriesDir)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e BackupEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("invalid backup entry %s: %w", path, err)
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, err
+}
+
+// Find returns the backup whose ID is id or starts with it. A prefix matching
+// several backups is an error.
+func (bm *BackupManager) Find(id string) (BackupEntry, error) {
+	entries, err := bm.List()
+	if err != nil {
+		return BackupEntry{}, err
+	}
+	var found []BackupEntry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if id != "" && strings.HasPrefix(e.ID, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return BackupEntry{}, fmt.Errorf("backup not found: %s", id)
+	case 1:
+		return found[0], nil
+	}
+	return BackupEntry{}, fmt.Errorf("backup ID '%s' is ambiguous", id)
+}
+
+// Restore writes the backup to target with its recorded mode and owner; directory
+// trees replace target as a whole. decrypt turns the content of encrypted backups
+// back into plaintext.
+func (bm *BackupManager) Restore(e BackupEntry, target string, decrypt func([]byte) ([]byte, error)) error {
+	if IsTreeBackup(e.Blob) {
+		return bm.RestoreTree(e.Blob, target)
+	}
+	data, err := os.ReadFile(e.Blob)
 	if err != nil {
+		return fmt.Errorf("backup content of %s is missing: %w", e.ID, err)
+	}
+	if e.Encrypted() {
+		if decrypt == nil {
+			return fmt.Errorf("backup %s is encrypted and no keyring is available", e.ID)
+		}
+		if data, err = decrypt(data); err != nil {
+			return err
+		}
+	}
+
+	hfs := bm.fs()
+	if err := hfs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := hfs.WriteFile(target, data, e.Mode); err != nil {
+		return err
+	}
+	if err := hfs.Chmod(target, e.Mode); err != nil {
 		return err
 	}
-	defer src.Close()
+	bm.chown(target, e.UID, e.GID)
+	return nil
+}
+
+// RestoreBackup writes the content stored at backupPath back to targetPath with the
+// mode and owner recorded in its entry. Content without an entry (from an older
+// version) keeps the mode of a target that still exists.
+func (bm *BackupManager) RestoreBackup(backupPath, targetPath string) error {
+	if e, ok := bm.findByBlob(backupPath, targetPath); ok {
+		return bm.Restore(e, targetPath, nil)
+	}
 
-	dst, err := os.Create(targetPath)
+	data, err := os.ReadFile(backupPath)
 	if err != nil {
+		return fmt.Errorf("backup not found at %s: %w", backupPath, err)
+	}
+
+	hfs := bm.fs()
+	mode := os.FileMode(0644)
+	if info, err := hfs.Stat(targetPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := hfs.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 		return err
 	}
-	defer dst.Close()
+	return hfs.WriteFile(targetPath, data, mode)
+}
+
+// findByBlob returns the newest entry whose content is stored at blob, preferring one
+// taken of path, since identical files share their content.
+func (bm *BackupManager) findByBlob(blob, path string) (BackupEntry, bool) {
+	entries, err := bm.List()
+	if err != nil {
+		return BackupEntry{}, false
+	}
+	if bm.FS == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	var found BackupEntry
+	ok := false
+	for _, e := range entries {
+		if e.Blob != blob {
+			continue
+		}
+		if e.Path == path || !ok || found.Path != path {
+			found, ok = e, true
+		}
+	}
+	return found, ok
+}
+
+// GC removes the backups of transactions that are no longer in history, and the
+// content no remaining backup or transaction refers to. Backup directories of older
+// versions (one per transaction) are removed the same way. With dryRun nothing is
+// removed; the stats tell what would be.
+func (bm *BackupManager) GC(history []types.Transaction, dryRun bool) (GCStats, error) {
+	var stats GCStats
+	keep := make(map[string]bool)
+	used := make(map[string]bool)
+	for _, tx := range history {
+		keep[tx.ID] = true
+		for _, c := range tx.Changes {
+			if c.BackupPath != "" {
+				used[c.BackupPath] = true
+			}
+		}
+	}
+	remove := func(path string) error {
+		if dryRun {
+			return nil
+		}
+		return os.RemoveAll(path)
+	}
 
-	_, err = io.Copy(dst, src)
-	return err
+	entries, err := bm.List()
+	if err != nil {
+		return stats, err
+	}
+	for _, e := range entries {
+		if keep[e.TxID] {
+			used[e.Blob] = true
+			continue
+		}
+		if err := remove(filepath.Join(bm.BaseDir, entriesDir, e.TxID, e.ID+".json")); err != nil {
+			return stats, err
+		}
+		stats.Entries++
+	}
+	if !dryRun {
+		removeEmptyDirs(filepath.Join(bm.BaseDir, entriesDir))
+	}
+
+	// Legacy per-transaction directories
+	dirs, err := os.ReadDir(bm.BaseDir)
+	if err != nil && !os.IsNotExist(err) {
+		return stats, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || d.Name() == blobsDir || d.Name() == entriesDir || keep[d.Name()] {
+			continue
+		}
+		dir := filepath.Join(bm.BaseDir, d.Name())
+		files, _ := os.ReadDir(dir)
+		for _, f := range files {
+			if info, err := f.Info(); err == nil {
+				stats.Blobs++
+				stats.Bytes += info.Size()
+			}
+		}
+		if err := remove(dir); err != nil {
+			return stats, err
+		}
+	}
+
+	root := filepath.Join(bm.BaseDir, blobsDir)
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || used[path] {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stats.Bytes += info.Size()
+		}
+		stats.Blobs++
+		return remove(path)
+	})
+	if err == nil && !dryRun {
+		removeEmptyDirs(root)
+	}
+	return stats, err
+}
+
+// removeEmptyDirs removes the empty subdirectories of root.
+func removeEmptyDirs(root string) {
+	dirs, _ := os.ReadDir(root)
+	for _, d := range dirs {
+		if d.IsDir() {
+			os.Remove(filepath.Join(root, d.Name())) // Fails unless empty
+		}
+	}
 }
@@ -1,9 +1,13 @@
 package state
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/melih-ucgun/veto/internal/types"
 )
 
 func TestBackupManager(t *testing.T) {
@@ -34,10 +38,13 @@ func TestBackupManager(t *testing.T) {
 	}
 
 	// 2. Test RestoreBackup
-	// Modify source
+	// Modify source, and its mode: the recorded one is restored
 	if err := os.WriteFile(srcFile, []byte("modified content"), 0644); err != nil {
 		t.Fatal(err)
 	}
+	if err := os.Chmod(srcFile, 0600); err != nil {
+		t.Fatal(err)
+	}
 
 	if err := bm.RestoreBackup(backupPath, srcFile); err != nil {
 		t.Fatalf("RestoreBackup failed: %v", err)
@@ -51,6 +58,9 @@ func TestBackupManager(t *testing.T) {
 	if string(restoredContent) != string(content) {
 		t.Errorf("Restore failed. Got '%s', want '%s'", string(restoredContent), string(content))
 	}
+	if info, err := os.Stat(srcFile); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Restore did not apply the recorded mode: %v", info.Mode())
+	}
 
 	// 3. Test CreateBackup non-existent file
 	noFile := filepath.Join(tmpDir, "doesnotexist")
@@ -62,3 +72,217 @@ func TestBackupManager(t *testing.T) {
 		t.Errorf("Expected empty path for non-existent file, got %s", path)
 	}
 }
+
+func TestBackupManager_Dedup(t *testing.T) {
+	tmpDir := t.TempDir()
+	bm := NewBackupManager(filepath.Join(tmpDir, "backups"))
+
+	src := filepath.Join(tmpDir, "motd")
+	if err := os.WriteFile(src, []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	first, err := bm.CreateBackup("tx1", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := bm.CreateBackup("tx2", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("Unchanged content stored twice: %s, %s", first, second)
+	}
+
+	entries, err := bm.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(entries))
+	}
+	e, err := bm.Find(entries[0].ID[:6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Path != src || e.TxID != "tx1" || e.Mode != 0640 || e.Size != 5 {
+		t.Errorf("Unexpected metadata: %+v", e)
+	}
+
+	// Restore with the recorded mode
+	dest := filepath.Join(tmpDir, "restored")
+	if err := bm.Restore(e, dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Restored mode %o, want 0640", info.Mode().Perm())
+	}
+}
+
+func TestBackupManager_GC(t *testing.T) {
+	tmpDir := t.TempDir()
+	bm := NewBackupManager(filepath.Join(tmpDir, "backups"))
+
+	src := filepath.Join(tmpDir, "motd")
+	os.WriteFile(src, []byte("old"), 0644)
+	oldBlob, _ := bm.CreateBackup("tx1", src)
+	os.WriteFile(src, []byte("new"), 0644)
+	newBlob, _ := bm.CreateBackup("tx2", src)
+
+	// Backup directory of an older version
+	legacy := filepath.Join(bm.BaseDir, "tx0")
+	os.MkdirAll(legacy, 0755)
+	os.WriteFile(filepath.Join(legacy, "abc"), []byte("x"), 0644)
+
+	history := []types.Transaction{{ID: "tx2"}}
+
+	stats, err := bm.GC(history, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 1 || stats.Blobs != 2 {
+		t.Errorf("Dry run stats: %+v", stats)
+	}
+	if _, err := os.Stat(oldBlob); err != nil {
+		t.Error("Dry run removed a backup")
+	}
+
+	if _, err := bm.GC(history, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldBlob); !os.IsNotExist(err) {
+		t.Error("Backup of tx1 was kept")
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("Legacy backup directory was kept")
+	}
+	if _, err := os.Stat(newBlob); err != nil {
+		t.Error("Backup of tx2 was removed")
+	}
+	entries, _ := bm.List()
+	if len(entries) != 1 || entries[0].TxID != "tx2" {
+		t.Errorf("Unexpected backups after gc: %+v", entries)
+	}
+}
+
+func TestBackupManager_Tree(t *testing.T) {
+	tmpDir := t.TempDir()
+	bm := NewBackupManager(filepath.Join(tmpDir, "backups"))
+
+	dir := filepath.Join(tmpDir, "repo")
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.MkdirAll(filepath.Join(dir, "node_modules", "x"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh"), 0755)
+	os.WriteFile(filepath.Join(dir, "node_modules", "x", "big"), make([]byte, 4096), 0644)
+	os.Symlink("run.sh", filepath.Join(dir, "link"))
+
+	if _, err := bm.CreateTreeBackup("tx1", dir, 1024, nil); !errors.Is(err, ErrBackupTooLarge) {
+		t.Fatalf("Expected ErrBackupTooLarge, got %v", err)
+	}
+
+	blob, err := bm.CreateTreeBackup("tx1", dir, 1024, []string{"node_modules"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := bm.CreateTreeBackup("tx2", dir, 1024, []string{"node_modules"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob != again {
+		t.Errorf("Unchanged tree stored twice: %s, %s", blob, again)
+	}
+
+	// Destroy and restore
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "stray"), []byte("x"), 0644)
+	if err := bm.RestoreTree(blob, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "src", "main.go")); err != nil || string(data) != "package main" {
+		t.Errorf("main.go not restored: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "run.sh")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("run.sh not restored with its mode: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "run.sh" {
+		t.Errorf("Symlink not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "node_modules")); !os.IsNotExist(err) {
+		t.Error("Excluded directory was backed up")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stray")); !os.IsNotExist(err) {
+		t.Error("Restore kept a file that was not in the backup")
+	}
+}
+
+// remoteFS stands in for a fleet host: its paths live below root.
+type remoteFS struct{ root string }
+
+func (f remoteFS) p(name string) string                       { return filepath.Join(f.root, name) }
+func (f remoteFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(f.p(name)) }
+func (f remoteFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(f.p(name)) }
+func (f remoteFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(f.p(name)) }
+func (f remoteFS) RemoveAll(path string) error                { return os.RemoveAll(f.p(path)) }
+func (f remoteFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(f.p(name)) }
+func (f remoteFS) Readlink(name string) (string, error)       { return os.Readlink(f.p(name)) }
+func (f remoteFS) Symlink(oldname, newname string) error      { return os.Symlink(oldname, f.p(newname)) }
+func (f remoteFS) Chmod(name string, mode os.FileMode) error  { return os.Chmod(f.p(name), mode) }
+func (f remoteFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(f.p(path), perm)
+}
+func (f remoteFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(f.p(name), data, perm)
+}
+
+func TestBackupManager_HostFS(t *testing.T) {
+	tmpDir := t.TempDir()
+	host := remoteFS{root: filepath.Join(tmpDir, "host")}
+	bm := NewBackupManager(filepath.Join(tmpDir, "backups"))
+	bm.FS = host
+	bm.Host = "web1"
+
+	host.MkdirAll("/etc/app/conf.d", 0755)
+	host.WriteFile("/etc/app/app.conf", []byte("remote"), 0640)
+	host.WriteFile("/etc/app/conf.d/a.conf", []byte("a"), 0644)
+
+	// The path only exists on the host
+	fileBlob, err := bm.CreateBackup("tx1", "/etc/app/app.conf")
+	if err != nil || fileBlob == "" {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	treeBlob, err := bm.CreateTreeBackup("tx1", "/etc/app/conf.d", 0, nil)
+	if err != nil || treeBlob == "" {
+		t.Fatalf("CreateTreeBackup failed: %v", err)
+	}
+	entries, _ := bm.List()
+	for _, e := range entries {
+		if e.Host != "web1" || (e.Path != "/etc/app/app.conf" && e.Path != "/etc/app/conf.d") {
+			t.Errorf("Unexpected entry: %+v", e)
+		}
+	}
+
+	host.WriteFile("/etc/app/app.conf", []byte("changed"), 0640)
+	host.RemoveAll("/etc/app/conf.d")
+
+	if err := bm.RestoreBackup(fileBlob, "/etc/app/app.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.RestoreTree(treeBlob, "/etc/app/conf.d"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := host.ReadFile("/etc/app/app.conf"); string(data) != "remote" {
+		t.Errorf("File restored as '%s'", data)
+	}
+	if data, _ := host.ReadFile("/etc/app/conf.d/a.conf"); string(data) != "a" {
+		t.Errorf("Tree restored with '%s'", data)
+	}
+	if _, err := os.Stat("/etc/app/conf.d"); err == nil {
+		t.Error("Restore wrote to the local filesystem")
+	}
+}
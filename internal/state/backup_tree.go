@@ -0,0 +1,295 @@
+package state
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TreeSuffix marks stored content that is a directory tree (a gzipped tar).
+const TreeSuffix = ".tar.gz"
+
+// ErrBackupTooLarge is returned when a directory exceeds the size limit of its backup.
+var ErrBackupTooLarge = errors.New("directory is larger than the backup size limit")
+
+// IsTreeBackup reports whether backupPath holds a directory tree.
+func IsTreeBackup(backupPath string) bool {
+	return strings.HasSuffix(backupPath, TreeSuffix)
+}
+
+// CreateTreeBackup stores the directory dir as a tree for transaction txID and returns
+// the path of the stored content. Files and directories whose name or path relative to
+// dir match one of the exclude globs are left out. A tree holding more than maxSize
+// bytes (0 for no limit) is refused with ErrBackupTooLarge. A missing dir is not an
+// error; the returned path is empty then.
+func (bm *BackupManager) CreateTreeBackup(txID, dir string, maxSize int64, exclude []string) (string, error) {
+	hfs := bm.fs()
+	info, err := hfs.Stat(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("backup source '%s' is not a directory", dir)
+	}
+
+	// Check the size first, so nothing is written for a tree that is refused
+	var total int64
+	err = walkTree(hfs, dir, exclude, func(path, rel string, info os.FileInfo) error {
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		if maxSize > 0 && total > maxSize {
+			return fmt.Errorf("%w: %s holds more than %d bytes", ErrBackupTooLarge, dir, maxSize)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTree(pw, hfs, dir, exclude))
+	}()
+	hash, size, blob, err := bm.storeBlob(pr, TreeSuffix)
+	pr.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", dir, err)
+	}
+	return blob, bm.addEntry(txID, dir, info, hash, size, blob)
+}
+
+// walkTree calls fn for everything below dir that no exclude glob matches, parents
+// before their children. Symlinks are not followed.
+func walkTree(hfs HostFS, dir string, exclude []string, fn func(path, rel string, info os.FileInfo) error) error {
+	var walk func(sub string) error
+	walk = func(sub string) error {
+		entries, err := hfs.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return err
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+		for _, d := range entries {
+			rel := filepath.Join(sub, d.Name())
+			if excluded(rel, exclude) {
+				continue
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if err := fn(filepath.Join(dir, rel), rel, info); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if err := walk(rel); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk("")
+}
+
+func excluded(rel string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTree writes dir as a gzipped tar to w. Modification times are left out, so an
+// unchanged tree always produces the same content.
+func writeTree(w io.Writer, hfs HostFS, dir string, exclude []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	// The directory itself, for its mode and owner
+	info, err := hfs.Stat(dir)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = "./"
+	hdr.Uid, hdr.Gid = owner(info)
+	if err := tw.WriteHeader(treeHeader(hdr)); err != nil {
+		return err
+	}
+
+	err = walkTree(hfs, dir, exclude, func(path, rel string, info os.FileInfo) error {
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := hfs.Readlink(path)
+			if err != nil {
+				return err
+			}
+			link = target
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil // Sockets, devices and pipes are not backed up
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid = owner(info)
+		if !info.Mode().IsRegular() {
+			return tw.WriteHeader(treeHeader(hdr))
+		}
+
+		data, err := hfs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		hdr.Size = int64(len(data)) // The file may have changed since the walk
+		if err := tw.WriteHeader(treeHeader(hdr)); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// treeHeader drops the times and names that would make the tar of an unchanged
+// tree differ.
+func treeHeader(hdr *tar.Header) *tar.Header {
+	hdr.ModTime = time.Unix(0, 0)
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	hdr.Uname, hdr.Gname = "", ""
+	hdr.Format = tar.FormatPAX
+	return hdr
+}
+
+// RestoreTree replaces the directory target with the tree stored at backupPath. Where
+// the filesystem can rename, the tree is extracted next to target first, so a failed
+// restore leaves target as it was.
+func (bm *BackupManager) RestoreTree(backupPath, target string) error {
+	f, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("backup not found at %s: %w", backupPath, err)
+	}
+	defer f.Close()
+
+	hfs := bm.fs()
+	renamer, canRename := hfs.(interface{ Rename(string, string) error })
+	if !canRename {
+		if err := hfs.RemoveAll(target); err != nil {
+			return err
+		}
+		if err := bm.extractTree(f, target); err != nil {
+			return fmt.Errorf("failed to extract backup %s: %w", backupPath, err)
+		}
+		return nil
+	}
+
+	tmp := filepath.Join(filepath.Dir(target), fmt.Sprintf(".%s.veto-restore-%d", filepath.Base(target), time.Now().UnixNano()))
+	defer hfs.RemoveAll(tmp)
+	if err := bm.extractTree(f, tmp); err != nil {
+		return fmt.Errorf("failed to extract backup %s: %w", backupPath, err)
+	}
+	if err := hfs.RemoveAll(target); err != nil {
+		return err
+	}
+	return renamer.Rename(tmp, target)
+}
+
+// extractTree extracts the gzipped tar r into dest, keeping modes and owners.
+func (bm *BackupManager) extractTree(r io.Reader, dest string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	hfs := bm.fs()
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dirMode
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dest, filepath.FromSlash(hdr.Name))
+		if rel, err := filepath.Rel(dest, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path: %s", hdr.Name)
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// Made writable while extracting, the mode is set at the end
+			if err := hfs.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirMode{path, mode})
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := hfs.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			if err := hfs.WriteFile(path, data, mode); err != nil {
+				return err
+			}
+			if err := hfs.Chmod(path, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := hfs.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+			continue // Chown would follow the link
+		default:
+			continue
+		}
+		bm.chown(path, hdr.Uid, hdr.Gid)
+	}
+
+	// Deepest first, so read-only directories don't block their children
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := hfs.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
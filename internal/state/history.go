@@ -2,17 +2,30 @@ package state
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/melih-ucgun/veto/internal/secrets"
 	"github.com/melih-ucgun/veto/internal/types"
 )
 
 // AddTransaction appends a new transaction to history and saves state.
 func (m *Manager) AddTransaction(tx types.Transaction) error {
+	// Never persist secrets: diffs and details are scrubbed even with --show-secrets
+	if tx.Changes != nil {
+		changes := make([]types.TransactionChange, len(tx.Changes))
+		for i, c := range tx.Changes {
+			c.Diff = secrets.Scrub(c.Diff)
+			c.Detail = secrets.Scrub(c.Detail)
+			changes[i] = c
+		}
+		tx.Changes = changes
+	}
+
 	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.Current.History = append(m.Current.History, tx)
-	m.mu.Unlock()
 
-	return m.Save()
+	return m.record(journalRecord{Time: time.Now(), Transaction: &tx})
 }
 
 // GetTransactions returns a copy of history.
@@ -3,9 +3,11 @@ package state
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/melih-ucgun/veto/internal/secrets"
 	"github.com/melih-ucgun/veto/internal/types"
 )
 
@@ -71,3 +73,33 @@ func TestHistoryManager(t *testing.T) {
 		t.Error("Transaction changes mismatch")
 	}
 }
+
+func TestHistoryScrubsSecrets(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	mgr, err := NewManager(stateFile, &MockRealFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets.Taint("history-db-password")
+	secrets.SetReveal(true) // --show-secrets must not leak into the state file
+	defer secrets.SetReveal(false)
+
+	tx := types.Transaction{
+		ID: "tx1",
+		Changes: []types.TransactionChange{
+			{Type: "file", Name: "/etc/app.conf", Diff: "+password=history-db-password\n"},
+		},
+	}
+	if err := mgr.AddTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(stateFile)
+	if strings.Contains(string(data), "history-db-password") {
+		t.Error("State file contains a plaintext secret")
+	}
+	if !strings.Contains(string(data), secrets.Mask) {
+		t.Error("Expected masked diff in state file")
+	}
+}
@@ -0,0 +1,241 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+// JournalSuffix is appended to the state file path to name its journal.
+const JournalSuffix = ".journal"
+
+// CompactEvery is the number of journal records after which the journal is folded
+// into the state snapshot, even while the lock is still held.
+const CompactEvery = 200
+
+// Appender is implemented by filesystems that can durably append to a file
+// (O_APPEND followed by fsync).
+type Appender interface {
+	AppendFile(name string, data []byte, perm os.FileMode) error
+}
+
+// AtomicWriter is implemented by filesystems that can replace a file atomically:
+// write a temporary file, fsync it and rename it over the target.
+type AtomicWriter interface {
+	WriteFileAtomic(name string, data []byte, perm os.FileMode) error
+}
+
+// JournalBackend is implemented by backends that keep an append-only journal next to
+// the state snapshot. Each resource update or transaction is one appended record
+// instead of a rewrite of the whole state.
+type JournalBackend interface {
+	Backend
+	CanAppend() bool
+	AppendJournal(record []byte) error
+	ReadJournal() ([]byte, error)
+	ClearJournal() error
+}
+
+// journalRecord is one line of the journal.
+type journalRecord struct {
+	Time        time.Time            `json:"time"`
+	Removed     string               `json:"removed,omitempty"` // ID of a resource no longer tracked
+	Resource    *types.ResourceEntry `json:"resource,omitempty"`
+	Transaction *types.Transaction   `json:"transaction,omitempty"`
+}
+
+// Retention limits the transaction history kept when the journal is compacted.
+type Retention struct {
+	KeepTransactions int           // Newest transactions kept, 0 keeps all
+	MaxAge           time.Duration // Older transactions are dropped, 0 keeps all
+}
+
+// Retention parses the keep_transactions and max_age settings.
+func (c *BackendConfig) Retention() (Retention, error) {
+	if c == nil {
+		return Retention{}, nil
+	}
+	if c.KeepTransactions < 0 {
+		return Retention{}, fmt.Errorf("keep_transactions must not be negative (got %d)", c.KeepTransactions)
+	}
+	r := Retention{KeepTransactions: c.KeepTransactions}
+	if c.MaxAge != "" {
+		age, err := ParseAge(c.MaxAge)
+		if err != nil {
+			return Retention{}, fmt.Errorf("invalid max_age '%s': %w", c.MaxAge, err)
+		}
+		r.MaxAge = age
+	}
+	return r, nil
+}
+
+// ParseAge accepts Go durations (720h) and whole days (30d).
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New("expected a number of days such as 30d")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, err
+}
+
+// apply drops transactions beyond the retention limits, oldest first.
+func (r Retention) apply(history []types.Transaction, now time.Time) []types.Transaction {
+	if r.MaxAge > 0 {
+		cutoff := now.Add(-r.MaxAge)
+		kept := history[:0:0]
+		for _, tx := range history {
+			if !tx.Timestamp.Before(cutoff) {
+				kept = append(kept, tx)
+			}
+		}
+		history = kept
+	}
+	if r.KeepTransactions > 0 && len(history) > r.KeepTransactions {
+		history = history[len(history)-r.KeepTransactions:]
+	}
+	return history
+}
+
+// journal returns the backend's journal, if it can append.
+func (m *Manager) journal() (JournalBackend, bool) {
+	j, ok := m.Backend.(JournalBackend)
+	if !ok || !j.CanAppend() {
+		return nil, false
+	}
+	return j, true
+}
+
+// record persists one change. Journaling backends append it and compact every
+// CompactEvery records; the others rewrite the whole state. The caller holds m.mu.
+func (m *Manager) record(rec journalRecord) error {
+	j, ok := m.journal()
+	if !ok {
+		return m.save()
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := j.AppendJournal(append(data, '\n')); err != nil {
+		return err
+	}
+	m.journaled++
+	if m.journaled >= CompactEvery {
+		return m.save()
+	}
+	return nil
+}
+
+// replayJournal applies the journal on top of the loaded snapshot and returns the
+// number of records applied. Records that don't decode (a write torn by a crash)
+// are skipped. The caller holds m.mu.
+func (m *Manager) replayJournal() (int, error) {
+	j, ok := m.Backend.(JournalBackend)
+	if !ok {
+		return 0, nil
+	}
+	data, err := j.ReadJournal()
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var rec journalRecord
+		if len(line) == 0 || json.Unmarshal(line, &rec) != nil {
+			continue
+		}
+		m.apply(rec)
+		n++
+	}
+	return n, nil
+}
+
+// apply applies a journal record to the in-memory state.
+func (m *Manager) apply(rec journalRecord) {
+	if rec.Time.After(m.Current.LastRun) {
+		m.Current.LastRun = rec.Time
+	}
+	if rec.Removed != "" {
+		delete(m.Current.Resources, rec.Removed)
+	}
+	if rec.Resource != nil {
+		m.Current.Resources[rec.Resource.ID] = *rec.Resource
+	}
+	if tx := rec.Transaction; tx != nil {
+		// A record replayed over a snapshot that already has it replaces it
+		for i := range m.Current.History {
+			if m.Current.History[i].ID == tx.ID {
+				m.Current.History[i] = *tx
+				return
+			}
+		}
+		m.Current.History = append(m.Current.History, *tx)
+	}
+}
+
+// Compact folds the journal into the state snapshot and applies the retention
+// limits. It is a no-op when nothing was journaled since the last compaction and
+// the state needs no migration.
+func (m *Manager) Compact() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.journal(); (!ok || m.journaled == 0) && m.premigration == nil {
+		return nil
+	}
+	return m.save()
+}
+
+func (b *FileBackend) journalPath() string { return b.Path + JournalSuffix }
+
+// CanAppend reports whether the filesystem supports durable appends.
+func (b *FileBackend) CanAppend() bool {
+	_, ok := b.FS.(Appender)
+	return ok
+}
+
+func (b *FileBackend) AppendJournal(record []byte) error {
+	a, ok := b.FS.(Appender)
+	if !ok {
+		return fmt.Errorf("filesystem cannot append to %s", b.journalPath())
+	}
+	if err := b.FS.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return err
+	}
+	return a.AppendFile(b.journalPath(), record, 0644)
+}
+
+func (b *FileBackend) ReadJournal() ([]byte, error) {
+	return b.FS.ReadFile(b.journalPath())
+}
+
+func (b *FileBackend) ClearJournal() error {
+	r, ok := b.FS.(interface{ Remove(string) error })
+	if !ok {
+		return b.FS.WriteFile(b.journalPath(), nil, 0644)
+	}
+	if err := r.Remove(b.journalPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+func TestJournal(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	mgr, err := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.UpdateResource("pkg", "vim", "present", "success"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.AddTransaction(types.Transaction{ID: "tx1", Timestamp: time.Now(), Status: "success"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updates are appended to the journal, the snapshot is not rewritten
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("Snapshot written before compaction: %v", err)
+	}
+
+	// A crash mid-append leaves a torn record; the others are still replayed
+	f, _ := os.OpenFile(stateFile+JournalSuffix, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"time":"2026-01-01T00:00:00Z","resou`)
+	f.Close()
+
+	mgr2, err := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mgr2.Current.Resources["pkg:vim"]; !ok {
+		t.Error("Resource update not replayed from the journal")
+	}
+	if txs := mgr2.GetTransactions(); len(txs) != 1 || txs[0].ID != "tx1" {
+		t.Errorf("Transaction not replayed from the journal: %+v", txs)
+	}
+
+	// Compaction folds the journal into the snapshot
+	if err := mgr2.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stateFile + JournalSuffix); !os.IsNotExist(err) {
+		t.Errorf("Journal not removed after compaction: %v", err)
+	}
+	mgr3, err := NewManager(stateFile, &MockRealFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mgr3.GetTransactions()) != 1 || len(mgr3.Current.Resources) != 1 {
+		t.Errorf("Compacted snapshot incomplete: %+v", mgr3.Current)
+	}
+}
+
+func TestJournalReplayAfterSnapshot(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	mgr, _ := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	mgr.AddTransaction(types.Transaction{ID: "tx1", Status: "success"})
+
+	// Crash between writing the snapshot and clearing the journal
+	data, _ := os.ReadFile(stateFile + JournalSuffix)
+	mgr.Compact()
+	os.WriteFile(stateFile+JournalSuffix, data, 0644)
+
+	mgr2, err := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txs := mgr2.GetTransactions(); len(txs) != 1 {
+		t.Errorf("Transaction replayed twice: %d in history", len(txs))
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	history := []types.Transaction{
+		{ID: "old", Timestamp: now.Add(-100 * 24 * time.Hour)},
+		{ID: "a", Timestamp: now.Add(-3 * time.Hour)},
+		{ID: "b", Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "c", Timestamp: now.Add(-1 * time.Hour)},
+	}
+
+	cfg := &BackendConfig{KeepTransactions: 2, MaxAge: "90d"}
+	r, err := cfg.Retention()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MaxAge != 90*24*time.Hour {
+		t.Errorf("max_age 90d parsed as %s", r.MaxAge)
+	}
+
+	kept := r.apply(history, now)
+	if len(kept) != 2 || kept[0].ID != "b" || kept[1].ID != "c" {
+		t.Errorf("Unexpected history after retention: %+v", kept)
+	}
+	if len(history) != 4 || history[0].ID != "old" {
+		t.Error("Retention modified the input history")
+	}
+
+	if kept := (Retention{MaxAge: 150 * time.Minute}).apply(history, now); len(kept) != 2 {
+		t.Errorf("Expected 2 transactions newer than max_age, got %d", len(kept))
+	}
+
+	for _, bad := range []*BackendConfig{{MaxAge: "soon"}, {MaxAge: "-1h"}, {KeepTransactions: -1}} {
+		if _, err := bad.Retention(); err == nil {
+			t.Errorf("Expected an error for %+v", bad)
+		}
+	}
+}
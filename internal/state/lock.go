@@ -0,0 +1,143 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// LockSuffix is appended to the state file path to name its lock file.
+const LockSuffix = ".lock"
+
+const lockPollInterval = 500 * time.Millisecond
+
+// LockInfo is the content of a lock file: who holds the state and since when.
+type LockInfo struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	User    string    `json:"user,omitempty"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+func (i LockInfo) String() string {
+	return fmt.Sprintf("'%s' (pid %d on %s, since %s)", i.Command, i.PID, i.Host, i.Started.Local().Format("2006-01-02 15:04:05"))
+}
+
+// Stale reports whether the lock holder ran on this host and is gone.
+// Locks taken on other hosts can't be checked and are never reported stale.
+func (i LockInfo) Stale() bool {
+	host, _ := os.Hostname()
+	if i.Host != host || i.PID <= 0 {
+		return false
+	}
+	// Signal 0 only checks that the process exists
+	err := syscall.Kill(i.PID, 0)
+	return errors.Is(err, syscall.ESRCH)
+}
+
+// LockedError is returned when another process holds the state lock.
+type LockedError struct {
+	Path string
+	Info LockInfo
+}
+
+func (e *LockedError) Error() string {
+	msg := fmt.Sprintf("state is locked by %s", e.Info)
+	if e.Info.Stale() {
+		msg += "; that process is gone, run 'veto unlock' to clear the stale lock"
+	}
+	return msg
+}
+
+// ExclusiveCreator is implemented by filesystems that can create a file only if it
+// does not exist yet (O_EXCL), which makes taking a file lock atomic.
+type ExclusiveCreator interface {
+	CreateExclusive(name string, data []byte, perm os.FileMode) error
+}
+
+// Lock is a held state lock.
+type Lock struct {
+	Info    LockInfo
+	backend Backend
+	manager *Manager
+}
+
+// Lock takes the cross-process state lock for command, waiting up to timeout for
+// the current holder to release it. The state is reloaded once the lock is held,
+// since another process may have changed it.
+func (m *Manager) Lock(command string, timeout time.Duration) (*Lock, error) {
+	host, _ := os.Hostname()
+	info := LockInfo{
+		PID:     os.Getpid(),
+		Host:    host,
+		User:    os.Getenv("USER"),
+		Command: command,
+		Started: time.Now(),
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := m.Backend.Lock(info)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock state (%s): %w", m.Backend, err)
+		}
+		if time.Now().After(deadline) {
+			holder, _ := m.Backend.ReadLock()
+			return nil, &LockedError{Path: m.Backend.String(), Info: holder}
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	lock := &Lock{Info: info, backend: m.Backend, manager: m}
+	if err := m.loadExisting(); err != nil {
+		lock.Unlock()
+		return nil, fmt.Errorf("failed to read state from %s: %w", m.Backend, err)
+	}
+	return lock, nil
+}
+
+// Unlock compacts the journal written under the lock and releases the lock, unless
+// it was forcibly taken over in the meantime. The lock is released even if the
+// compaction fails; the journal is then replayed on the next load.
+func (l *Lock) Unlock() error {
+	current, err := l.backend.ReadLock()
+	if err != nil {
+		return err
+	}
+	if current.PID != l.Info.PID || current.Host != l.Info.Host || !current.Started.Equal(l.Info.Started) {
+		return fmt.Errorf("lock was taken over by %s", current)
+	}
+
+	var compactErr error
+	if l.manager != nil {
+		compactErr = l.manager.Compact()
+	}
+	if err := l.backend.Unlock(); err != nil {
+		return err
+	}
+	if compactErr != nil {
+		return fmt.Errorf("failed to compact state journal: %w", compactErr)
+	}
+	return nil
+}
+
+// ReadLock returns the current holder of the state lock.
+func (m *Manager) ReadLock() (LockInfo, error) {
+	return m.Backend.ReadLock()
+}
+
+// ForceUnlock removes the lock regardless of its holder and returns who held it.
+func (m *Manager) ForceUnlock() (LockInfo, error) {
+	info, err := m.Backend.ReadLock()
+	if errors.Is(err, os.ErrNotExist) {
+		return info, err
+	}
+	// An unreadable lock is removed as well
+	return info, m.Backend.Unlock()
+}
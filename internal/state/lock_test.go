@@ -0,0 +1,112 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// lockFS adds the removal and O_EXCL creation the lock relies on.
+type lockFS struct{ MockRealFS }
+
+func (fs *lockFS) Remove(name string) error { return os.Remove(name) }
+
+func (fs *lockFS) CreateExclusive(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+func TestStateLock(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	first, _ := NewManager(stateFile, &lockFS{})
+	second, _ := NewManager(stateFile, &lockFS{})
+
+	lock, err := first.Lock("veto apply", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = second.Lock("veto rollback", 600*time.Millisecond)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Expected LockedError, got %v", err)
+	}
+	if locked.Info.Command != "veto apply" || locked.Info.PID != os.Getpid() {
+		t.Errorf("Unexpected lock holder: %+v", locked.Info)
+	}
+	if locked.Info.Stale() {
+		t.Error("Lock of a running process reported stale")
+	}
+
+	// The second manager waits for the first one to finish
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		lock.Unlock()
+	}()
+	lock2, err := second.Lock("veto rollback", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Lock not released: %v", err)
+	}
+
+	if _, err := first.ForceUnlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock2.Unlock(); err == nil {
+		t.Error("Unlock should fail after the lock was removed")
+	}
+}
+
+// TestLockReloadsRemovals checks that a removal by another process survives the reload
+// of a manager that loaded the state before it.
+func TestLockReloadsRemovals(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	a, _ := NewManager(stateFile, &lockFS{})
+	if err := a.UpdateResource("package", "vim", "present", "success"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewManager(stateFile, &lockFS{})
+	if _, ok := b.Resource("package:vim"); !ok {
+		t.Fatal("Resource not loaded")
+	}
+
+	lock, err := a.Lock("veto state rm", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.RemoveResource("package:vim"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+
+	lock, err = b.Lock("veto apply", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+	if _, ok := b.Resource("package:vim"); ok {
+		t.Error("Resource removed by another process came back on reload")
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	host, _ := os.Hostname()
+	if !(LockInfo{PID: 1 << 30, Host: host}).Stale() {
+		t.Error("Lock of a dead process should be stale")
+	}
+	if (LockInfo{PID: 1 << 30, Host: "elsewhere.example.com"}).Stale() {
+		t.Error("Locks from other hosts can't be known stale")
+	}
+}
@@ -2,9 +2,9 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
@@ -22,75 +22,128 @@ type FileSystem interface {
 // Manager manages reading/writing the state file.
 // It uses a Mutex for thread-safety.
 type Manager struct {
-	FilePath string
-	Current  *types.State
-	FS       FileSystem
-	mu       sync.RWMutex
+	Backend      Backend
+	Current      *types.State
+	Retention    Retention // Applied to the history on compaction
+	LoadedSchema int       // Schema of the snapshot as read, before migrations
+	mu           sync.RWMutex
+	journaled    int    // Journal records not yet compacted into the snapshot
+	premigration []byte // Snapshot as read, backed up before the migrated state is saved
 }
 
-// NewManager creates a new state manager and loads the existing file.
+// NewManager creates a new state manager for a state file and loads it.
 func NewManager(path string, fs FileSystem) (*Manager, error) {
+	return NewManagerWithBackend(&FileBackend{Path: path, FS: fs})
+}
+
+// NewManagerWithBackend creates a new state manager on a backend and loads the existing state.
+func NewManagerWithBackend(backend Backend) (*Manager, error) {
 	mgr := &Manager{
-		FilePath: path,
-		Current:  types.NewState(),
-		FS:       fs,
+		Backend: backend,
+		Current: types.NewState(),
 	}
 
-	// Load existing file if present
-	if err := mgr.Load(); err != nil {
-		// If file doesn't exist, it's fine, we'll create it.
-		// Since we use abstracted FS, we check for specific error or just ignore 'not exist' logic if hidden.
-		// os.IsNotExist works with OS errors, but FS implementation should return compatible errors.
-		// For now we assume the error returned by ReadFile satisfies os.IsNotExist or we check message.
-		if !os.IsNotExist(err) && err.Error() != "file does not exist" {
-			// Some Sftp implementations might return generic error.
-			// But core.Transport.ReadFile usually wraps/returns underlying error.
-			// Let's assume ignore for now if it fails on load, start fresh.
-			// Ideally investigate error type.
-		}
+	if err := mgr.loadExisting(); err != nil {
+		return nil, fmt.Errorf("failed to read state from %s: %w", backend, err)
 	}
-
 	return mgr, nil
 }
 
-// Load reads state file from abstract FS.
+// loadExisting loads the saved state. A missing state is a fresh start and an
+// undecodable one is ignored, but a backend that can't be read is an error: saving
+// over it would lose the real state.
+func (m *Manager) loadExisting() error {
+	err := m.Load()
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if err == nil || errors.Is(err, os.ErrNotExist) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return nil
+	}
+	return err
+}
+
+// Load reads the state snapshot from the backend and replays its journal.
 func (m *Manager) Load() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	data, err := m.FS.ReadFile(m.FilePath)
+	data, readErr := m.Backend.Read()
+	if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+		return readErr
+	}
+	// Start over: decoding into the old state would keep entries removed since
+	m.Current = types.NewState()
+	m.LoadedSchema = types.StateSchemaVersion
+	if readErr == nil {
+		migrated, from, err := migrate(data)
+		if err != nil {
+			return err
+		}
+		if from < types.StateSchemaVersion {
+			m.LoadedSchema = from
+			m.premigration = data
+		}
+		if err := json.Unmarshal(migrated, m.Current); err != nil {
+			return err
+		}
+	}
+
+	n, err := m.replayJournal()
 	if err != nil {
 		return err
 	}
-
-	return json.Unmarshal(data, m.Current)
+	m.journaled = n
+	if readErr != nil && n == 0 {
+		return readErr
+	}
+	return nil
 }
 
-// Save writes current state to abstract FS.
+// Save writes current state to the backend as a new snapshot, folding in the journal.
+// A state migrated on load is backed up first.
 func (m *Manager) Save() error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.save()
+}
 
-	m.Current.LastRun = time.Now()
+// save writes the snapshot and clears the journal. The caller holds m.mu.
+func (m *Manager) save() error {
+	now := time.Now()
+	m.Current.Version = types.StateSchemaVersion
+	m.Current.LastRun = now
+	m.Current.History = m.Retention.apply(m.Current.History, now)
 
 	data, err := json.MarshalIndent(m.Current, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(m.FilePath)
-	if err := m.FS.MkdirAll(dir, 0755); err != nil {
+	// Keep the state as an older veto wrote it before replacing it
+	if m.premigration != nil {
+		if err := m.Backend.WriteBackup(migrationBackupSuffix(m.LoadedSchema), m.premigration); err != nil {
+			return fmt.Errorf("failed to back up state before migration: %w", err)
+		}
+		m.premigration = nil
+	}
+	if err := m.Backend.Write(data); err != nil {
 		return err
 	}
 
-	return m.FS.WriteFile(m.FilePath, data, 0644)
+	if j, ok := m.Backend.(JournalBackend); ok && m.journaled > 0 {
+		if err := j.ClearJournal(); err != nil {
+			return err
+		}
+	}
+	m.journaled = 0
+	return nil
 }
 
-// UpdateResource updates a specific resource state and saves it.
+// UpdateResource updates a specific resource state and records it.
 func (m *Manager) UpdateResource(resType, name, targetState, status string) error {
 	m.mu.Lock()
-	id := fmt.Sprintf("%s:%s", resType, name)
+	defer m.mu.Unlock()
+	id := ResourceID(resType, name)
 
 	entry := types.ResourceEntry{
 		ID:          id,
@@ -102,8 +155,8 @@ func (m *Manager) UpdateResource(resType, name, targetState, status string) erro
 		Metadata:    make(map[string]interface{}),
 	}
 	m.Current.Resources[id] = entry
-	m.mu.Unlock()
+	m.Current.LastRun = entry.LastApplied
 
-	// Safe to save on every update
-	return m.Save()
+	// Journaled as one small record instead of rewriting the state
+	return m.record(journalRecord{Time: entry.LastApplied, Resource: &entry})
 }
@@ -0,0 +1,143 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+// ResourceID returns the state ID of a resource.
+func ResourceID(resType, name string) string {
+	return fmt.Sprintf("%s:%s", resType, name)
+}
+
+// ParseResourceID splits a state ID into resource type and name.
+func ParseResourceID(id string) (string, string, error) {
+	resType, name, ok := strings.Cut(id, ":")
+	if !ok || resType == "" || name == "" {
+		return "", "", fmt.Errorf("invalid resource ID '%s' (expected type:name)", id)
+	}
+	return resType, name, nil
+}
+
+// Resources returns the managed resources sorted by ID.
+func (m *Manager) Resources() []types.ResourceEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]types.ResourceEntry, 0, len(m.Current.Resources))
+	for _, e := range m.Current.Resources {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Resource returns the entry with the given ID.
+func (m *Manager) Resource(id string) (types.ResourceEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.Current.Resources[id]
+	return e, ok
+}
+
+// RemoveResource stops tracking a resource without touching the system.
+func (m *Manager) RemoveResource(id string) (types.ResourceEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.Current.Resources[id]
+	if !ok {
+		return entry, fmt.Errorf("resource '%s' is not in the state", id)
+	}
+	delete(m.Current.Resources, id)
+
+	tx := m.stateTransaction(types.TransactionChange{
+		Type:   entry.Type,
+		Name:   entry.Name,
+		Action: types.ActionStateRemove,
+	})
+	return entry, m.record(journalRecord{Time: tx.Timestamp, Removed: id, Transaction: &tx})
+}
+
+// MoveResource renames the entry from to the ID to, e.g. after a resource was
+// renamed in the config, so it isn't orphaned.
+func (m *Manager) MoveResource(from, to string) (types.ResourceEntry, error) {
+	resType, name, err := ParseResourceID(to)
+	if err != nil {
+		return types.ResourceEntry{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.Current.Resources[from]
+	if !ok {
+		return entry, fmt.Errorf("resource '%s' is not in the state", from)
+	}
+	if _, exists := m.Current.Resources[to]; exists {
+		return entry, fmt.Errorf("resource '%s' is already in the state", to)
+	}
+
+	delete(m.Current.Resources, from)
+	entry.ID, entry.Type, entry.Name = to, resType, name
+	m.Current.Resources[to] = entry
+
+	tx := m.stateTransaction(types.TransactionChange{
+		Type:   resType,
+		Name:   name,
+		Action: types.ActionStateMove,
+		Target: from,
+	})
+	return entry, m.record(journalRecord{Time: tx.Timestamp, Removed: from, Resource: &entry, Transaction: &tx})
+}
+
+// ImportResource starts tracking an existing system object as managed.
+func (m *Manager) ImportResource(resType, name, targetState string, metadata map[string]interface{}) (types.ResourceEntry, error) {
+	id := ResourceID(resType, name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.Current.Resources[id]; exists {
+		return types.ResourceEntry{}, fmt.Errorf("resource '%s' is already in the state", id)
+	}
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	entry := types.ResourceEntry{
+		ID:          id,
+		Name:        name,
+		Type:        resType,
+		State:       targetState,
+		Status:      "success",
+		LastApplied: time.Now(),
+		Metadata:    metadata,
+	}
+	m.Current.Resources[id] = entry
+
+	tx := m.stateTransaction(types.TransactionChange{
+		Type:   resType,
+		Name:   name,
+		Action: types.ActionStateImport,
+	})
+	return entry, m.record(journalRecord{Time: tx.Timestamp, Resource: &entry, Transaction: &tx})
+}
+
+// stateTransaction appends a transaction for a 'veto state' change to the history.
+// The caller holds m.mu and records it.
+func (m *Manager) stateTransaction(change types.TransactionChange) types.Transaction {
+	tx := types.Transaction{
+		ID:        uuid.New().String(),
+		Timestamp: time.Now(),
+		Status:    "success",
+		Changes:   []types.TransactionChange{change},
+	}
+	m.Current.History = append(m.Current.History, tx)
+	return tx
+}
@@ -0,0 +1,76 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+func TestStateSurgery(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	mgr, err := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.UpdateResource("file", "/etc/old.conf", "present", "success")
+	mgr.UpdateResource("pkg", "vim", "present", "success")
+
+	if _, err := mgr.MoveResource("file:/etc/old.conf", "file:/etc/new.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.MoveResource("pkg:vim", "file:/etc/new.conf"); err == nil {
+		t.Error("Moving onto an existing entry should fail")
+	}
+	if _, err := mgr.RemoveResource("pkg:vim"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.RemoveResource("pkg:vim"); err == nil {
+		t.Error("Removing a missing entry should fail")
+	}
+	if _, err := mgr.ImportResource("service", "nginx", "running", map[string]interface{}{"imported": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replayed from the journal by a fresh manager
+	mgr2, err := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range mgr2.Resources() {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) != 2 || ids[0] != "file:/etc/new.conf" || ids[1] != "service:nginx" {
+		t.Errorf("Unexpected resources after surgery: %v", ids)
+	}
+	if e, _ := mgr2.Resource("file:/etc/new.conf"); e.Name != "/etc/new.conf" || e.Type != "file" {
+		t.Errorf("Moved entry not renamed: %+v", e)
+	}
+
+	var actions []string
+	for _, tx := range mgr2.GetTransactions() {
+		actions = append(actions, tx.Changes[0].Action)
+	}
+	want := []string{types.ActionStateMove, types.ActionStateRemove, types.ActionStateImport}
+	if len(actions) != len(want) {
+		t.Fatalf("Expected transactions %v, got %v", want, actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("Transaction %d is %s, want %s", i, actions[i], want[i])
+		}
+	}
+}
+
+func TestParseResourceID(t *testing.T) {
+	resType, name, err := ParseResourceID("file:/etc/a:b")
+	if err != nil || resType != "file" || name != "/etc/a:b" {
+		t.Errorf("Unexpected parse: %s %s %v", resType, name, err)
+	}
+	for _, bad := range []string{"vim", ":vim", "pkg:"} {
+		if _, _, err := ParseResourceID(bad); err == nil {
+			t.Errorf("Expected an error for '%s'", bad)
+		}
+	}
+}
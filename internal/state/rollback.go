@@ -0,0 +1,71 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+// FindTransaction returns the transaction whose ID is id or starts with it, and its
+// index in the history. A prefix matching several transactions is an error.
+func (m *Manager) FindTransaction(id string) (int, types.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	found := -1
+	for i, tx := range m.Current.History {
+		if tx.ID == id {
+			return i, tx, nil
+		}
+		if id != "" && strings.HasPrefix(tx.ID, id) {
+			if found >= 0 {
+				return -1, types.Transaction{}, fmt.Errorf("transaction ID '%s' is ambiguous", id)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return -1, types.Transaction{}, fmt.Errorf("transaction not found: %s", id)
+	}
+	return found, m.Current.History[found], nil
+}
+
+// RecordRollback adds rollback transaction tx to the history. The transactions in
+// reverted were undone completely and are marked "reverted"; the resources tx
+// reverted are marked "rolled-back".
+func (m *Manager) RecordRollback(tx types.Transaction, reverted []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range reverted {
+		for i := range m.Current.History {
+			if m.Current.History[i].ID != id {
+				continue
+			}
+			m.Current.History[i].Status = "reverted"
+			old := m.Current.History[i]
+			if err := m.record(journalRecord{Time: now, Transaction: &old}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range tx.Changes {
+		entry, ok := m.Current.Resources[ResourceID(c.Type, c.Name)]
+		if !ok {
+			continue
+		}
+		entry.Status = "rolled-back"
+		entry.LastApplied = now
+		m.Current.Resources[entry.ID] = entry
+		if err := m.record(journalRecord{Time: now, Resource: &entry}); err != nil {
+			return err
+		}
+	}
+
+	m.Current.History = append(m.Current.History, tx)
+	return m.record(journalRecord{Time: now, Transaction: &tx})
+}
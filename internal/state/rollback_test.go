@@ -0,0 +1,47 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+func TestRecordRollback(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	mgr, _ := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	mgr.UpdateResource("pkg", "vim", "present", "success")
+	mgr.AddTransaction(types.Transaction{ID: "aa11", Status: "success"})
+	mgr.AddTransaction(types.Transaction{ID: "ab22", Status: "success"})
+
+	if _, _, err := mgr.FindTransaction("a"); err == nil {
+		t.Error("Expected an error for an ambiguous prefix")
+	}
+	i, tx, err := mgr.FindTransaction("ab")
+	if err != nil || i != 1 || tx.ID != "ab22" {
+		t.Fatalf("FindTransaction(ab) = %d, %+v, %v", i, tx, err)
+	}
+
+	rollback := types.Transaction{
+		ID:      "rb",
+		Status:  "success",
+		Changes: []types.TransactionChange{{Type: "pkg", Name: "vim", Action: types.ActionReverted}},
+		Reverts: []string{"ab22"},
+	}
+	if err := mgr.RecordRollback(rollback, []string{"ab22"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replayed from the journal
+	mgr2, err := NewManagerWithBackend(&FileBackend{Path: stateFile, FS: osFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := mgr2.GetTransactions()
+	if len(txs) != 3 || txs[1].Status != "reverted" || txs[2].ID != "rb" || txs[2].Reverts[0] != "ab22" {
+		t.Errorf("Unexpected history: %+v", txs)
+	}
+	if e, _ := mgr2.Resource("pkg:vim"); e.Status != "rolled-back" {
+		t.Errorf("Resource status = %s, want rolled-back", e.Status)
+	}
+}
@@ -0,0 +1,264 @@
+package state
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const s3Timeout = 30 * time.Second
+
+// S3Backend keeps the state as an object in an S3-compatible bucket (AWS S3, MinIO,
+// Ceph RGW, ...). Requests are signed with AWS Signature Version 4. The lock is a
+// second object written with a conditional PUT (If-None-Match: *), so two writers
+// can't both create it.
+type S3Backend struct {
+	Endpoint     string // e.g. https://s3.eu-central-1.amazonaws.com
+	Bucket       string
+	Key          string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	PathStyle    bool
+	Client       *http.Client
+
+	now func() time.Time // For tests
+}
+
+// NewS3Backend creates an S3 backend for the object key. Credentials and region
+// default to the usual AWS environment variables.
+func NewS3Backend(cfg *BackendConfig, key string) (*S3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 state backend: 'bucket' is required")
+	}
+	if key == "" {
+		return nil, errors.New("s3 state backend: 'key' is required")
+	}
+
+	b := &S3Backend{
+		Endpoint:     cfg.Endpoint,
+		Bucket:       cfg.Bucket,
+		Key:          strings.TrimPrefix(key, "/"),
+		Region:       firstNonEmpty(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		AccessKey:    firstNonEmpty(cfg.AccessKey, os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey:    firstNonEmpty(cfg.SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		PathStyle:    cfg.PathStyle,
+		Client:       &http.Client{Timeout: s3Timeout},
+	}
+	if b.Endpoint == "" {
+		b.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", b.Region)
+	}
+	if b.AccessKey == "" || b.SecretKey == "" {
+		return nil, errors.New("s3 state backend: no credentials (set access_key/secret_key or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)")
+	}
+	return b, nil
+}
+
+func (b *S3Backend) String() string {
+	return fmt.Sprintf("s3://%s/%s", b.Bucket, b.Key)
+}
+
+func (b *S3Backend) Read() ([]byte, error) {
+	return b.get(b.Key)
+}
+
+func (b *S3Backend) Write(data []byte) error {
+	resp, err := b.do(http.MethodPut, b.Key, data, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp, "PUT", b.Key)
+}
+
+func (b *S3Backend) WriteBackup(suffix string, data []byte) error {
+	resp, err := b.do(http.MethodPut, b.Key+suffix, data, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp, "PUT", b.Key+suffix)
+}
+
+func (b *S3Backend) Lock(info LockInfo) error {
+	data, err := jsonLock(info)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(http.MethodPut, b.lockKey(), data, http.Header{"If-None-Match": {"*"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%s: %w", b.lockKey(), os.ErrExist)
+	}
+	return s3Error(resp, "PUT", b.lockKey())
+}
+
+func (b *S3Backend) ReadLock() (LockInfo, error) {
+	data, err := b.get(b.lockKey())
+	if err != nil {
+		return LockInfo{}, err
+	}
+	return parseLock(b.lockKey(), data)
+}
+
+func (b *S3Backend) Unlock() error {
+	resp, err := b.do(http.MethodDelete, b.lockKey(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp, "DELETE", b.lockKey())
+}
+
+func (b *S3Backend) lockKey() string { return b.Key + LockSuffix }
+
+func (b *S3Backend) get(key string) ([]byte, error) {
+	resp, err := b.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("s3://%s/%s: %w", b.Bucket, key, os.ErrNotExist)
+	}
+	if err := s3Error(resp, "GET", key); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// do sends a signed request for an object.
+func (b *S3Backend) do(method, key string, body []byte, header http.Header) (*http.Response, error) {
+	endpoint, err := url.Parse(b.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	u := *endpoint
+	if b.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.Bucket + "/" + key
+	} else {
+		u.Host = b.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = s3Escape(u.Path)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	b.sign(req, body)
+	return b.Client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header.
+func (b *S3Backend) sign(req *http.Request, body []byte) {
+	now := time.Now
+	if b.now != nil {
+		now = b.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.SessionToken)
+	}
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if b.SessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range signed {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + b.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+b.SecretKey), day)
+	key = hmacSHA256(key, b.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKey, scope, signedHeaders, signature))
+}
+
+func s3Error(resp *http.Response, method, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// s3Escape percent-encodes a path the way SigV4 expects: everything except
+// unreserved characters and '/'.
+func s3Escape(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+// Migration upgrades a decoded state document from schema From to From+1.
+// It works on the raw JSON document, so it can handle fields whose type changed.
+// The version field is updated after Migrate; a nil Migrate only bumps it.
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(doc map[string]interface{}) error
+}
+
+var migrations = make(map[int]Migration)
+
+// RegisterMigration registers the migration from schema m.From to m.From+1.
+func RegisterMigration(m Migration) {
+	if _, exists := migrations[m.From]; exists {
+		panic(fmt.Sprintf("state: migration from schema v%d registered twice", m.From))
+	}
+	migrations[m.From] = m
+}
+
+func init() {
+	RegisterMigration(Migration{
+		From:        1,
+		Description: `schema version is stored as an integer instead of "1.0"`,
+	})
+}
+
+// NewerSchemaError is returned for a state written by a newer veto than this binary.
+type NewerSchemaError struct {
+	Version int
+}
+
+func (e *NewerSchemaError) Error() string {
+	return fmt.Sprintf("state uses schema v%d but this veto only supports up to v%d; upgrade veto before using this state",
+		e.Version, types.StateSchemaVersion)
+}
+
+// schemaVersion returns the schema of a state document. Version 1 wrote "1.0".
+func schemaVersion(doc map[string]interface{}) (int, error) {
+	switch v := doc["version"].(type) {
+	case nil:
+		return 1, nil
+	case float64:
+		return int(v), nil
+	case string:
+		if v == "1.0" || v == "" {
+			return 1, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("unknown state schema version '%s'", v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("unknown state schema version %v", v)
+	}
+}
+
+// PendingMigrations returns the migrations needed to bring a state document of
+// schema from up to the current schema, in order.
+func PendingMigrations(from int) ([]Migration, error) {
+	var pending []Migration
+	for v := from; v < types.StateSchemaVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration registered from state schema v%d", v)
+		}
+		pending = append(pending, m)
+	}
+	return pending, nil
+}
+
+// migrate upgrades a serialized state to the current schema and returns it with
+// the schema it was written in.
+func migrate(data []byte) ([]byte, int, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+	from, err := schemaVersion(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from > types.StateSchemaVersion {
+		return nil, from, &NewerSchemaError{Version: from}
+	}
+	if from == types.StateSchemaVersion {
+		return data, from, nil
+	}
+
+	pending, err := PendingMigrations(from)
+	if err != nil {
+		return nil, from, err
+	}
+	for _, m := range pending {
+		if m.Migrate != nil {
+			if err := m.Migrate(doc); err != nil {
+				return nil, from, fmt.Errorf("state migration v%d -> v%d failed: %w", m.From, m.From+1, err)
+			}
+		}
+		doc["version"] = m.From + 1
+	}
+
+	out, err := json.Marshal(doc)
+	return out, from, err
+}
+
+// MigrationBackup returns where the state as it was before migration is saved, or
+// "" when the loaded state needed no migration.
+func (m *Manager) MigrationBackup() string {
+	if m.premigration == nil {
+		return ""
+	}
+	return m.Backend.String() + migrationBackupSuffix(m.LoadedSchema)
+}
+
+func migrationBackupSuffix(version int) string {
+	return fmt.Sprintf(".v%d.bak", version)
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/types"
+)
+
+func TestStateMigration(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	v1 := `{"version":"1.0","resources":{"pkg:vim":{"id":"pkg:vim","name":"vim","type":"pkg","status":"success"}},"history":[{"id":"tx1","status":"success"}]}`
+	os.WriteFile(stateFile, []byte(v1), 0644)
+
+	mgr, err := NewManager(stateFile, &MockRealFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mgr.LoadedSchema != 1 {
+		t.Errorf("Loaded schema v%d, want v1", mgr.LoadedSchema)
+	}
+	if len(mgr.Current.Resources) != 1 || len(mgr.GetTransactions()) != 1 {
+		t.Fatalf("Migrated state lost data: %+v", mgr.Current)
+	}
+	if mgr.MigrationBackup() != stateFile+".v1.bak" {
+		t.Errorf("Unexpected migration backup %s", mgr.MigrationBackup())
+	}
+
+	if err := mgr.Save(); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := os.ReadFile(stateFile + ".v1.bak")
+	if err != nil || string(backup) != v1 {
+		t.Errorf("Pre-migration state not backed up: %v", err)
+	}
+
+	mgr2, err := NewManager(stateFile, &MockRealFS{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mgr2.LoadedSchema != types.StateSchemaVersion || mgr2.Current.Version != types.StateSchemaVersion {
+		t.Errorf("Saved state not in schema v%d: %d", types.StateSchemaVersion, mgr2.Current.Version)
+	}
+	if mgr2.MigrationBackup() != "" {
+		t.Error("Current state should need no migration")
+	}
+}
+
+func TestNewerStateRefused(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(stateFile, []byte(`{"version":99,"resources":{}}`), 0644)
+
+	_, err := NewManager(stateFile, &MockRealFS{})
+	var newer *NewerSchemaError
+	if !errors.As(err, &newer) || newer.Version != 99 {
+		t.Fatalf("Expected NewerSchemaError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "upgrade veto") {
+		t.Errorf("Error should tell to upgrade: %v", err)
+	}
+
+	if _, err := PendingMigrations(0); err == nil {
+		t.Error("Expected an error for a schema without registered migration")
+	}
+}
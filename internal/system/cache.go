@@ -0,0 +1,231 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/core"
+)
+
+// DefaultFactsTTL is how long cached facts are trusted before a full detection.
+const DefaultFactsTTL = time.Hour
+
+// Fact groups filled by Detect. Freshness is tracked per group.
+const (
+	FactOS       = "os"
+	FactKernel   = "kernel"
+	FactHostname = "hostname"
+	FactUser     = "user"
+	FactHardware = "hardware"
+	FactEnv      = "env"
+	FactFS       = "fs"
+	FactStorage  = "storage"
+	FactNetwork  = "network"
+	FactPlatform = "platform" // Virtualization, container, power
+	FactCustom   = "custom"
+)
+
+// FactGroups lists every fact group recorded in SystemContext.FactTimes.
+var FactGroups = []string{FactOS, FactKernel, FactHostname, FactUser, FactHardware, FactEnv, FactFS,
+	FactStorage, FactNetwork, FactPlatform, FactCustom}
+
+// FactCache stores detected facts under .veto/facts/<host>.json so repeated runs
+// (watch ticks, fleet hosts over SSH) skip the full detection.
+// A nil *FactCache always runs a full detection.
+type FactCache struct {
+	Dir     string
+	TTL     time.Duration
+	Refresh bool // Ignore cached entries and re-detect (--refresh-facts)
+}
+
+// cachedFacts is the on-disk representation of a host's facts.
+type cachedFacts struct {
+	Host       string               `json:"host"`
+	OS         string               `json:"os"`
+	Kernel     string               `json:"kernel"`
+	Distro     string               `json:"distro"`
+	Family     string               `json:"family"`
+	Version    string               `json:"version"`
+	InitSystem string               `json:"init_system"`
+	Hostname   string               `json:"hostname"`
+	Hardware   core.SystemHardware  `json:"hardware"`
+	Env        core.SystemEnv       `json:"env"`
+	FSInfo     core.SystemFS        `json:"fs"`
+	Storage    core.SystemStorage   `json:"storage"`
+	Network    core.SystemNetwork   `json:"network"`
+	Virt       string               `json:"virtualization"`
+	Container  string               `json:"container"`
+	User       string               `json:"user"`
+	HomeDir    string               `json:"home_dir"`
+	UID        string               `json:"uid"`
+	GID        string               `json:"gid"`
+	FactTimes  map[string]time.Time `json:"fact_times"`
+}
+
+// NewFactCache creates a cache in the default facts directory.
+func NewFactCache(ttl time.Duration, refresh bool) *FactCache {
+	return &FactCache{
+		Dir:     consts.GetFactsDir(),
+		TTL:     ttl,
+		Refresh: refresh,
+	}
+}
+
+// Detect fills ctx from the cache when the entry for host is fresh, and runs a
+// full Detect (updating the cache) otherwise. Hostname and kernel are always
+// probed; a change in either invalidates the entry. An empty host keys the
+// entry by the probed hostname. Custom facts (facts.d) are never cached, so a
+// broken one is reported on every run. Returns true if the cache was used.
+func (c *FactCache) Detect(ctx *core.SystemContext, host string) bool {
+	if c == nil {
+		Detect(ctx)
+		return false
+	}
+
+	if ctx.FS == nil && ctx.Transport != nil {
+		ctx.FS = ctx.Transport.GetFileSystem()
+	}
+
+	execCmd := commandRunner(ctx)
+	hostnameOut, _ := execCmd("hostname")
+	hostname := strings.TrimSpace(hostnameOut)
+	kernel := detectKernel(ctx, execCmd)
+	probedAt := time.Now()
+
+	if host == "" {
+		host = hostname
+	}
+
+	if !c.Refresh {
+		if entry, err := c.load(host); err == nil && c.valid(entry, hostname, kernel) {
+			entry.apply(ctx)
+			ctx.Facts, ctx.FactErrors = LoadCustomFacts(ctx, CustomFactDirs())
+			ctx.FactTimes[FactHostname] = probedAt
+			ctx.FactTimes[FactKernel] = probedAt
+			ctx.FactTimes[FactCustom] = time.Now()
+			return true
+		}
+	}
+
+	Detect(ctx)
+	if err := c.save(host, ctx); err != nil && ctx.Logger != nil {
+		ctx.Logger.Debug(fmt.Sprintf("Failed to write fact cache: %v", err))
+	}
+	return false
+}
+
+// Invalidate removes the cached entry for host.
+func (c *FactCache) Invalidate(host string) error {
+	err := os.Remove(c.path(host))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *FactCache) valid(entry *cachedFacts, hostname, kernel string) bool {
+	if entry.Hostname != hostname || entry.Kernel != kernel {
+		return false
+	}
+	collected, ok := entry.FactTimes[FactHardware]
+	if !ok {
+		return false
+	}
+	return c.TTL > 0 && time.Since(collected) < c.TTL
+}
+
+// hostFileRe restricts cache file names to safe characters.
+var hostFileRe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func (c *FactCache) path(host string) string {
+	name := hostFileRe.ReplaceAllString(host, "_")
+	if name == "" {
+		name = "localhost"
+	}
+	return filepath.Join(c.Dir, name+".json")
+}
+
+func (c *FactCache) load(host string) (*cachedFacts, error) {
+	data, err := os.ReadFile(c.path(host))
+	if err != nil {
+		return nil, err
+	}
+	var entry cachedFacts
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *FactCache) save(host string, ctx *core.SystemContext) error {
+	entry := cachedFacts{
+		Host:       host,
+		OS:         ctx.OS,
+		Kernel:     ctx.Kernel,
+		Distro:     ctx.Distro,
+		Family:     ctx.Family,
+		Version:    ctx.Version,
+		InitSystem: ctx.InitSystem,
+		Hostname:   ctx.Hostname,
+		Hardware:   ctx.Hardware,
+		Env:        ctx.Env,
+		FSInfo:     ctx.FSInfo,
+		Storage:    ctx.Storage,
+		Network:    ctx.Network,
+		Virt:       ctx.Virtualization,
+		Container:  ctx.Container,
+		User:       ctx.User,
+		HomeDir:    ctx.HomeDir,
+		UID:        ctx.UID,
+		GID:        ctx.GID,
+		FactTimes:  ctx.FactTimes,
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a concurrent reader never sees a partial entry
+	target := c.path(host)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+func (e *cachedFacts) apply(ctx *core.SystemContext) {
+	ctx.OS = e.OS
+	ctx.Kernel = e.Kernel
+	ctx.Distro = e.Distro
+	ctx.Family = e.Family
+	ctx.Version = e.Version
+	ctx.InitSystem = e.InitSystem
+	ctx.Hostname = e.Hostname
+	ctx.Hardware = e.Hardware
+	ctx.Env = e.Env
+	ctx.FSInfo = e.FSInfo
+	ctx.Storage = e.Storage
+	ctx.Network = e.Network
+	ctx.Virtualization = e.Virt
+	ctx.Container = e.Container
+	ctx.User = e.User
+	ctx.HomeDir = e.HomeDir
+	ctx.UID = e.UID
+	ctx.GID = e.GID
+
+	ctx.FactTimes = make(map[string]time.Time, len(e.FactTimes))
+	for k, v := range e.FactTimes {
+		ctx.FactTimes[k] = v
+	}
+}
@@ -0,0 +1,85 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/transport"
+)
+
+func newCacheTestTransport() *transport.MockTransport {
+	tr := transport.NewMockTransport()
+	tr.AddResponse("hostname", "web1\n")
+	tr.AddResponse("uname -r", "6.1.0\n")
+	tr.AddResponse("id -u -n", "deploy\n")
+	tr.FileContent["/etc/os-release"] = "ID=debian\nVERSION_ID=12\n"
+	return tr
+}
+
+func TestFactCacheDetect(t *testing.T) {
+	cache := &FactCache{Dir: t.TempDir(), TTL: time.Hour}
+	tr := newCacheTestTransport()
+
+	ctx := core.NewSystemContext(false, tr)
+	if cache.Detect(ctx, "web1") {
+		t.Fatal("First detection should not come from the cache")
+	}
+	if _, err := os.Stat(filepath.Join(cache.Dir, "web1.json")); err != nil {
+		t.Fatalf("Cache entry not written: %v", err)
+	}
+
+	// A changed user must not be picked up while the entry is fresh
+	tr.AddResponse("id -u -n", "other\n")
+	ctx = core.NewSystemContext(false, tr)
+	if !cache.Detect(ctx, "web1") {
+		t.Fatal("Expected a cache hit")
+	}
+	if ctx.User != "deploy" || ctx.Distro != "debian" {
+		t.Errorf("Facts not restored from cache: user=%s distro=%s", ctx.User, ctx.Distro)
+	}
+	if _, ok := ctx.FactTimes[FactHardware]; !ok {
+		t.Error("Fact times not restored")
+	}
+	// Custom facts are loaded again on a hit, never taken from the entry
+	if !ctx.FactTimes[FactCustom].After(ctx.FactTimes[FactHardware]) || ctx.FactErrors == nil {
+		t.Error("Custom facts not reloaded on a cache hit")
+	}
+
+	// Kernel upgrade invalidates the entry
+	tr.AddResponse("uname -r", "6.2.0\n")
+	ctx = core.NewSystemContext(false, tr)
+	if cache.Detect(ctx, "web1") {
+		t.Fatal("Kernel change should invalidate the cache")
+	}
+	if ctx.User != "other" || ctx.Kernel != "6.2.0" {
+		t.Errorf("Expected fresh facts, got user=%s kernel=%s", ctx.User, ctx.Kernel)
+	}
+
+	// --refresh-facts bypasses a valid entry
+	cache.Refresh = true
+	if cache.Detect(core.NewSystemContext(false, tr), "web1") {
+		t.Error("Refresh should bypass the cache")
+	}
+}
+
+func TestFactCacheTTL(t *testing.T) {
+	cache := &FactCache{Dir: t.TempDir(), TTL: time.Hour}
+	tr := newCacheTestTransport()
+	cache.Detect(core.NewSystemContext(false, tr), "web1")
+
+	entry, err := cache.load("web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cache.valid(entry, "web1", "6.1.0") {
+		t.Error("Fresh entry should be valid")
+	}
+
+	entry.FactTimes[FactHardware] = time.Now().Add(-2 * time.Hour)
+	if cache.valid(entry, "web1", "6.1.0") {
+		t.Error("Expired entry should be invalid")
+	}
+}
@@ -0,0 +1,212 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/melih-ucgun/veto/internal/consts"
+	"github.com/melih-ucgun/veto/internal/core"
+)
+
+// DefaultCustomFactTimeout bounds how long a single facts.d executable may run.
+const DefaultCustomFactTimeout = 10 * time.Second
+
+// SystemCustomFactsDir is the host-wide facts.d directory. Facts in the
+// project directory (.veto/facts.d) override facts with the same name.
+const SystemCustomFactsDir = "/etc/veto/facts.d"
+
+// factTimeoutRe matches a "veto:timeout=30s" marker in the first lines of an executable.
+var factTimeoutRe = regexp.MustCompile(`veto:timeout=([0-9a-z.]+)`)
+
+// CustomFactDirs returns the facts.d directories scanned on every host, lowest priority first.
+func CustomFactDirs() []string {
+	return []string{SystemCustomFactsDir, consts.GetCustomFactsDir()}
+}
+
+// LoadCustomFacts reads user-defined facts from the given facts.d directories through
+// the context's FileSystem and Transport, so the same code works on remote hosts.
+//
+// Static *.json, *.yaml and *.yml files are parsed as-is. Executables are run and their
+// output is parsed as JSON, then YAML, falling back to the trimmed text. The fact name is
+// the file name without extension. A failing fact never aborts detection: its error is
+// returned in the errors map and the fact is left out.
+func LoadCustomFacts(ctx *core.SystemContext, dirs []string) (map[string]interface{}, map[string]string) {
+	fsys := ctx.FS
+	if fsys == nil {
+		fsys = &core.RealFS{}
+	}
+
+	facts := make(map[string]interface{})
+	errs := make(map[string]string)
+
+	for _, dir := range dirs {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			continue // Missing facts.d is the normal case
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+
+			file := path.Join(dir, entry.Name())
+			ext := path.Ext(entry.Name())
+			name := strings.TrimSuffix(entry.Name(), ext)
+
+			var value interface{}
+			switch {
+			case info.Mode()&0111 != 0:
+				value, err = runCustomFact(ctx, fsys, file)
+			case ext == ".json":
+				value, err = readStaticFact(fsys, file, json.Unmarshal)
+			case ext == ".yaml" || ext == ".yml":
+				value, err = readStaticFact(fsys, file, yaml.Unmarshal)
+			default:
+				continue // Not a fact source (README, backup files...)
+			}
+
+			if err != nil {
+				errs[name] = err.Error()
+				delete(facts, name)
+				if ctx.Logger != nil {
+					ctx.Logger.Warn(fmt.Sprintf("Custom fact '%s' failed: %v", name, err))
+				}
+				continue
+			}
+			delete(errs, name)
+			facts[name] = value
+		}
+	}
+
+	return facts, errs
+}
+
+func readStaticFact(fsys core.FileSystem, file string, unmarshal func([]byte, interface{}) error) (interface{}, error) {
+	data, err := fsys.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return normalizeFact(value), nil
+}
+
+// runCustomFact executes a facts.d executable with its timeout. The command is wrapped
+// in coreutils' timeout so it is also killed on remote hosts; the select guards against
+// transports that ignore the deadline entirely.
+func runCustomFact(ctx *core.SystemContext, fsys core.FileSystem, file string) (interface{}, error) {
+	timeout := customFactTimeout(fsys, file)
+	cmd := fmt.Sprintf("timeout -k 2 %d %s", int(timeout.Seconds()+0.5), shellQuote(file))
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		out, err := commandRunner(ctx)(cmd)
+		done <- result{out, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, fmt.Errorf("%w: %s", res.err, strings.TrimSpace(res.out))
+		}
+		return parseFactOutput(res.out), nil
+	case <-time.After(timeout + 5*time.Second):
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// customFactTimeout reads an optional "veto:timeout=<duration>" marker from the head of the file.
+func customFactTimeout(fsys core.FileSystem, file string) time.Duration {
+	data, err := fsys.ReadFile(file)
+	if err != nil {
+		return DefaultCustomFactTimeout
+	}
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	m := factTimeoutRe.FindSubmatch(data)
+	if m == nil {
+		return DefaultCustomFactTimeout
+	}
+	raw := string(m[1])
+	if !strings.ContainsAny(raw, "smh") {
+		raw += "s" // Bare numbers are seconds
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return DefaultCustomFactTimeout
+	}
+	if d < time.Second {
+		return time.Second
+	}
+	return d
+}
+
+// parseFactOutput interprets executable output as JSON, then YAML mapping/list, then plain text.
+func parseFactOutput(out string) interface{} {
+	trimmed := strings.TrimSpace(out)
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(trimmed), &value); err == nil {
+		return normalizeFact(value)
+	}
+	if err := yaml.Unmarshal([]byte(trimmed), &value); err == nil {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return normalizeFact(value)
+		}
+	}
+	return trimmed
+}
+
+// normalizeFact converts YAML's map[interface{}]interface{} leftovers so templates
+// and expressions can index every level with string keys.
+func normalizeFact(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = normalizeFact(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalizeFact(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeFact(item)
+		}
+		return val
+	}
+	return v
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
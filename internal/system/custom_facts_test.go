@@ -0,0 +1,93 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melih-ucgun/veto/internal/core"
+)
+
+func writeFact(t *testing.T, dir, name, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCustomFacts(t *testing.T) {
+	dir := t.TempDir()
+	writeFact(t, dir, "datacenter.json", `{"region": "eu-west", "rack": 12}`, 0644)
+	writeFact(t, dir, "role.yaml", "name: web\ntier: 2\n", 0644)
+	writeFact(t, dir, "build.sh", "#!/bin/sh\necho '{\"commit\": \"abc123\"}'\n", 0755)
+	writeFact(t, dir, "plain", "#!/bin/sh\necho hello\n", 0755)
+	writeFact(t, dir, "broken", "#!/bin/sh\necho boom >&2\nexit 3\n", 0755)
+	writeFact(t, dir, "README.md", "not a fact", 0644)
+
+	ctx := core.NewSystemContext(false, nil)
+	facts, errs := LoadCustomFacts(ctx, []string{dir})
+
+	dc, ok := facts["datacenter"].(map[string]interface{})
+	if !ok || dc["region"] != "eu-west" {
+		t.Errorf("Unexpected datacenter fact: %#v", facts["datacenter"])
+	}
+	role, ok := facts["role"].(map[string]interface{})
+	if !ok || role["name"] != "web" {
+		t.Errorf("Unexpected role fact: %#v", facts["role"])
+	}
+	build, ok := facts["build"].(map[string]interface{})
+	if !ok || build["commit"] != "abc123" {
+		t.Errorf("Unexpected build fact: %#v", facts["build"])
+	}
+	if facts["plain"] != "hello" {
+		t.Errorf("Expected plain text fact, got %#v", facts["plain"])
+	}
+	if _, ok := facts["README"]; ok {
+		t.Error("README.md should not be loaded as a fact")
+	}
+
+	// A failing executable is isolated
+	if _, ok := facts["broken"]; ok {
+		t.Error("Failed fact should not be set")
+	}
+	if errs["broken"] == "" {
+		t.Error("Failed fact should report its error")
+	}
+
+	// Facts are usable from templates and 'when' expressions
+	ctx.Facts = facts
+	out, err := core.ExecuteTemplate("{{ .Facts.datacenter.region }}", ctx)
+	if err != nil || out != "eu-west" {
+		t.Errorf("Template access failed: %q, %v", out, err)
+	}
+	ok, err = core.EvaluateCondition(`Facts.role.name == "web"`, ctx)
+	if err != nil || !ok {
+		t.Errorf("Expression access failed: %v, %v", ok, err)
+	}
+}
+
+func TestLoadCustomFactsOverride(t *testing.T) {
+	system, project := t.TempDir(), t.TempDir()
+	writeFact(t, system, "env.json", `"staging"`, 0644)
+	writeFact(t, project, "env.json", `"production"`, 0644)
+
+	facts, _ := LoadCustomFacts(core.NewSystemContext(false, nil), []string{system, project})
+	if facts["env"] != "production" {
+		t.Errorf("Project facts should override system facts, got %v", facts["env"])
+	}
+}
+
+func TestCustomFactTimeout(t *testing.T) {
+	dir := t.TempDir()
+	writeFact(t, dir, "slow", "#!/bin/sh\n# veto:timeout=1\nsleep 10\n", 0755)
+
+	start := time.Now()
+	facts, errs := LoadCustomFacts(core.NewSystemContext(false, nil), []string{dir})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Timeout not enforced, took %s", elapsed)
+	}
+	if _, ok := facts["slow"]; ok || errs["slow"] == "" {
+		t.Errorf("Expected slow fact to fail, facts=%v errs=%v", facts, errs)
+	}
+}
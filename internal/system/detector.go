@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/melih-ucgun/veto/internal/core"
 )
@@ -19,19 +20,7 @@ func Detect(ctx *core.SystemContext) {
 	// If still nil and no transport, fallback to RealFS (Local) is unsafe without context?
 	// But Detect usually requires valid context. We'll proceed.
 
-	// Helper to execute command
-	execCmd := func(cmdStr string) (string, error) {
-		if ctx.Transport != nil {
-			return ctx.Transport.Execute(ctx.Context, cmdStr)
-		}
-		// Fallback to local execution
-		// Very basic split, might not handle complex quotes, but enough for hostname etc.
-		// Actually RunCommand logic in core deals with sh -c.
-		// We can just run sh -c here too.
-		c := exec.Command("sh", "-c", cmdStr)
-		out, err := c.CombinedOutput()
-		return string(out), err
-	}
+	execCmd := commandRunner(ctx)
 
 	// 1. Temel OS Bilgileri
 	info := readOSRelease(ctx)
@@ -45,21 +34,11 @@ func Detect(ctx *core.SystemContext) {
 	ctx.InitSystem = detectInitSystem(ctx, execCmd)
 	ctx.Kernel = detectKernel(ctx, execCmd)
 
-	// Arch tabanlı veya versiyon bilgisi olmayan sistemler için Rolling Release kontrolü
-	if ctx.Version == "" {
-		rollingDistros := []string{"arch", "cachyos", "manjaro", "endeavouros"}
-		for _, d := range rollingDistros {
-			if strings.Contains(strings.ToLower(ctx.Distro), d) {
-				ctx.Version = "Rolling Release"
-				break
-			}
-		}
-	}
+	ctx.Family = core.DistroFamily(ctx.Distro, info["ID_LIKE"])
 
-	if val, ok := info["ID_LIKE"]; ok {
-		if strings.Contains(val, "arch") && ctx.Version == "" {
-			ctx.Version = "Rolling Release"
-		}
+	// Arch tabanlı veya versiyon bilgisi olmayan sistemler için Rolling Release kontrolü
+	if ctx.Version == "" && ctx.Family == core.FamilyArch {
+		ctx.Version = "Rolling Release"
 	}
 
 	// 2. Kullanıcı Bilgileri
@@ -78,12 +57,43 @@ func Detect(ctx *core.SystemContext) {
 
 	// 3. Donanım Tespiti
 	ctx.Hardware = detectHardware(ctx, execCmd)
+	ctx.Hardware.IsLaptop, ctx.Hardware.HasBattery = detectPower(ctx)
+	ctx.Virtualization, ctx.Container = detectVirtualization(ctx, execCmd)
 
 	// 4. Çevresel Değişkenler
 	ctx.Env = detectEnv(ctx, execCmd)
+	ctx.Env.Desktop, ctx.Env.SessionType = detectSession(execCmd)
 
 	// 5. Dosya Sistemi
 	ctx.FSInfo = detectFS(ctx, "/")
+	ctx.Storage = detectStorage(ctx, execCmd)
+
+	// 6. Ağ
+	ctx.Network = detectNetwork(execCmd)
+
+	// 7. Kullanıcı Tanımlı Facts (facts.d)
+	ctx.Facts, ctx.FactErrors = LoadCustomFacts(ctx, CustomFactDirs())
+
+	now := time.Now()
+	ctx.FactTimes = make(map[string]time.Time, len(FactGroups))
+	for _, name := range FactGroups {
+		ctx.FactTimes[name] = now
+	}
+}
+
+// commandRunner returns a helper executing commands through the context transport,
+// falling back to a local shell when no transport is set.
+func commandRunner(ctx *core.SystemContext) func(string) (string, error) {
+	return func(cmdStr string) (string, error) {
+		if ctx.Transport != nil {
+			return ctx.Transport.Execute(ctx.Context, cmdStr)
+		}
+		// Fallback to local execution
+		// RunCommand logic in core deals with sh -c, we do the same here.
+		c := exec.Command("sh", "-c", cmdStr)
+		out, err := c.CombinedOutput()
+		return string(out), err
+	}
 }
 
 func readOSRelease(ctx *core.SystemContext) map[string]string {
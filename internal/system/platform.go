@@ -0,0 +1,265 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/melih-ucgun/veto/internal/core"
+)
+
+// detectNetwork reads interfaces and the default route from iproute2's JSON output.
+func detectNetwork(execCmd func(string) (string, error)) core.SystemNetwork {
+	net := core.SystemNetwork{}
+
+	if out, err := execCmd("ip -j addr show"); err == nil {
+		var links []struct {
+			IfName   string   `json:"ifname"`
+			Address  string   `json:"address"`
+			OperSt   string   `json:"operstate"`
+			Flags    []string `json:"flags"`
+			AddrInfo []struct {
+				Family    string `json:"family"`
+				Local     string `json:"local"`
+				PrefixLen int    `json:"prefixlen"`
+			} `json:"addr_info"`
+		}
+		if json.Unmarshal([]byte(out), &links) == nil {
+			for _, l := range links {
+				iface := core.NetInterface{
+					Name: l.IfName,
+					MAC:  l.Address,
+					Up:   l.OperSt == "UP" || (containsString(l.Flags, "UP") && l.OperSt == "UNKNOWN"),
+				}
+				for _, a := range l.AddrInfo {
+					iface.Addresses = append(iface.Addresses, fmt.Sprintf("%s/%d", a.Local, a.PrefixLen))
+				}
+				net.Interfaces = append(net.Interfaces, iface)
+			}
+		}
+	}
+
+	if out, err := execCmd("ip -j route show default"); err == nil {
+		var routes []struct {
+			Gateway string `json:"gateway"`
+			Dev     string `json:"dev"`
+		}
+		if json.Unmarshal([]byte(out), &routes) == nil && len(routes) > 0 {
+			net.DefaultGateway = routes[0].Gateway
+			net.DefaultIface = routes[0].Dev
+		}
+	}
+
+	for _, iface := range net.Interfaces {
+		if iface.Name != net.DefaultIface {
+			continue
+		}
+		for _, addr := range iface.Addresses {
+			if ip, _, ok := strings.Cut(addr, "/"); ok && !strings.Contains(ip, ":") {
+				net.PrimaryIP = ip
+				break
+			}
+		}
+	}
+
+	return net
+}
+
+// detectStorage lists block devices (lsblk) and real mounts (/proc/mounts).
+func detectStorage(ctx *core.SystemContext, execCmd func(string) (string, error)) core.SystemStorage {
+	storage := core.SystemStorage{}
+
+	if out, err := execCmd("lsblk -J -b -o NAME,SIZE,TYPE,ROTA,MODEL"); err == nil {
+		var tree struct {
+			BlockDevices []lsblkDevice `json:"blockdevices"`
+		}
+		if json.Unmarshal([]byte(out), &tree) == nil {
+			storage.Disks = flattenBlockDevices(tree.BlockDevices, nil)
+		}
+	}
+
+	if f, err := ctx.FS.Open("/proc/mounts"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			// Only device-backed mounts; proc, sysfs, tmpfs, cgroup... are noise
+			if len(fields) < 3 || !strings.HasPrefix(fields[0], "/") {
+				continue
+			}
+			storage.Mounts = append(storage.Mounts, core.Mount{
+				Device: fields[0],
+				Path:   fields[1],
+				FSType: fields[2],
+			})
+		}
+	}
+
+	return storage
+}
+
+// lsblkDevice tolerates both old (strings) and new (typed) lsblk JSON output.
+type lsblkDevice struct {
+	Name     string        `json:"name"`
+	Size     interface{}   `json:"size"`
+	Type     string        `json:"type"`
+	Rota     interface{}   `json:"rota"`
+	Model    *string       `json:"model"`
+	Children []lsblkDevice `json:"children"`
+}
+
+func flattenBlockDevices(devices []lsblkDevice, out []core.BlockDevice) []core.BlockDevice {
+	for _, d := range devices {
+		dev := core.BlockDevice{Name: d.Name, Type: d.Type}
+		switch v := d.Size.(type) {
+		case float64:
+			dev.Size = int64(v)
+		case string:
+			dev.Size, _ = strconv.ParseInt(v, 10, 64)
+		}
+		switch v := d.Rota.(type) {
+		case bool:
+			dev.Rotational = v
+		case string:
+			dev.Rotational = v == "1"
+		}
+		if d.Model != nil {
+			dev.Model = strings.TrimSpace(*d.Model)
+		}
+		out = append(out, dev)
+		out = flattenBlockDevices(d.Children, out)
+	}
+	return out
+}
+
+// detectVirtualization returns the hypervisor and container type ("none" if bare metal / host).
+func detectVirtualization(ctx *core.SystemContext, execCmd func(string) (string, error)) (string, string) {
+	// systemd-detect-virt exits non-zero but prints "none" when nothing is detected,
+	// so accept any single-word answer and ignore shell errors ("command not found")
+	detectVirt := func(flag string) string {
+		out, _ := execCmd("systemd-detect-virt " + flag)
+		out = strings.TrimSpace(out)
+		if out == "" || strings.ContainsAny(out, " :\n") {
+			return ""
+		}
+		return out
+	}
+	virt, container := detectVirt("--vm"), detectVirt("--container")
+
+	if container == "" {
+		container = "none"
+		if _, err := ctx.FS.Stat("/.dockerenv"); err == nil {
+			container = "docker"
+		} else if _, err := ctx.FS.Stat("/run/.containerenv"); err == nil {
+			container = "podman"
+		} else if data, err := ctx.FS.ReadFile("/proc/1/cgroup"); err == nil {
+			content := string(data)
+			switch {
+			case strings.Contains(content, "docker"):
+				container = "docker"
+			case strings.Contains(content, "lxc"):
+				container = "lxc"
+			case strings.Contains(content, "kubepods"):
+				container = "kubernetes"
+			}
+		}
+	}
+
+	if virt == "" {
+		virt = "none"
+		if data, err := ctx.FS.ReadFile("/sys/class/dmi/id/product_name"); err == nil {
+			product := strings.ToLower(string(data))
+			switch {
+			case strings.Contains(product, "kvm"), strings.Contains(product, "qemu"):
+				virt = "kvm"
+			case strings.Contains(product, "virtualbox"):
+				virt = "oracle"
+			case strings.Contains(product, "vmware"):
+				virt = "vmware"
+			case strings.Contains(product, "virtual machine"):
+				virt = "microsoft"
+			}
+		}
+	}
+
+	return virt, container
+}
+
+// laptopChassisTypes are SMBIOS chassis types of portable machines.
+var laptopChassisTypes = map[string]bool{"8": true, "9": true, "10": true, "14": true, "31": true, "32": true}
+
+// detectPower reports battery presence and whether the machine is a laptop.
+func detectPower(ctx *core.SystemContext) (isLaptop bool, hasBattery bool) {
+	if entries, err := ctx.FS.ReadDir("/sys/class/power_supply"); err == nil {
+		for _, e := range entries {
+			data, err := ctx.FS.ReadFile(path.Join("/sys/class/power_supply", e.Name(), "type"))
+			if err == nil && strings.TrimSpace(string(data)) == "Battery" {
+				hasBattery = true
+				break
+			}
+		}
+	}
+
+	if data, err := ctx.FS.ReadFile("/sys/class/dmi/id/chassis_type"); err == nil {
+		isLaptop = laptopChassisTypes[strings.TrimSpace(string(data))]
+	}
+	return isLaptop || hasBattery, hasBattery
+}
+
+// detectSession finds the desktop environment and session type. Over SSH the
+// environment has no graphical session, so logind is asked as a fallback.
+func detectSession(execCmd func(string) (string, error)) (string, string) {
+	desktop, _ := execCmd("echo $XDG_CURRENT_DESKTOP")
+	session, _ := execCmd("echo $XDG_SESSION_TYPE")
+	desktop, session = strings.TrimSpace(desktop), strings.TrimSpace(session)
+
+	if desktop == "" || session == "" {
+		out, err := execCmd("loginctl list-sessions --no-legend")
+		if err == nil {
+			for _, line := range strings.Split(out, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 0 {
+					continue
+				}
+				props, err := execCmd("loginctl show-session " + fields[0] + " -p Type -p Desktop")
+				if err != nil {
+					continue
+				}
+				var sType, sDesktop string
+				for _, p := range strings.Split(props, "\n") {
+					if v, ok := strings.CutPrefix(strings.TrimSpace(p), "Type="); ok {
+						sType = v
+					} else if v, ok := strings.CutPrefix(strings.TrimSpace(p), "Desktop="); ok {
+						sDesktop = v
+					}
+				}
+				if sType == "wayland" || sType == "x11" {
+					if session == "" {
+						session = sType
+					}
+					if desktop == "" {
+						desktop = sDesktop
+					}
+					break
+				}
+			}
+		}
+	}
+
+	if session == "" {
+		session = "tty"
+	}
+	return desktop, session
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
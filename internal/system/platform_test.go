@@ -0,0 +1,58 @@
+package system
+
+import (
+	"context"
+	"testing"
+
+	"github.com/melih-ucgun/veto/internal/core"
+	"github.com/melih-ucgun/veto/internal/transport"
+)
+
+func TestDetectPlatformFacts(t *testing.T) {
+	mock := transport.NewMockTransport()
+	mock.AddResponse("hostname", "garuda-box")
+	mock.AddResponse("ip -j addr show", `[
+		{"ifname":"lo","address":"00:00:00:00:00:00","operstate":"UNKNOWN","flags":["LOOPBACK","UP"],
+		 "addr_info":[{"family":"inet","local":"127.0.0.1","prefixlen":8}]},
+		{"ifname":"wlan0","address":"aa:bb:cc:dd:ee:ff","operstate":"UP","flags":["UP"],
+		 "addr_info":[{"family":"inet6","local":"fe80::1","prefixlen":64},{"family":"inet","local":"192.168.1.20","prefixlen":24}]}]`)
+	mock.AddResponse("ip -j route show default", `[{"dst":"default","gateway":"192.168.1.1","dev":"wlan0"}]`)
+	mock.AddResponse("lsblk -J -b -o NAME,SIZE,TYPE,ROTA,MODEL", `{"blockdevices":[
+		{"name":"nvme0n1","size":512110190592,"type":"disk","rota":false,"model":"Samsung SSD 980 ",
+		 "children":[{"name":"nvme0n1p1","size":"536870912","type":"part","rota":"0","model":null}]}]}`)
+	mock.AddResponse("systemd-detect-virt --vm", "kvm\n")
+	mock.AddResponse("systemd-detect-virt --container", "none\n")
+	mock.AddResponse("echo $XDG_CURRENT_DESKTOP", "KDE\n")
+	mock.AddResponse("echo $XDG_SESSION_TYPE", "wayland\n")
+	mock.FileContent["/etc/os-release"] = "ID=garuda\nID_LIKE=arch\n"
+	mock.FileContent["/sys/class/dmi/id/chassis_type"] = "10\n"
+	mock.FileContent["/proc/mounts"] = "proc /proc proc rw 0 0\n/dev/nvme0n1p2 / btrfs rw 0 0\n"
+
+	ctx := &core.SystemContext{Context: context.Background(), Transport: mock, FS: mock.GetFileSystem()}
+	Detect(ctx)
+
+	if ctx.Family != core.FamilyArch || ctx.Version != "Rolling Release" {
+		t.Errorf("Unexpected family/version: %s %s", ctx.Family, ctx.Version)
+	}
+	if ctx.Network.DefaultIface != "wlan0" || ctx.Network.DefaultGateway != "192.168.1.1" || ctx.Network.PrimaryIP != "192.168.1.20" {
+		t.Errorf("Unexpected network facts: %+v", ctx.Network)
+	}
+	if len(ctx.Network.Interfaces) != 2 || !ctx.Network.Interfaces[0].Up {
+		t.Errorf("Unexpected interfaces: %+v", ctx.Network.Interfaces)
+	}
+	if len(ctx.Storage.Disks) != 2 || ctx.Storage.Disks[0].Model != "Samsung SSD 980" || ctx.Storage.Disks[1].Size != 536870912 {
+		t.Errorf("Unexpected disks: %+v", ctx.Storage.Disks)
+	}
+	if len(ctx.Storage.Mounts) != 1 || ctx.Storage.Mounts[0].FSType != "btrfs" {
+		t.Errorf("Unexpected mounts: %+v", ctx.Storage.Mounts)
+	}
+	if ctx.Virtualization != "kvm" || ctx.Container != "none" {
+		t.Errorf("Unexpected virtualization: %s / %s", ctx.Virtualization, ctx.Container)
+	}
+	if !ctx.Hardware.IsLaptop {
+		t.Error("Chassis type 10 should be detected as laptop")
+	}
+	if ctx.Env.Desktop != "KDE" || ctx.Env.SessionType != "wayland" {
+		t.Errorf("Unexpected session: %s / %s", ctx.Env.Desktop, ctx.Env.SessionType)
+	}
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"errors"
 	"io"
 	"os"
 
@@ -105,6 +106,25 @@ func (fs *SFTPFS) ReadFile(filename string) ([]byte, error) {
 	return io.ReadAll(f)
 }
 
+// CreateExclusive writes a new file, failing with os.ErrExist if it exists (SSH_FXF_EXCL).
+func (fs *SFTPFS) CreateExclusive(filename string, data []byte, perm os.FileMode) error {
+	f, err := fs.client.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		// Servers report a generic failure for existing files
+		if _, statErr := fs.client.Stat(filename); statErr == nil {
+			return os.ErrExist
+		}
+		return err
+	}
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		fs.client.Remove(filename)
+		return err
+	}
+	return fs.client.Chmod(filename, perm)
+}
+
 func (fs *SFTPFS) WriteFile(filename string, data []byte, perm os.FileMode) error {
 	f, err := fs.client.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
 	if err != nil {
@@ -118,6 +138,55 @@ func (fs *SFTPFS) WriteFile(filename string, data []byte, perm os.FileMode) erro
 	return fs.client.Chmod(filename, perm)
 }
 
+// AppendFile appends data to filename and fsyncs it when the server supports
+// the fsync@openssh.com extension.
+func (fs *SFTPFS) AppendFile(filename string, data []byte, perm os.FileMode) error {
+	f, err := fs.client.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return syncFile(f)
+}
+
+// WriteFileAtomic writes a temporary file and renames it over filename, so readers
+// see either the old or the new content.
+func (fs *SFTPFS) WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp := filename + ".tmp"
+	f, err := fs.client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = syncFile(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fs.client.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = fs.client.PosixRename(tmp, filename)
+	}
+	if err != nil {
+		fs.client.Remove(tmp)
+	}
+	return err
+}
+
+func syncFile(f *sftp.File) error {
+	err := f.Sync()
+	var status *sftp.StatusError
+	if errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxOpUnsupported {
+		return nil // Best effort on servers without fsync
+	}
+	return err
+}
+
 func (fs *SFTPFS) Symlink(oldname, newname string) error {
 	return fs.client.Symlink(oldname, newname)
 }
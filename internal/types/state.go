@@ -22,6 +22,24 @@ type TransactionChange struct {
 	BackupPath string `json:"backup_path,omitempty"`
 	Diff       string `json:"diff,omitempty"`   // Diff of the change
 	Detail     string `json:"detail,omitempty"` // Extra details (e.g. error msg)
+	// State of the object before the change (e.g. previous package version), used by rollback
+	Before map[string]interface{} `json:"before,omitempty"`
+}
+
+// Actions of the state-only changes made by 'veto state' commands. They touch no
+// system object, so rollback leaves them alone.
+const (
+	ActionStateRemove = "state-rm"
+	ActionStateMove   = "state-mv"
+	ActionStateImport = "state-import"
+)
+
+// ActionReverted is the action of the changes in a rollback transaction.
+const ActionReverted = "reverted"
+
+// IsStateAction reports whether action only changed the state.
+func IsStateAction(action string) bool {
+	return action == ActionStateRemove || action == ActionStateMove || action == ActionStateImport
 }
 
 // Transaction represents a session of changes (e.g. one apply run).
@@ -30,11 +48,16 @@ type Transaction struct {
 	Timestamp time.Time           `json:"timestamp"`
 	Status    string              `json:"status"` // success, failed, reverted
 	Changes   []TransactionChange `json:"changes"`
+	Reverts   []string            `json:"reverts,omitempty"` // IDs of the transactions a rollback reverted
 }
 
+// StateSchemaVersion is the state file schema written by this binary. Older files are
+// migrated when loaded (see state.RegisterMigration), newer ones are refused.
+const StateSchemaVersion = 2
+
 // State, tüm sistemin o anki snapshot'ıdır.
 type State struct {
-	Version   string                   `json:"version"` // State dosya versiyonu
+	Version   int                      `json:"version"` // State dosya şema versiyonu
 	LastRun   time.Time                `json:"last_run"`
 	Resources map[string]ResourceEntry `json:"resources"`
 	History   []Transaction            `json:"history,omitempty"` // Log of actions
@@ -42,7 +65,7 @@ type State struct {
 
 func NewState() *State {
 	return &State{
-		Version:   "1.0",
+		Version:   StateSchemaVersion,
 		Resources: make(map[string]ResourceEntry),
 	}
 }
@@ -0,0 +1,51 @@
+//go:build linux
+
+package utils
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestWriteFileAtomicWriteError makes the write itself fail (file size limit), which
+// must leave the old file in place and no temporary file behind.
+func TestWriteFileAtomicWriteError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Skip(err)
+	}
+	signal.Ignore(syscall.SIGXFSZ)
+	defer signal.Reset(syscall.SIGXFSZ)
+	small := limit
+	small.Cur = 16
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &small); err != nil {
+		t.Skip(err)
+	}
+	err := WriteFileAtomic(path, bytes.Repeat([]byte("x"), 4096), 0644)
+	appendErr := AppendFile(path, bytes.Repeat([]byte("x"), 4096), 0644)
+	syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit)
+
+	if err == nil {
+		t.Fatal("WriteFileAtomic ignored the write error")
+	}
+	if appendErr == nil {
+		t.Error("AppendFile ignored the write error")
+	}
+	if data, _ := os.ReadFile(path); string(data[:3]) != "old" {
+		t.Errorf("Old content replaced: %q", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Temporary file left behind: %v", entries)
+	}
+}